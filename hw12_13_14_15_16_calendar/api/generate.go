@@ -0,0 +1,5 @@
+// Package api holds the Protobuf specification of the calendar API.
+// Go code is generated into pkg/eventpb.
+package api
+
+//go:generate buf generate
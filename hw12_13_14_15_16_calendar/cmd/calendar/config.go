@@ -1,20 +1,177 @@
 package main
 
+import (
+	"io"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+)
+
 // При желании конфигурацию можно вынести в internal/config.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger LoggerConf
-	// TODO
+	Logger      LoggerConf
+	Storage     StorageConf
+	Cache       CacheConf
+	App         AppConf
+	Attachments AttachmentsConf
+	Auth        AuthConf
+	RateLimit   RateLimitConf
+	HTTP        HTTPConf
+	GRPC        ServerConf
+	Tracing     TracingConf
+	Metrics     MetricsConf
+	Shutdown    ShutdownConf
 }
 
 type LoggerConf struct {
 	Level string
-	// TODO
 }
 
-func NewConfig() Config {
-	return Config{}
+type StorageConf struct {
+	// Type is either "memory" or "sql".
+	Type string
+	DSN  string `secret:"url"`
 }
 
-// TODO
+// CacheConf configures the cache of event listings. Listings changed by other
+// replicas are seen when they expire.
+type CacheConf struct {
+	// MaxEvents bounds the number of cached events, zero disables the cache.
+	MaxEvents int
+	TTL       time.Duration
+}
+
+type AppConf struct {
+	// IdempotencyTTL is how long an Idempotency-Key of a create request is remembered.
+	IdempotencyTTL time.Duration
+	// MaxEventsPerUser limits the number of stored events of a user, zero means no limit.
+	MaxEventsPerUser int
+	// ChangeHistory is how many latest event changes are kept so that watchers can resume after a reconnect.
+	ChangeHistory int
+}
+
+// AttachmentsConf configures files attached to events, their contents are kept
+// as files of Dir. Replicas need a shared directory.
+type AttachmentsConf struct {
+	Dir string
+	// MaxSize is the maximum size of a file in bytes, zero means no limit.
+	MaxSize int64
+	// MaxPerEvent is the maximum number of files attached to an event, zero means no limit.
+	MaxPerEvent int
+	// SweepInterval is how often files of attachments deleted with their calendars
+	// or by retention are deleted, zero disables it.
+	SweepInterval time.Duration
+}
+
+// AuthConf configures authentication of API clients.
+// Static tokens and JWTs may be enabled together.
+type AuthConf struct {
+	// TrustUserIDHeader takes the user ID from the X-User-Id header (x-user-id metadata)
+	// without any verification. For local runs only.
+	TrustUserIDHeader bool
+	Tokens            []TokenConf
+	JWT               JWTConf
+	// Admins are the user IDs allowed to export and erase data of any user.
+	Admins []string
+}
+
+type TokenConf struct {
+	Token  string `secret:"true"`
+	UserID string
+}
+
+// JWTConf configures verification of JWTs, the user ID is taken from the "sub" claim.
+type JWTConf struct {
+	// Algorithm is "HS256" or "RS256", empty disables JWTs.
+	Algorithm string
+	// Secret is the HS256 shared secret.
+	Secret string `secret:"true"`
+	// PublicKeyFile is a PEM encoded RS256 public key.
+	PublicKeyFile string
+	// Issuer is the required "iss" claim, empty accepts any issuer.
+	Issuer string
+}
+
+// RateLimitConf configures token buckets kept per user and per client IP.
+// Rate is in requests per second, zero disables limiting.
+type RateLimitConf struct {
+	Rate   float64
+	Burst  int
+	Routes []RouteRateLimitConf
+}
+
+// RouteRateLimitConf overrides the limit of a method, named by its HTTP route
+// (e.g. "POST /v1/events") and gRPC method (e.g. "/event.EventService/CreateEvent").
+type RouteRateLimitConf struct {
+	HTTP  string
+	GRPC  string
+	Rate  float64
+	Burst int
+}
+
+type ServerConf struct {
+	Host string
+	Port int
+}
+
+type HTTPConf struct {
+	Host string
+	Port int
+	// PublicURL is the base URL clients reach the API at, e.g. "https://calendar.example.com".
+	// Exported calendars link attachments under it, empty leaves them out.
+	PublicURL string
+}
+
+type MetricsConf struct {
+	// Addr serves metrics for Prometheus, empty disables them.
+	Addr string
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// ShutdownConf configures stopping on SIGINT and SIGTERM.
+type ShutdownConf struct {
+	// Timeout is how long servers drain requests in progress and the other components
+	// stop, in total. It should be shorter than the time the orchestrator waits before
+	// killing the process.
+	Timeout time.Duration
+}
+
+// EnvPrefix starts the names of environment variables overriding the config,
+// e.g. CALENDAR_STORAGE_DSN.
+const EnvPrefix = "CALENDAR"
+
+// attachmentsDir is the default directory of the files of attachments.
+const attachmentsDir = "/var/lib/calendar/attachments"
+
+func NewConfig(path string) (Config, error) {
+	conf := Config{
+		Logger:      LoggerConf{Level: "INFO"},
+		Storage:     StorageConf{Type: "memory"},
+		Cache:       CacheConf{MaxEvents: 100000, TTL: time.Minute},
+		App:         AppConf{IdempotencyTTL: 24 * time.Hour, ChangeHistory: 1000},
+		Attachments: AttachmentsConf{Dir: attachmentsDir, MaxSize: 10 << 20, MaxPerEvent: 20, SweepInterval: time.Hour},
+		RateLimit:   RateLimitConf{Rate: 10, Burst: 20},
+		HTTP:        HTTPConf{Host: "0.0.0.0", Port: 8888},
+		GRPC:        ServerConf{Host: "0.0.0.0", Port: 50051},
+		Tracing:     TracingConf{Exporter: "none", SampleRatio: 1},
+		Shutdown:    ShutdownConf{Timeout: 10 * time.Second},
+	}
+	if err := config.Load(path, EnvPrefix, &conf); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// Print writes the effective configuration with its secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	return config.Print(w, c)
+}
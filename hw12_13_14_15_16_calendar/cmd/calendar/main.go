@@ -2,22 +2,37 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/cache"
 	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
 )
 
-var configFile string
+var (
+	configFile  string
+	printConfig bool
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config.toml", "Path to configuration file")
+	flag.BoolVar(&printConfig, "print-config", false,
+		"Print the effective configuration, environment overrides included, with secrets redacted and exit")
 }
 
 func main() {
@@ -28,34 +43,167 @@ func main() {
 		return
 	}
 
-	config := NewConfig()
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+	if printConfig {
+		if err := config.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	logg := logger.New(config.Logger.Level)
 
-	storage := memorystorage.New()
-	calendar := app.New(logg, storage)
-
-	server := internalhttp.NewServer(logg, calendar)
-
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	go func() {
-		<-ctx.Done()
+	shutdownTracing, err := tracing.Setup(ctx, "calendar", tracing.Config(config.Tracing))
+	if err != nil {
+		logg.Error("failed to init tracing: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+	shutdownMetrics, err := metrics.Setup(ctx, "calendar", metrics.Config(config.Metrics))
+	if err != nil {
+		logg.Error("failed to init metrics: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
 
-		if err := server.Stop(ctx); err != nil {
-			logg.Error("failed to stop http server: " + err.Error())
-		}
-	}()
+	storage, storageComponent, err := newStorage(config.Storage)
+	if err != nil {
+		logg.Error("failed to init storage: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	if config.Cache.MaxEvents > 0 {
+		storage = cache.New(storage, config.Cache.MaxEvents, config.Cache.TTL)
+	}
+
+	feed := changefeed.New(config.App.ChangeHistory)
+	blobs := blob.NewFS(config.Attachments.Dir)
+	calendar := app.New(logg, storage, feed, blobs, config.App.IdempotencyTTL, config.App.MaxEventsPerUser,
+		app.AttachmentLimits{MaxSize: config.Attachments.MaxSize, MaxPerEvent: config.Attachments.MaxPerEvent})
+
+	authenticator, err := newAuthenticator(config.Auth)
+	if err != nil {
+		logg.Error("failed to init authentication: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	if authenticator == nil {
+		logg.Warn("user IDs are taken from request headers without authentication")
+	}
+
+	limiter := ratelimit.New(rateLimitRules(config.RateLimit))
+	reloader := newReloader(config, logg, limiter)
+	service := internalgrpc.NewService(logg, calendar, config.HTTP.PublicURL, config.Auth.Admins)
+	httpServer := internalhttp.NewServer(logg, service, authenticator, limiter, config.HTTP.Host, config.HTTP.Port)
+	grpcServer := internalgrpc.NewServer(logg, service, authenticator, limiter, config.GRPC.Host, config.GRPC.Port)
+
+	// Servers are stopped first, so that requests in progress drain while the storage is still open.
+	lifecycle := app.NewLifecycle(logg, config.Shutdown.Timeout)
+	lifecycle.Add(
+		app.Component{Name: "tracing", Stop: shutdownTracing},
+		app.Component{Name: "metrics", Stop: shutdownMetrics},
+		storageComponent,
+		app.Component{Name: "config reloader", Run: func(ctx context.Context) error {
+			reloader.Run(ctx)
+			return nil
+		}},
+	)
+	if config.Attachments.SweepInterval > 0 {
+		lifecycle.Add(app.Component{Name: "blob sweeper", Run: func(ctx context.Context) error {
+			calendar.RunBlobSweeper(ctx, config.Attachments.SweepInterval)
+			return nil
+		}})
+	}
+	lifecycle.Add(
+		app.Component{Name: "grpc server", Run: grpcServer.Start, Stop: grpcServer.Stop},
+		app.Component{Name: "http server", Run: httpServer.Start, Stop: httpServer.Stop},
+	)
 
 	logg.Info("calendar is running...")
 
-	if err := server.Start(ctx); err != nil {
-		logg.Error("failed to start http server: " + err.Error())
+	if err := lifecycle.Run(ctx); err != nil {
+		logg.Error(err.Error())
 		cancel()
 		os.Exit(1) //nolint:gocritic
 	}
 }
+
+// newAuthenticator returns nil if user IDs are trusted from request headers.
+func newAuthenticator(conf AuthConf) (auth.Authenticator, error) {
+	if conf.TrustUserIDHeader {
+		return nil, nil
+	}
+
+	var chain auth.Chain
+	if len(conf.Tokens) > 0 {
+		tokens := make(map[string]string, len(conf.Tokens))
+		for _, t := range conf.Tokens {
+			if t.Token == "" || t.UserID == "" {
+				return nil, errors.New("api token and its user id are required")
+			}
+			tokens[t.Token] = t.UserID
+		}
+		chain = append(chain, auth.NewStaticTokens(tokens))
+	}
+
+	switch conf.JWT.Algorithm {
+	case "":
+	case "HS256":
+		if conf.JWT.Secret == "" {
+			return nil, errors.New("jwt secret is required for HS256")
+		}
+		chain = append(chain, auth.NewHS256([]byte(conf.JWT.Secret), conf.JWT.Issuer))
+	case "RS256":
+		pem, err := os.ReadFile(conf.JWT.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt public key: %w", err)
+		}
+		key, err := auth.ParseRSAPublicKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse jwt public key: %w", err)
+		}
+		chain = append(chain, auth.NewRS256(key, conf.JWT.Issuer))
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", conf.JWT.Algorithm)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("no api tokens or jwt verification configured")
+	}
+	return chain, nil
+}
+
+func rateLimitRules(conf RateLimitConf) (ratelimit.Rule, []ratelimit.Route) {
+	routes := make([]ratelimit.Route, 0, len(conf.Routes))
+	for _, route := range conf.Routes {
+		routes = append(routes, ratelimit.Route{
+			HTTP: route.HTTP,
+			GRPC: route.GRPC,
+			Rule: ratelimit.Rule{Rate: route.Rate, Burst: route.Burst},
+		})
+	}
+	return ratelimit.Rule{Rate: conf.Rate, Burst: conf.Burst}, routes
+}
+
+// newStorage returns the storage and its component, which connects to the database on start.
+func newStorage(conf StorageConf) (app.Storage, app.Component, error) {
+	component := app.Component{Name: "storage"}
+	switch conf.Type {
+	case "memory":
+		return memorystorage.New(), component, nil
+	case "sql":
+		storage := sqlstorage.New(conf.DSN)
+		component.Start, component.Stop = storage.Connect, storage.Close
+		return storage, component, nil
+	}
+	return nil, component, fmt.Errorf("unknown storage type %q", conf.Type)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+)
+
+// reloadableKeys may change on SIGHUP, changes of other keys need a restart.
+var reloadableKeys = []string{"logger.level", "rateLimit"}
+
+func newReloader(conf Config, logg *logger.Logger, limiter *ratelimit.Limiter) *config.Reloader[Config] {
+	load := func() (Config, error) {
+		return NewConfig(configFile)
+	}
+	return config.NewReloader(logg, conf, load, reloadableKeys, func(conf Config) error {
+		if err := logg.SetLevel(conf.Logger.Level); err != nil {
+			return err
+		}
+		limiter.Update(rateLimitRules(conf.RateLimit))
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+)
+
+type Config struct {
+	Logger    LoggerConf
+	Storage   StorageConf
+	Queue     QueueConf
+	Scheduler SchedulerConf
+	Archive   ArchiveConf
+	Outbox    OutboxConf
+	Leader    LeaderConf
+	Tracing   TracingConf
+	Metrics   MetricsConf
+}
+
+type LoggerConf struct {
+	Level string
+}
+
+type StorageConf struct {
+	DSN string `secret:"url"`
+}
+
+type QueueConf struct {
+	URL      string `secret:"url"`
+	Exchange string
+	Queue    string
+	// ChangesQueue receives announcements of event changes, empty drops them.
+	ChangesQueue string
+}
+
+type SchedulerConf struct {
+	// Interval is how often the storage is scanned for notifications to send.
+	Interval time.Duration
+	// Retention is how long events are kept after they end, zero keeps them forever.
+	Retention time.Duration
+	// Policies override Retention for events of calendars.
+	Policies []RetentionPolicyConf
+	// IdempotencyTTL is how long idempotency keys of create requests are kept, it should
+	// not be shorter than app.idempotencyTTL of the API. Zero keeps them forever.
+	IdempotencyTTL time.Duration
+}
+
+type RetentionPolicyConf struct {
+	CalendarID string
+	Retention  time.Duration
+}
+
+type ArchiveConf struct {
+	// Dir receives expired events before they are deleted, empty deletes them without archiving.
+	Dir string
+}
+
+// OutboxConf configures the relay publishing queued messages.
+type OutboxConf struct {
+	// Interval is how often the outbox is checked for messages to publish.
+	Interval  time.Duration
+	BatchSize int
+	// Retention is how long published messages are kept.
+	Retention time.Duration
+}
+
+// LeaderConf configures the election of the replica that scans the storage,
+// the other replicas take over if it dies.
+type LeaderConf struct {
+	// Lock is "postgres", "file" for replicas on the same host, or "none" for a single replica.
+	Lock string
+	// File is the path of the "file" lock.
+	File string
+	// Interval is how often followers try to take the lock and the leader checks that it holds it.
+	Interval time.Duration
+}
+
+type MetricsConf struct {
+	// Addr serves metrics for Prometheus, empty disables them.
+	Addr string
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// EnvPrefix starts the names of environment variables overriding the config,
+// e.g. CALENDAR_SCHEDULER_QUEUE_URL.
+const EnvPrefix = "CALENDAR_SCHEDULER"
+
+func NewConfig(path string) (Config, error) {
+	conf := Config{
+		Logger:    LoggerConf{Level: "INFO"},
+		Queue:     QueueConf{Exchange: "calendar", Queue: "notifications", ChangesQueue: "event_changes"},
+		Scheduler: SchedulerConf{Interval: time.Minute, Retention: 365 * 24 * time.Hour, IdempotencyTTL: 24 * time.Hour},
+		Outbox:    OutboxConf{Interval: time.Second, BatchSize: 100, Retention: 24 * time.Hour},
+		Leader: LeaderConf{
+			Lock:     "postgres",
+			File:     filepath.Join(os.TempDir(), "calendar_scheduler.lock"),
+			Interval: 5 * time.Second,
+		},
+		Tracing: TracingConf{Exporter: "none", SampleRatio: 1},
+	}
+	if err := config.Load(path, EnvPrefix, &conf); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// Print writes the effective configuration with its secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	return config.Print(w, c)
+}
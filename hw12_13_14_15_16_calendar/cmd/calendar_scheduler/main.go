@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/archive"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/leader"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/outbox"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+)
+
+var (
+	configFile  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
+	flag.BoolVar(&printConfig, "print-config", false,
+		"Print the effective configuration, environment overrides included, with secrets redacted and exit")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+	if printConfig {
+		if err := config.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	logg := logger.New(config.Logger.Level)
+
+	if flag.Arg(0) == "restore" {
+		if err := restore(config, logg, flag.Args()[1:]); err != nil {
+			logg.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(config, logg); err != nil {
+		logg.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(config Config, logg *logger.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, "calendar_scheduler", tracing.Config(config.Tracing))
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}()
+
+	shutdownMetrics, err := metrics.Setup(ctx, "calendar_scheduler", metrics.Config(config.Metrics))
+	if err != nil {
+		return fmt.Errorf("failed to init metrics: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		_ = shutdownMetrics(ctx)
+	}()
+
+	store := sqlstorage.New(config.Storage.DSN)
+	if err := store.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to storage: %w", err)
+	}
+	defer store.Close(context.Background())
+
+	queues := map[string]string{storage.TopicNotifications: config.Queue.Queue}
+	if config.Queue.ChangesQueue != "" {
+		queues[storage.TopicEventChanges] = config.Queue.ChangesQueue
+	}
+	publishers := make(map[string]outbox.Publisher, len(queues))
+	for topic, name := range queues {
+		publisher := rabbit.New(config.Queue.URL, config.Queue.Exchange, name)
+		if err := publisher.Connect(ctx); err != nil {
+			return fmt.Errorf("failed to connect to queue %s: %w", name, err)
+		}
+		defer publisher.Close()
+		publishers[topic] = publisher
+	}
+
+	lock, err := newLeaderLock(config.Leader, store)
+	if err != nil {
+		return err
+	}
+
+	var archiver scheduler.Archiver
+	if config.Archive.Dir != "" {
+		archiver = archive.New(config.Archive.Dir)
+	}
+	sched := scheduler.New(logg, store, archiver, config.Scheduler.Interval, newRetention(config.Scheduler))
+	go newReloader(config, logg, sched).Run(ctx)
+	relay := outbox.New(logg, store, publishers,
+		config.Outbox.Interval, config.Outbox.BatchSize, config.Outbox.Retention)
+	lead := func(ctx context.Context) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			relay.Run(ctx)
+		}()
+		sched.Run(ctx)
+		<-done
+	}
+
+	logg.Info("scheduler is running...")
+	if lock == nil {
+		lead(ctx)
+		return nil
+	}
+	leader.New(logg, lock, config.Leader.Interval).Run(ctx, lead)
+	return nil
+}
+
+func newRetention(conf SchedulerConf) scheduler.Retention {
+	retention := scheduler.Retention{
+		Default:         conf.Retention,
+		Calendars:       make(map[string]time.Duration, len(conf.Policies)),
+		IdempotencyKeys: conf.IdempotencyTTL,
+	}
+	for _, policy := range conf.Policies {
+		retention.Calendars[policy.CalendarID] = policy.Retention
+	}
+	return retention
+}
+
+// newLeaderLock returns nil if the scheduler runs without leader election.
+func newLeaderLock(conf LeaderConf, storage *sqlstorage.Storage) (leader.Lock, error) {
+	switch conf.Lock {
+	case "none":
+		return nil, nil
+	case "postgres":
+		return storage.AdvisoryLock("calendar_scheduler"), nil
+	case "file":
+		return leader.NewFileLock(conf.File), nil
+	}
+	return nil, fmt.Errorf("unknown leader lock %q", conf.Lock)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+// reloadableKeys may change on SIGHUP, changes of other keys need a restart.
+var reloadableKeys = []string{
+	"logger.level",
+	"scheduler.interval",
+	"scheduler.retention",
+	"scheduler.policies",
+	"scheduler.idempotencyTTL",
+}
+
+func newReloader(conf Config, logg *logger.Logger, sched *scheduler.Scheduler) *config.Reloader[Config] {
+	load := func() (Config, error) {
+		return NewConfig(configFile)
+	}
+	return config.NewReloader(logg, conf, load, reloadableKeys, func(conf Config) error {
+		if conf.Scheduler.Interval <= 0 {
+			return errors.New("scheduler interval must be positive")
+		}
+		if err := logg.SetLevel(conf.Logger.Level); err != nil {
+			return err
+		}
+		sched.Reconfigure(conf.Scheduler.Interval, newRetention(conf.Scheduler))
+		return nil
+	})
+}
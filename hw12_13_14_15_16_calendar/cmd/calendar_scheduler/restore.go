@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/archive"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+// restore brings archived events back to the storage.
+func restore(config Config, logg *logger.Logger, paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("no archive files to restore")
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	store := sqlstorage.New(config.Storage.DSN)
+	if err := store.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to storage: %w", err)
+	}
+	defer store.Close(context.Background())
+
+	for _, path := range paths {
+		restored, err := archive.Restore(ctx, store, path)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+		logg.Info(fmt.Sprintf("%s: restored %d events, %d already exist", path, restored.Events, restored.Existing))
+		if len(restored.Conflicting) > 0 {
+			logg.Error(fmt.Sprintf("%s: %d events conflict with existing events, out-of-office periods or calendars: %s",
+				path, len(restored.Conflicting), strings.Join(restored.Conflicting, ", ")))
+		}
+	}
+	return nil
+}
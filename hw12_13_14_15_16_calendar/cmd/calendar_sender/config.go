@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+)
+
+type Config struct {
+	Logger  LoggerConf
+	Storage StorageConf
+	Queue   QueueConf
+	Sink    SinkConf
+	Tracing TracingConf
+}
+
+type LoggerConf struct {
+	Level string
+}
+
+// StorageConf configures the database the events of notifications are looked up in.
+type StorageConf struct {
+	DSN string `secret:"url"`
+}
+
+type QueueConf struct {
+	URL      string `secret:"url"`
+	Exchange string
+	Queue    string
+}
+
+// SinkConf configures where notifications are delivered.
+type SinkConf struct {
+	// Type is "log" or "webhook".
+	Type string
+	// URL receives notifications POSTed as JSON by the "webhook" sink.
+	URL     string `secret:"true"`
+	Timeout time.Duration
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// EnvPrefix starts the names of environment variables overriding the config,
+// e.g. CALENDAR_SENDER_QUEUE_URL.
+const EnvPrefix = "CALENDAR_SENDER"
+
+func NewConfig(path string) (Config, error) {
+	conf := Config{
+		Logger:  LoggerConf{Level: "INFO"},
+		Queue:   QueueConf{Exchange: "calendar", Queue: "notifications"},
+		Sink:    SinkConf{Type: "log", Timeout: 5 * time.Second},
+		Tracing: TracingConf{Exporter: "none", SampleRatio: 1},
+	}
+	if err := config.Load(path, EnvPrefix, &conf); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// Print writes the effective configuration with its secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	return config.Print(w, c)
+}
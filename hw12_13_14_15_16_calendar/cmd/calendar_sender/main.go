@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+)
+
+var (
+	configFile  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml", "Path to configuration file")
+	flag.BoolVar(&printConfig, "print-config", false,
+		"Print the effective configuration, environment overrides included, with secrets redacted and exit")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+	if printConfig {
+		if err := config.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	logg := logger.New(config.Logger.Level)
+
+	if err := run(config, logg); err != nil {
+		logg.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(config Config, logg *logger.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, "calendar_sender", tracing.Config(config.Tracing))
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}()
+
+	store := sqlstorage.New(config.Storage.DSN)
+	if err := store.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to storage: %w", err)
+	}
+	defer store.Close(context.Background())
+
+	consumer := rabbit.New(config.Queue.URL, config.Queue.Exchange, config.Queue.Queue)
+	if err := consumer.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to queue: %w", err)
+	}
+	defer consumer.Close()
+
+	sink, err := newSink(config.Sink, logg)
+	if err != nil {
+		return err
+	}
+
+	s := sender.New(logg, consumer, store, sink)
+	go newReloader(config, logg, s).Run(ctx)
+
+	logg.Info("sender is running...")
+	return s.Run(ctx)
+}
+
+func newSink(conf SinkConf, logg *logger.Logger) (sender.Sink, error) {
+	switch conf.Type {
+	case "log":
+		return sender.NewLogSink(logg), nil
+	case "webhook":
+		if conf.URL == "" {
+			return nil, errors.New("webhook sink needs a URL")
+		}
+		return sender.NewWebhookSink(conf.URL, conf.Timeout), nil
+	}
+	return nil, fmt.Errorf("unknown sink %q", conf.Type)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+)
+
+// reloadableKeys may change on SIGHUP, changes of other keys need a restart.
+var reloadableKeys = []string{"logger.level", "sink"}
+
+func newReloader(conf Config, logg *logger.Logger, s *sender.Sender) *config.Reloader[Config] {
+	load := func() (Config, error) {
+		return NewConfig(configFile)
+	}
+	return config.NewReloader(logg, conf, load, reloadableKeys, func(conf Config) error {
+		if _, err := logger.ParseLevel(conf.Logger.Level); err != nil {
+			return err
+		}
+		sink, err := newSink(conf.Sink, logg)
+		if err != nil {
+			return err
+		}
+		_ = logg.SetLevel(conf.Logger.Level)
+		s.SetSink(sink)
+		return nil
+	})
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"create":    createEvent,
+	"update":    updateEvent,
+	"add":       quickAdd,
+	"delete":    deleteEvents,
+	"list":      listEvents,
+	"export":    exportEvents,
+	"import":    importEvents,
+	"scheduler": schedulerStatus,
+}
+
+// importAttempts is how many times import tries to create an event while rate limited.
+const importAttempts = 5
+
+// Accepted formats of times in flags, times without a zone are local.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+type cli struct {
+	client eventpb.EventServiceClient
+	out    io.Writer
+	json   bool
+}
+
+// eventFlags are the fields of an event set by create and update.
+type eventFlags struct {
+	title       string
+	description string
+	calendar    string
+	location    string
+	conference  string
+	start       string
+	end         string
+	duration    time.Duration
+	notify      time.Duration
+	outOfOffice bool
+	category    string
+	tags        stringsFlag
+}
+
+func (f *eventFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.title, "title", "", "title (required)")
+	fs.StringVar(&f.description, "description", "", "description")
+	fs.StringVar(&f.calendar, "calendar", "", "shared calendar ID, empty for a personal event")
+	fs.StringVar(&f.location, "location", "", "room or street address")
+	fs.StringVar(&f.conference, "conference", "", "URL to join the online meeting")
+	fs.StringVar(&f.start, "start", "", `start time, e.g. "2025-03-10 10:00" or RFC 3339 (required)`)
+	fs.StringVar(&f.end, "end", "", "end time, defaults to start plus duration")
+	fs.DurationVar(&f.duration, "duration", time.Hour, "duration if end is not set")
+	fs.DurationVar(&f.notify, "notify", 0, "notify this long before the start, zero disables notification")
+	fs.BoolVar(&f.outOfOffice, "ooo", false, "out-of-office period declining new personal events it overlaps")
+	fs.StringVar(&f.category, "category", "", "category ID")
+	fs.Var(&f.tags, "tag", "tag, may be repeated")
+}
+
+func (f *eventFlags) event() (*eventpb.Event, error) {
+	start, err := parseTime(f.start)
+	if err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	end := start.Add(f.duration)
+	if f.end != "" {
+		if end, err = parseTime(f.end); err != nil {
+			return nil, fmt.Errorf("end: %w", err)
+		}
+	}
+	event := &eventpb.Event{
+		Title:        f.title,
+		Description:  f.description,
+		CalendarId:   f.calendar,
+		StartAt:      timestamppb.New(start),
+		EndAt:        timestamppb.New(end),
+		NotifyBefore: durationpb.New(f.notify),
+		CategoryId:   f.category,
+		Tags:         f.tags,
+	}
+	if f.outOfOffice {
+		event.Kind = eventpb.EventKind_EVENT_KIND_OUT_OF_OFFICE
+	}
+	if f.location != "" || f.conference != "" {
+		event.Location = &eventpb.Location{Address: f.location, ConferenceUrl: f.conference}
+	}
+	return event, nil
+}
+
+func createEvent(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	var f eventFlags
+	f.register(fs)
+	_ = fs.Parse(args)
+
+	event, err := f.event()
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: event})
+	if err != nil {
+		return err
+	}
+	return c.printEvents(resp, resp.GetEvent())
+}
+
+func updateEvent(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	var f eventFlags
+	f.register(fs)
+	id := fs.String("id", "", "event ID (required)")
+	version := fs.Int64("version", 0, "fail if the event version has changed, zero overwrites any version")
+	_ = fs.Parse(args)
+
+	if *id == "" {
+		return errors.New("id is required")
+	}
+	event, err := f.event()
+	if err != nil {
+		return err
+	}
+	event.Version = *version
+	resp, err := c.client.UpdateEvent(ctx, &eventpb.UpdateEventRequest{Id: *id, Event: event})
+	if err != nil {
+		return err
+	}
+	return c.printEvents(resp, resp.GetEvent())
+}
+
+// quickAdd prints the events parsed from a phrase, or creates them with -create.
+func quickAdd(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	create := fs.Bool("create", false, "create the events rather than only print them")
+	calendar := fs.String("calendar", "", "shared calendar ID, empty for personal events")
+	occurrences := fs.Uint("occurrences", 0, "occurrences of a weekly event, 10 if zero")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New(`usage: add [-create] [-calendar ID] [-occurrences N] "lunch with Ana tomorrow 1pm for 45m"`)
+	}
+	resp, err := c.client.QuickAdd(ctx, &eventpb.QuickAddRequest{
+		Text:        strings.Join(fs.Args(), " "),
+		CalendarId:  *calendar,
+		Create:      *create,
+		Occurrences: uint32(*occurrences), //nolint:gosec // occurrences are few
+	})
+	if err != nil {
+		return err
+	}
+	return c.printEvents(resp, resp.GetEvents()...)
+}
+
+func deleteEvents(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: delete <id>...")
+	}
+	for _, id := range args {
+		if _, err := c.client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: id}); err != nil {
+			return fmt.Errorf("delete %s: %w", id, err)
+		}
+		if !c.json {
+			fmt.Fprintln(c.out, "deleted "+id)
+		}
+	}
+	return nil
+}
+
+func listEvents(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: list day|week|month [-date DATE] [-calendar ID]... [-category ID]... [-tag TAG]...")
+	}
+	lists := map[string]func(context.Context, *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error){
+		"day": func(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+			return c.client.ListDayEvents(ctx, req)
+		},
+		"week": func(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+			return c.client.ListWeekEvents(ctx, req)
+		},
+		"month": func(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+			return c.client.ListMonthEvents(ctx, req)
+		},
+	}
+	list, ok := lists[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown period %q, expected day, week or month", args[0])
+	}
+
+	fs := flag.NewFlagSet("list "+args[0], flag.ExitOnError)
+	date := fs.String("date", "", "first day of the period, defaults to today")
+	var calendars stringsFlag
+	fs.Var(&calendars, "calendar", "calendar ID, may be repeated; personal events and all calendars by default")
+	var categories, tags stringsFlag
+	fs.Var(&categories, "category", "list events of the category ID, may be repeated")
+	fs.Var(&tags, "tag", "list events having the tag, may be repeated to require all of them")
+	_ = fs.Parse(args[1:])
+
+	from := startOfDay(time.Now())
+	if *date != "" {
+		var err error
+		if from, err = parseTime(*date); err != nil {
+			return fmt.Errorf("date: %w", err)
+		}
+	}
+	resp, err := list(ctx, &eventpb.ListEventsRequest{
+		Date:        timestamppb.New(from),
+		CalendarIds: calendars,
+		CategoryIds: categories,
+		Tags:        tags,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printEvents(resp, resp.GetEvents()...)
+}
+
+// exportEvents writes every event the user sees as a line of JSON.
+func exportEvents(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	file := fs.String("file", "-", `output file, "-" for stdout`)
+	var calendars stringsFlag
+	fs.Var(&calendars, "calendar", "calendar ID, may be repeated; personal events and all calendars by default")
+	_ = fs.Parse(args)
+
+	out := os.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	count := 0
+	req := &eventpb.SyncRequest{CalendarIds: calendars}
+	for {
+		resp, err := c.client.Sync(ctx, req)
+		if err != nil {
+			return err
+		}
+		for _, change := range resp.GetChanges() {
+			if change.GetKind() == eventpb.ChangeKind_CHANGE_KIND_DELETED {
+				continue
+			}
+			line, err := protojson.Marshal(change.GetEvent())
+			if err != nil {
+				return err
+			}
+			_, _ = w.Write(append(line, '\n'))
+			count++
+		}
+		if !resp.GetHasMore() {
+			break
+		}
+		req.Token = resp.GetToken()
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d events\n", count)
+	return nil
+}
+
+// importEvents creates events read from lines of JSON, as written by export.
+// Every event is created with an idempotency key derived from its line, so an
+// interrupted import can be repeated without duplicating events.
+func importEvents(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "-", `input file, "-" for stdin`)
+	_ = fs.Parse(args)
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	created, failed := 0, 0
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		event := &eventpb.Event{}
+		if err := protojson.Unmarshal([]byte(line), event); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		event.Id, event.UserId, event.Version = "", "", 0
+		// Categories belong to the user who exported the events and may not exist where they are imported.
+		event.CategoryId = ""
+
+		hash := sha256.Sum256([]byte(line))
+		ctx := metadata.AppendToOutgoingContext(ctx,
+			internalgrpc.IdempotencyKeyKey, "import-"+hex.EncodeToString(hash[:16]))
+		if err := c.createWithRetry(ctx, &eventpb.CreateEventRequest{Event: event}); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", n, err)
+			failed++
+			continue
+		}
+		created++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d events\n", created)
+	if failed > 0 {
+		return fmt.Errorf("%d events failed", failed)
+	}
+	return nil
+}
+
+// createWithRetry waits and retries creates rejected by the rate limit.
+func (c *cli) createWithRetry(ctx context.Context, req *eventpb.CreateEventRequest) error {
+	for attempt := 1; ; attempt++ {
+		_, err := c.client.CreateEvent(ctx, req)
+		if status.Code(err) != codes.ResourceExhausted || attempt == importAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
+
+func schedulerStatus(ctx context.Context, c *cli, _ []string) error {
+	resp, err := c.client.GetSchedulerStatus(ctx, &emptypb.Empty{})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	fmt.Fprintf(c.out, "pending notifications: %d\n", resp.GetPendingNotifications())
+	if resp.GetOldestDueAt() != nil {
+		dueAt := resp.GetOldestDueAt().AsTime()
+		fmt.Fprintf(c.out, "oldest due at: %s (%s ago)\n",
+			dueAt.Local().Format(time.DateTime), time.Since(dueAt).Round(time.Second))
+	}
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// stringsFlag collects the values of a repeated flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `calendarctl is a command line client of the calendar gRPC API.
+
+Usage:
+  calendarctl [flags] <command> [command flags]
+
+Commands:
+  create      create an event
+  update      replace an event
+  add         parse a phrase into events: add [-create] "lunch with Ana tomorrow 1pm"
+  delete      delete events: delete <id>...
+  list        list events: list day|week|month
+  export      write events to a JSON lines file
+  import      create events from a JSON lines file
+  scheduler   show notifications the scheduler has yet to publish
+  version     print the version
+
+Run "calendarctl <command> -h" for the flags of a command.
+
+Flags:
+`
+
+var (
+	address string
+	token   string
+	userID  string
+	output  string
+	timeout time.Duration
+)
+
+func init() {
+	flag.StringVar(&address, "addr", envOr("CALENDAR_ADDR", "localhost:50051"), "gRPC API address ($CALENDAR_ADDR)")
+	flag.StringVar(&token, "token", os.Getenv("CALENDAR_TOKEN"), "API token or JWT ($CALENDAR_TOKEN)")
+	flag.StringVar(&userID, "user", os.Getenv("CALENDAR_USER"),
+		"user ID for servers that trust the x-user-id metadata ($CALENDAR_USER)")
+	flag.StringVar(&output, "o", "table", "output format: table or json")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "timeout of a single API call")
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "calendarctl: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("command is required")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(callInterceptor),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return cmd(ctx, &cli{client: eventpb.NewEventServiceClient(conn), out: os.Stdout, json: output == "json"}, args[1:])
+}
+
+// callInterceptor authenticates every call and limits its duration.
+func callInterceptor(
+	ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, internalgrpc.AuthorizationKey, "Bearer "+token)
+	}
+	if userID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, internalgrpc.UserIDKey, userID)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// printEvents prints the response as JSON or its events as a table.
+func (c *cli) printEvents(resp proto.Message, events ...*eventpb.Event) error {
+	if c.json {
+		return c.printJSON(resp)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTART\tEND\tTITLE\tCALENDAR\tVERSION")
+	for _, event := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n",
+			event.GetId(),
+			formatTime(event.GetStartAt().AsTime()),
+			formatTime(event.GetEndAt().AsTime()),
+			event.GetTitle(),
+			event.GetCalendarId(),
+			event.GetVersion(),
+		)
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJSON(msg proto.Message) error {
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.out, string(data))
+	return err
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,55 @@
+package deployments
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the chart")
+
+// TestChart renders the chart at the root of the module with `helm template` and
+// compares the manifests with testdata/NAME.golden.yaml, the values of a case are
+// read from testdata/NAME.values.yaml. Run with -update to rewrite the golden files.
+func TestChart(t *testing.T) {
+	helm, err := exec.LookPath("helm")
+	if err != nil {
+		t.Skip("helm is not installed")
+	}
+
+	tests := []struct {
+		name   string
+		values bool
+	}{
+		{name: "default"},
+		{name: "ingress", values: true},
+		{name: "existing-secret", values: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := []string{"template", "calendar", "..", "--namespace", "calendar"}
+			if tc.values {
+				args = append(args, "--values", filepath.Join("testdata", tc.name+".values.yaml"))
+			}
+			var stderr bytes.Buffer
+			cmd := exec.Command(helm, args...)
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			require.NoError(t, err, stderr.String())
+
+			golden := filepath.Join("testdata", tc.name+".golden.yaml")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, out, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(want), string(out))
+		})
+	}
+}
@@ -2,25 +2,469 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrInvalidEvent    = errors.New("invalid event")
+	ErrInvalidCalendar = errors.New("invalid calendar")
+	ErrInvalidBatch    = errors.New("invalid batch")
+	ErrNoUser          = errors.New("user id is required")
+	ErrForbidden       = errors.New("permission denied")
+	ErrQuotaExceeded   = errors.New("too many events")
 )
 
-type App struct { // TODO
+type App struct {
+	logger           Logger
+	storage          Storage
+	feed             Feed
+	blobs            BlobStore
+	idempotencyTTL   time.Duration
+	maxEventsPerUser int
+	attachments      AttachmentLimits
+}
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app")
+
+type Logger interface {
+	DebugContext(ctx context.Context, msg string)
+	InfoContext(ctx context.Context, msg string)
+	WarnContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+// Feed delivers event changes to watchers.
+type Feed interface {
+	Publish(kind storage.ChangeKind, event storage.Event)
+	Subscribe(ctx context.Context, since int64) (<-chan storage.Change, error)
+}
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+	CreateEventWithKey(ctx context.Context, event storage.Event, key string, notBefore time.Time) (storage.Event, error)
+	GetEventByKey(ctx context.Context, userID, key string, notBefore time.Time) (storage.Event, error)
+	UpdateEvent(ctx context.Context, event storage.Event) error
+	DeleteEvent(ctx context.Context, id string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	ListCalendarEvents(ctx context.Context, calendarIDs []string, from, to time.Time) ([]storage.Event, error)
+	CountEvents(ctx context.Context, userID string) (int, error)
+	ListChanges(ctx context.Context, userID string, calendarIDs []string, since int64, limit int) ([]storage.Change, error)
+	ListEventsToNotify(ctx context.Context, now time.Time) ([]storage.Event, error)
+	ApplyBatch(ctx context.Context, ops []storage.BatchOp) ([]error, error)
+	CheckBatch(ctx context.Context, ops []storage.BatchOp) ([]error, error)
+
+	CreateCalendar(ctx context.Context, calendar storage.Calendar, ownerID string) error
+	GetCalendar(ctx context.Context, id string) (storage.Calendar, error)
+	DeleteCalendar(ctx context.Context, id string) error
+	SetMember(ctx context.Context, member storage.Member) error
+	RemoveMember(ctx context.Context, calendarID, userID string) error
+	GetMember(ctx context.Context, calendarID, userID string) (storage.Member, error)
+	ListMembers(ctx context.Context, calendarID string) ([]storage.Member, error)
+	ListUserCalendars(ctx context.Context, userID string) ([]storage.UserCalendar, error)
+
+	CreateCategory(ctx context.Context, category storage.Category) error
+	GetCategory(ctx context.Context, id string) (storage.Category, error)
+	UpdateCategory(ctx context.Context, category storage.Category) error
+	DeleteCategory(ctx context.Context, id string) error
+	ListCategories(ctx context.Context, userID string) ([]storage.Category, error)
+
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) error
+	GetAttachment(ctx context.Context, id string) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventIDs []string) ([]storage.Attachment, error)
+	MissingAttachments(ctx context.Context, ids []string) ([]string, error)
+	DeleteAttachment(ctx context.Context, id string) error
+
+	ListOverlappingEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	GetAvailability(ctx context.Context, userID string) (storage.Availability, error)
+	SetAvailability(ctx context.Context, availability storage.Availability) error
+
+	ReportUsage(ctx context.Context, query storage.UsageQuery) ([]storage.Usage, error)
+
+	ExportUserData(ctx context.Context, userID string) (storage.UserData, error)
+	DeleteUserData(ctx context.Context, record storage.AuditRecord) (storage.ErasedUserData, error)
+	AddAuditRecord(ctx context.Context, record storage.AuditRecord) (storage.AuditRecord, error)
+	ListAuditRecords(ctx context.Context, userID string) ([]storage.AuditRecord, error)
 }
 
-type Logger interface { // TODO
+// New creates the application. Create requests repeated with the same
+// idempotency key within idempotencyTTL return the originally created event.
+// A user may store at most maxEventsPerUser events, zero means no limit.
+// Contents of attachments are kept in blobs.
+func New(
+	logger Logger, storage Storage, feed Feed, blobs BlobStore,
+	idempotencyTTL time.Duration, maxEventsPerUser int, attachments AttachmentLimits,
+) *App {
+	return &App{
+		logger:           logger,
+		storage:          storage,
+		feed:             feed,
+		blobs:            blobs,
+		idempotencyTTL:   idempotencyTTL,
+		maxEventsPerUser: maxEventsPerUser,
+		attachments:      attachments,
+	}
 }
 
-type Storage interface { // TODO
+// CreateEvent creates a new event, a personal one unless event.CalendarID is set.
+// A non-empty idempotencyKey makes retries of the same request return the event
+// created by the first attempt.
+func (a *App) CreateEvent(
+	ctx context.Context, event storage.Event, idempotencyKey string,
+) (_ storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "app.CreateEvent")
+	defer func() { endSpan(span, err) }()
+
+	event.Tags = normalizeTags(event.Tags)
+	if err := validateEvent(event); err != nil {
+		return storage.Event{}, err
+	}
+	// A retry is answered before the checks, it must not fail because of its own first attempt.
+	notBefore := time.Now().Add(-a.idempotencyTTL)
+	if idempotencyKey != "" {
+		created, err := a.storage.GetEventByKey(ctx, event.UserID, idempotencyKey, notBefore)
+		if err == nil {
+			a.logger.DebugContext(ctx, fmt.Sprintf("create request with key %q replayed event %s", idempotencyKey, created.ID))
+			return created, nil
+		}
+		if !errors.Is(err, storage.ErrEventNotFound) {
+			return storage.Event{}, err
+		}
+	}
+	if event.CalendarID != "" {
+		if err := a.checkRole(ctx, event.UserID, event.CalendarID, storage.Role.CanWrite); err != nil {
+			return storage.Event{}, err
+		}
+	}
+	if err := a.checkCategory(ctx, event); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.checkOutOfOffice(ctx, event); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.checkQuota(ctx, event.UserID, 1); err != nil {
+		return storage.Event{}, err
+	}
+	event.ID = uuid.NewString()
+	event.Version = 1
+
+	if idempotencyKey == "" {
+		if err := a.storage.CreateEvent(ctx, event); err != nil {
+			return storage.Event{}, err
+		}
+	} else {
+		created, err := a.storage.CreateEventWithKey(ctx, event, idempotencyKey, notBefore)
+		if err != nil {
+			return storage.Event{}, err
+		}
+		if created.ID != event.ID {
+			a.logger.DebugContext(ctx, fmt.Sprintf("create request with key %q replayed event %s", idempotencyKey, created.ID))
+			return created, nil
+		}
+	}
+	a.feed.Publish(storage.ChangeCreated, event)
+	a.logger.DebugContext(ctx, fmt.Sprintf("event %s created by user %s", event.ID, event.UserID))
+	return event, nil
 }
 
-func New(logger Logger, storage Storage) *App {
-	return &App{}
+// UpdateEvent replaces the event on behalf of event.UserID. If event.Version is set,
+// the update is applied only if the event has not been changed since that version,
+// otherwise storage.ErrVersionConflict is returned. Events cannot be moved between calendars.
+func (a *App) UpdateEvent(ctx context.Context, id string, event storage.Event) (_ storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "app.UpdateEvent")
+	defer func() { endSpan(span, err) }()
+
+	event.Tags = normalizeTags(event.Tags)
+	if err := validateEvent(event); err != nil {
+		return storage.Event{}, err
+	}
+	userID := event.UserID
+	current, err := a.writableEvent(ctx, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if event.Version == 0 {
+		event.Version = current.Version
+	}
+	event.ID = id
+	event.UserID = current.UserID
+	event.CalendarID = current.CalendarID
+	if err := a.checkUpdatedEvent(ctx, current, event); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.checkCategory(ctx, event); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.storage.UpdateEvent(ctx, event); err != nil {
+		return storage.Event{}, err
+	}
+	event.Version++
+	a.feed.Publish(storage.ChangeUpdated, event)
+	a.logger.DebugContext(ctx, fmt.Sprintf("event %s updated by user %s", event.ID, userID))
+	return event, nil
 }
 
-func (a *App) CreateEvent(ctx context.Context, id, title string) error {
-	// TODO
+func (a *App) DeleteEvent(ctx context.Context, userID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "app.DeleteEvent")
+	defer func() { endSpan(span, err) }()
+
+	event, err := a.writableEvent(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	attachments, err := a.storage.ListAttachments(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+	if err := a.storage.DeleteEvent(ctx, id); err != nil {
+		return err
+	}
+	a.deleteBlobs(ctx, attachments)
+	a.feed.Publish(storage.ChangeDeleted, storage.Event{ID: id, UserID: event.UserID, CalendarID: event.CalendarID})
+	a.logger.DebugContext(ctx, fmt.Sprintf("event %s deleted by user %s", id, userID))
 	return nil
-	// return a.storage.CreateEvent(storage.Event{ID: id, Title: title})
 }
 
-// TODO
+// ListDayEvents lists events of the given calendars, or of all calendars the user
+// has access to and the user's personal events if calendarIDs is empty, that pass the filter.
+// Events of free/busy calendars only tell when they take place and pass empty filters only.
+func (a *App) ListDayEvents(
+	ctx context.Context, userID string, calendarIDs []string, filter EventFilter, date time.Time,
+) ([]storage.Event, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, calendarIDs, filter, from, from.AddDate(0, 0, 1))
+}
+
+func (a *App) ListWeekEvents(
+	ctx context.Context, userID string, calendarIDs []string, filter EventFilter, date time.Time,
+) ([]storage.Event, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, calendarIDs, filter, from, from.AddDate(0, 0, 7))
+}
+
+func (a *App) ListMonthEvents(
+	ctx context.Context, userID string, calendarIDs []string, filter EventFilter, date time.Time,
+) ([]storage.Event, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, calendarIDs, filter, from, from.AddDate(0, 1, 0))
+}
+
+func (a *App) listEvents(
+	ctx context.Context, userID string, calendarIDs []string, filter EventFilter, from, to time.Time,
+) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "app.ListEvents")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+
+	roles, err := a.roles(ctx, userID, calendarIDs)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]storage.Event, 0)
+	if len(calendarIDs) == 0 {
+		personal, err := a.storage.ListEvents(ctx, userID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		events = filterEvents(personal, filter)
+	}
+	if len(roles) == 0 {
+		return events, nil
+	}
+
+	ids := make([]string, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	shared, err := a.storage.ListCalendarEvents(ctx, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range shared {
+		if !roles[event.CalendarID].CanRead() {
+			event = busyEvent(event)
+		}
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+	return events, nil
+}
+
+func filterEvents(events []storage.Event, filter EventFilter) []storage.Event {
+	filtered := events[:0]
+	for _, event := range events {
+		if filter.Match(event) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// checkQuota checks that the user may create n more events. It is a soft limit:
+// concurrent creates may exceed it by a few events.
+func (a *App) checkQuota(ctx context.Context, userID string, n int) error {
+	if a.maxEventsPerUser <= 0 {
+		return nil
+	}
+	count, err := a.storage.CountEvents(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count+n > a.maxEventsPerUser {
+		return fmt.Errorf("%w: limit is %d", ErrQuotaExceeded, a.maxEventsPerUser)
+	}
+	return nil
+}
+
+// checkUpdatedEvent checks the update of the current event: events of calendars cannot
+// become out-of-office periods, and personal events moved to or made regular
+// must not overlap out-of-office periods.
+func (a *App) checkUpdatedEvent(ctx context.Context, current, event storage.Event) error {
+	if event.Kind == storage.KindOutOfOffice && event.CalendarID != "" {
+		return fmt.Errorf("%w: out-of-office events must be personal", ErrInvalidEvent)
+	}
+	if current.Kind == event.Kind && current.StartAt.Equal(event.StartAt) && current.EndAt.Equal(event.EndAt) {
+		return nil
+	}
+	return a.checkOutOfOffice(ctx, event)
+}
+
+// writableEvent loads the event the user may change. Events the user cannot see
+// are hidden behind ErrEventNotFound.
+func (a *App) writableEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	event, role, err := a.readableEvent(ctx, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if event.CalendarID != "" && !role.CanWrite() {
+		return storage.Event{}, fmt.Errorf("%w: %s cannot change events", ErrForbidden, role)
+	}
+	return event, nil
+}
+
+// readableEvent loads the event the user may see in full along with the user's role
+// in its calendar, personal events have no role. Events the user cannot see and events
+// of free/busy calendars are hidden behind ErrEventNotFound.
+func (a *App) readableEvent(ctx context.Context, userID, id string) (storage.Event, storage.Role, error) {
+	if userID == "" {
+		return storage.Event{}, "", ErrNoUser
+	}
+	event, err := a.storage.GetEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, "", err
+	}
+	if event.CalendarID == "" {
+		if event.UserID != userID {
+			return storage.Event{}, "", storage.ErrEventNotFound
+		}
+		return event, "", nil
+	}
+
+	role, err := a.role(ctx, userID, event.CalendarID)
+	if errors.Is(err, storage.ErrCalendarNotFound) {
+		return storage.Event{}, "", storage.ErrEventNotFound
+	}
+	switch {
+	case err != nil:
+		return storage.Event{}, "", err
+	case !role.CanRead():
+		return storage.Event{}, "", storage.ErrEventNotFound
+	}
+	return event, role, nil
+}
+
+// roles returns the roles of the user in the given calendars, or in all calendars
+// of the user if calendarIDs is empty.
+func (a *App) roles(ctx context.Context, userID string, calendarIDs []string) (map[string]storage.Role, error) {
+	roles := make(map[string]storage.Role, len(calendarIDs))
+	if len(calendarIDs) > 0 {
+		for _, id := range calendarIDs {
+			role, err := a.role(ctx, userID, id)
+			if err != nil {
+				return nil, err
+			}
+			roles[id] = role
+		}
+		return roles, nil
+	}
+
+	calendars, err := a.storage.ListUserCalendars(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, calendar := range calendars {
+		roles[calendar.ID] = calendar.Role
+	}
+	return roles, nil
+}
+
+// busyEvent strips everything but the ID and time from an event of a free/busy calendar.
+func busyEvent(event storage.Event) storage.Event {
+	return storage.Event{
+		ID:         event.ID,
+		CalendarID: event.CalendarID,
+		StartAt:    event.StartAt,
+		EndAt:      event.EndAt,
+	}
+}
+
+// isBusyEvent tells events stripped by busyEvent, only they have no user.
+func isBusyEvent(event storage.Event) bool {
+	return event.UserID == ""
+}
+
+func validateEvent(event storage.Event) error {
+	switch {
+	case event.UserID == "":
+		return ErrNoUser
+	case event.Title == "":
+		return fmt.Errorf("%w: title is required", ErrInvalidEvent)
+	case event.StartAt.IsZero():
+		return fmt.Errorf("%w: start time is required", ErrInvalidEvent)
+	case !event.EndAt.After(event.StartAt):
+		return fmt.Errorf("%w: event must end after it starts", ErrInvalidEvent)
+	case event.NotifyBefore < 0:
+		return fmt.Errorf("%w: notify before must not be negative", ErrInvalidEvent)
+	case event.Kind != storage.KindRegular && event.Kind != storage.KindOutOfOffice:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidEvent, event.Kind)
+	case event.Kind == storage.KindOutOfOffice && event.CalendarID != "":
+		return fmt.Errorf("%w: out-of-office events must be personal", ErrInvalidEvent)
+	}
+	if event.Location.ConferenceURL != "" {
+		u, err := url.Parse(event.Location.ConferenceURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: conference URL must be an absolute http(s) URL", ErrInvalidEvent)
+		}
+	}
+	return validateTags(event.Tags)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedCalendars(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	room, err := a.CreateCalendar(ctx, "alice", "Team Room")
+	require.NoError(t, err)
+	require.Equal(t, storage.RoleOwner, room.Role)
+	for user, role := range map[string]storage.Role{
+		"bob":   storage.RoleEditor,
+		"carol": storage.RoleViewer,
+		"dave":  storage.RoleFreeBusy,
+	} {
+		require.NoError(t, a.ShareCalendar(ctx, "alice", storage.Member{CalendarID: room.ID, UserID: user, Role: role}))
+	}
+
+	newEvent := func(userID string, startAt time.Time) storage.Event {
+		return storage.Event{
+			Title:      "planning",
+			UserID:     userID,
+			CalendarID: room.ID,
+			StartAt:    startAt,
+			EndAt:      startAt.Add(time.Hour),
+		}
+	}
+	event, err := a.CreateEvent(ctx, newEvent("bob", start), "")
+	require.NoError(t, err)
+
+	t.Run("writes", func(t *testing.T) {
+		_, err := a.CreateEvent(ctx, newEvent("carol", start.Add(2*time.Hour)), "")
+		require.ErrorIs(t, err, ErrForbidden)
+		_, err = a.CreateEvent(ctx, newEvent("eve", start.Add(2*time.Hour)), "")
+		require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+		_, err = a.CreateEvent(ctx, newEvent("alice", start.Add(30*time.Minute)), "")
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		update := newEvent("carol", start)
+		_, err = a.UpdateEvent(ctx, event.ID, update)
+		require.ErrorIs(t, err, ErrForbidden)
+		update.UserID = "dave"
+		_, err = a.UpdateEvent(ctx, event.ID, update)
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		update.UserID = "alice"
+		update.Title = "retro"
+		updated, err := a.UpdateEvent(ctx, event.ID, update)
+		require.NoError(t, err)
+		require.Equal(t, "bob", updated.UserID)
+		require.Equal(t, room.ID, updated.CalendarID)
+
+		require.ErrorIs(t, a.DeleteEvent(ctx, "eve", event.ID), storage.ErrEventNotFound)
+	})
+
+	t.Run("aggregated list", func(t *testing.T) {
+		_, err := a.CreateEvent(ctx, storage.Event{
+			Title: "dentist", UserID: "dave", StartAt: start, EndAt: start.Add(time.Hour),
+		}, "")
+		require.NoError(t, err)
+
+		events, err := a.ListDayEvents(ctx, "carol", nil, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "retro", events[0].Title)
+
+		events, err = a.ListDayEvents(ctx, "dave", nil, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "dentist", events[0].Title)
+		require.Equal(t, storage.Event{
+			ID: event.ID, CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+		}, events[1])
+
+		events, err = a.ListDayEvents(ctx, "dave", []string{room.ID}, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+
+		_, err = a.ListDayEvents(ctx, "eve", []string{room.ID}, EventFilter{}, start)
+		require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+	})
+
+	t.Run("management", func(t *testing.T) {
+		err := a.ShareCalendar(ctx, "bob", storage.Member{CalendarID: room.ID, UserID: "eve", Role: storage.RoleViewer})
+		require.ErrorIs(t, err, ErrForbidden)
+		err = a.ShareCalendar(ctx, "alice", storage.Member{CalendarID: room.ID, UserID: "alice", Role: storage.RoleViewer})
+		require.ErrorIs(t, err, ErrInvalidCalendar)
+		require.ErrorIs(t, a.UnshareCalendar(ctx, "alice", room.ID, "alice"), ErrInvalidCalendar)
+
+		require.ErrorIs(t, a.UnshareCalendar(ctx, "bob", room.ID, "carol"), ErrForbidden)
+		require.NoError(t, a.UnshareCalendar(ctx, "carol", room.ID, "carol"))
+		_, err = a.ListMembers(ctx, "carol", room.ID)
+		require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+
+		require.ErrorIs(t, a.DeleteCalendar(ctx, "bob", room.ID), ErrForbidden)
+		require.NoError(t, a.DeleteCalendar(ctx, "alice", room.ID))
+		calendars, err := a.ListCalendars(ctx, "bob")
+		require.NoError(t, err)
+		require.Empty(t, calendars)
+	})
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 2, AttachmentLimits{})
+	newEvent := func(startAt time.Time) storage.Event {
+		return storage.Event{Title: "call", UserID: "alice", StartAt: startAt, EndAt: startAt.Add(time.Hour)}
+	}
+
+	t.Run("replay at quota returns the original event", func(t *testing.T) {
+		created, err := a.CreateEvent(ctx, newEvent(start), "first")
+		require.NoError(t, err)
+		_, err = a.CreateEvent(ctx, newEvent(start.Add(2*time.Hour)), "")
+		require.NoError(t, err)
+		_, err = a.CreateEvent(ctx, newEvent(start.Add(4*time.Hour)), "")
+		require.ErrorIs(t, err, ErrQuotaExceeded)
+
+		replayed, err := a.CreateEvent(ctx, newEvent(start), "first")
+		require.NoError(t, err)
+		require.Equal(t, created, replayed)
+	})
+
+	t.Run("replay over out of office returns the original event", func(t *testing.T) {
+		b := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+			blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+		created, err := b.CreateEvent(ctx, newEvent(start), "first")
+		require.NoError(t, err)
+		away := newEvent(start.Add(-time.Hour))
+		away.Kind = storage.KindOutOfOffice
+		away.EndAt = start.Add(24 * time.Hour)
+		_, err = b.CreateEvent(ctx, away, "")
+		require.NoError(t, err)
+
+		replayed, err := b.CreateEvent(ctx, newEvent(start), "first")
+		require.NoError(t, err)
+		require.Equal(t, created, replayed)
+		_, err = b.CreateEvent(ctx, newEvent(start), "second")
+		require.ErrorIs(t, err, ErrOutOfOffice)
+	})
+}
+
+func TestSchedulerStatus(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	status, err := a.SchedulerStatus(ctx, "alice", now)
+	require.NoError(t, err)
+	require.Equal(t, SchedulerStatus{}, status)
+
+	for i, notifyBefore := range []time.Duration{time.Hour, 3 * time.Hour, time.Minute} {
+		startAt := now.Add(time.Duration(i+1) * 30 * time.Minute)
+		_, err := a.CreateEvent(ctx, storage.Event{
+			Title: "call", UserID: "bob", StartAt: startAt, EndAt: startAt.Add(10 * time.Minute), NotifyBefore: notifyBefore,
+		}, "")
+		require.NoError(t, err)
+	}
+
+	status, err = a.SchedulerStatus(ctx, "alice", now)
+	require.NoError(t, err)
+	require.Equal(t, SchedulerStatus{PendingNotifications: 2, OldestDueAt: now.Add(-2 * time.Hour)}, status)
+
+	_, err = a.SchedulerStatus(ctx, "", now)
+	require.ErrorIs(t, err, ErrNoUser)
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrTooManyAttachments = errors.New("too many attachments")
+)
+
+const (
+	maxAttachmentNameLen      = 255
+	defaultAttachmentMimeType = "application/octet-stream"
+	// blobSweepGrace keeps contents written shortly before a sweep, their uploads
+	// may not have created the attachments yet.
+	blobSweepGrace = time.Hour
+	// blobSweepBatch is the number of contents checked against the storage at a time.
+	blobSweepBatch = 1000
+)
+
+// BlobStore keeps contents of attachments under the attachment IDs.
+type BlobStore interface {
+	// Put stores the content read from r and returns its size. A failed Put stores nothing.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete deletes the content, deleting missing content is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns keys of the contents written before the given time.
+	List(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// AttachmentLimits bound files attached to events, zero means no limit.
+type AttachmentLimits struct {
+	// MaxSize is the maximum size of a file in bytes.
+	MaxSize int64
+	// MaxPerEvent is the maximum number of files attached to an event.
+	MaxPerEvent int
+}
+
+// AddAttachment attaches the content to the event the user may change. The name and
+// the content type are taken from attachment, the rest is set by the application.
+// The limit of attachments per event is soft: concurrent uploads may exceed it.
+func (a *App) AddAttachment(
+	ctx context.Context, userID string, attachment storage.Attachment, content io.Reader,
+) (_ storage.Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "app.AddAttachment")
+	defer func() { endSpan(span, err) }()
+
+	if attachment.ContentType == "" {
+		attachment.ContentType = defaultAttachmentMimeType
+	}
+	if err := validateAttachment(attachment); err != nil {
+		return storage.Attachment{}, err
+	}
+	if _, err := a.writableEvent(ctx, userID, attachment.EventID); err != nil {
+		return storage.Attachment{}, err
+	}
+	if a.attachments.MaxPerEvent > 0 {
+		attached, err := a.storage.ListAttachments(ctx, []string{attachment.EventID})
+		if err != nil {
+			return storage.Attachment{}, err
+		}
+		if len(attached) >= a.attachments.MaxPerEvent {
+			return storage.Attachment{}, fmt.Errorf("%w: limit is %d per event",
+				ErrTooManyAttachments, a.attachments.MaxPerEvent)
+		}
+	}
+
+	attachment.ID = uuid.NewString()
+	attachment.UserID = userID
+	attachment.CreatedAt = time.Now()
+	if a.attachments.MaxSize > 0 {
+		content = &limitedReader{r: content, left: a.attachments.MaxSize, limit: a.attachments.MaxSize}
+	}
+	if attachment.Size, err = a.blobs.Put(ctx, attachment.ID, content); err != nil {
+		return storage.Attachment{}, err
+	}
+	if err := a.storage.CreateAttachment(ctx, attachment); err != nil {
+		// The event may have been deleted during the upload.
+		a.deleteBlobs(ctx, []storage.Attachment{attachment})
+		return storage.Attachment{}, err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("attachment %s of %d bytes added to event %s by user %s",
+		attachment.ID, attachment.Size, attachment.EventID, userID))
+	return attachment, nil
+}
+
+// ListAttachments lists files attached to the event the user may see in full.
+func (a *App) ListAttachments(ctx context.Context, userID, eventID string) ([]storage.Attachment, error) {
+	if _, _, err := a.readableEvent(ctx, userID, eventID); err != nil {
+		return nil, err
+	}
+	return a.storage.ListAttachments(ctx, []string{eventID})
+}
+
+// OpenAttachment returns the attachment and its content, the caller closes the content.
+// Attachments of events the user cannot see in full are not found.
+func (a *App) OpenAttachment(
+	ctx context.Context, userID, id string,
+) (_ storage.Attachment, _ io.ReadCloser, err error) {
+	ctx, span := tracer.Start(ctx, "app.OpenAttachment")
+	defer func() { endSpan(span, err) }()
+
+	attachment, err := a.readableAttachment(ctx, userID, id)
+	if err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	content, err := a.blobs.Open(ctx, attachment.ID)
+	if err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	return attachment, content, nil
+}
+
+// DeleteAttachment deletes a file attached to the event the user may change.
+func (a *App) DeleteAttachment(ctx context.Context, userID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "app.DeleteAttachment")
+	defer func() { endSpan(span, err) }()
+
+	attachment, err := a.readableAttachment(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := a.writableEvent(ctx, userID, attachment.EventID); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+	a.deleteBlobs(ctx, []storage.Attachment{attachment})
+	a.logger.DebugContext(ctx, fmt.Sprintf("attachment %s deleted by user %s", id, userID))
+	return nil
+}
+
+func (a *App) readableAttachment(ctx context.Context, userID, id string) (storage.Attachment, error) {
+	if userID == "" {
+		return storage.Attachment{}, ErrNoUser
+	}
+	attachment, err := a.storage.GetAttachment(ctx, id)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	_, _, err = a.readableEvent(ctx, userID, attachment.EventID)
+	if errors.Is(err, storage.ErrEventNotFound) {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// deleteBlobs deletes contents of deleted attachments. Failures are only logged,
+// SweepBlobs deletes the contents left behind later.
+func (a *App) deleteBlobs(ctx context.Context, attachments []storage.Attachment) {
+	for _, attachment := range attachments {
+		if err := a.blobs.Delete(ctx, attachment.ID); err != nil {
+			a.logger.ErrorContext(ctx, fmt.Sprintf("failed to delete content of attachment %s: %s", attachment.ID, err))
+		}
+	}
+}
+
+func validateAttachment(attachment storage.Attachment) error {
+	switch {
+	case attachment.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidAttachment)
+	case len(attachment.Name) > maxAttachmentNameLen:
+		return fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidAttachment, maxAttachmentNameLen)
+	case strings.ContainsAny(attachment.Name, "/\\\x00"):
+		return fmt.Errorf("%w: name must not contain path separators", ErrInvalidAttachment)
+	}
+	if _, _, err := mime.ParseMediaType(attachment.ContentType); err != nil {
+		return fmt.Errorf("%w: content type: %w", ErrInvalidAttachment, err)
+	}
+	return nil
+}
+
+// limitedReader fails with ErrAttachmentTooLarge once the content exceeds the limit.
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return 0, fmt.Errorf("%w: limit is %d bytes", ErrAttachmentTooLarge, l.limit)
+	}
+	return n, err
+}
+
+// SweepBlobs deletes contents of attachments that no longer exist: those deleted with
+// their events by calendar deletion or retention, and those whose deletion failed.
+// Contents written after the given time are kept. It returns the number of deleted contents.
+func (a *App) SweepBlobs(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "app.SweepBlobs")
+	defer func() { endSpan(span, err) }()
+
+	keys, err := a.blobs.List(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	// Contents are kept under attachment IDs, anything else is not ours to delete.
+	keys = slices.DeleteFunc(keys, func(key string) bool { return uuid.Validate(key) != nil })
+	deleted := 0
+	for chunk := range slices.Chunk(keys, blobSweepBatch) {
+		missing, err := a.storage.MissingAttachments(ctx, chunk)
+		if err != nil {
+			return deleted, err
+		}
+		for _, key := range missing {
+			if err := a.blobs.Delete(ctx, key); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		a.logger.InfoContext(ctx, fmt.Sprintf("deleted %d contents of deleted attachments", deleted))
+	}
+	return deleted, nil
+}
+
+// RunBlobSweeper runs SweepBlobs every interval until ctx is done.
+func (a *App) RunBlobSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.SweepBlobs(ctx, time.Now().Add(-blobSweepGrace)); err != nil && ctx.Err() == nil {
+			a.logger.ErrorContext(ctx, "blob sweep failed: "+err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
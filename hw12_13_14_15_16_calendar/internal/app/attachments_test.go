@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(dir), time.Hour, 0, AttachmentLimits{MaxSize: 10, MaxPerEvent: 2})
+
+	room, err := a.CreateCalendar(ctx, "alice", "Team Room")
+	require.NoError(t, err)
+	for user, role := range map[string]storage.Role{"carol": storage.RoleViewer, "dave": storage.RoleFreeBusy} {
+		require.NoError(t, a.ShareCalendar(ctx, "alice", storage.Member{CalendarID: room.ID, UserID: user, Role: role}))
+	}
+	event, err := a.CreateEvent(ctx, storage.Event{
+		Title: "planning", UserID: "alice", CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+		Location: storage.Location{Address: "Room 1", ConferenceURL: "https://meet.example.com/planning"},
+	}, "")
+	require.NoError(t, err)
+
+	read := func(t *testing.T, userID, id string) string {
+		t.Helper()
+		_, content, err := a.OpenAttachment(ctx, userID, id)
+		require.NoError(t, err)
+		defer content.Close()
+		data, err := io.ReadAll(content)
+		require.NoError(t, err)
+		return string(data)
+	}
+	blobs := func(t *testing.T) int {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	t.Run("add, open and delete", func(t *testing.T) {
+		agenda, err := a.AddAttachment(ctx, "alice",
+			storage.Attachment{EventID: event.ID, Name: "agenda.txt", ContentType: "text/plain"},
+			strings.NewReader("hello"))
+		require.NoError(t, err)
+		require.Equal(t, int64(5), agenda.Size)
+		require.Equal(t, "alice", agenda.UserID)
+
+		require.Equal(t, "hello", read(t, "carol", agenda.ID))
+		_, _, err = a.OpenAttachment(ctx, "dave", agenda.ID)
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+		_, err = a.ListAttachments(ctx, "dave", event.ID)
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		attachments, err := a.ListAttachments(ctx, "carol", event.ID)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Attachment{agenda}, attachments)
+
+		require.ErrorIs(t, a.DeleteAttachment(ctx, "carol", agenda.ID), ErrForbidden)
+		require.NoError(t, a.DeleteAttachment(ctx, "alice", agenda.ID))
+		_, _, err = a.OpenAttachment(ctx, "alice", agenda.ID)
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+		require.Equal(t, 0, blobs(t))
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := a.AddAttachment(ctx, "carol", storage.Attachment{EventID: event.ID, Name: "a"}, strings.NewReader("x"))
+		require.ErrorIs(t, err, ErrForbidden)
+		for _, name := range []string{"", "../a", strings.Repeat("a", 256)} {
+			_, err := a.AddAttachment(ctx, "alice", storage.Attachment{EventID: event.ID, Name: name}, strings.NewReader("x"))
+			require.ErrorIs(t, err, ErrInvalidAttachment, name)
+		}
+		_, err = a.AddAttachment(ctx, "alice",
+			storage.Attachment{EventID: event.ID, Name: "big.bin"}, strings.NewReader("more than ten bytes"))
+		require.ErrorIs(t, err, ErrAttachmentTooLarge)
+		require.Equal(t, 0, blobs(t))
+
+		exact, err := a.AddAttachment(ctx, "alice",
+			storage.Attachment{EventID: event.ID, Name: "exact.bin"}, strings.NewReader("0123456789"))
+		require.NoError(t, err)
+		require.Equal(t, "application/octet-stream", exact.ContentType)
+		_, err = a.AddAttachment(ctx, "alice", storage.Attachment{EventID: event.ID, Name: "b"}, strings.NewReader("b"))
+		require.NoError(t, err)
+		_, err = a.AddAttachment(ctx, "alice", storage.Attachment{EventID: event.ID, Name: "c"}, strings.NewReader("c"))
+		require.ErrorIs(t, err, ErrTooManyAttachments)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		exported, err := a.ExportEvents(ctx, "carol", nil, EventFilter{}, start, start.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, exported, 1)
+		require.Equal(t, event.Location, exported[0].Location)
+		require.Len(t, exported[0].Attachments, 2)
+
+		exported, err = a.ExportEvents(ctx, "dave", nil, EventFilter{}, start, start.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, exported, 1)
+		require.Empty(t, exported[0].Attachments)
+		require.Empty(t, exported[0].Location)
+
+		_, err = a.ExportEvents(ctx, "carol", nil, EventFilter{}, start, start)
+		require.ErrorIs(t, err, ErrInvalidRange)
+		_, err = a.ExportEvents(ctx, "carol", nil, EventFilter{}, start, start.AddDate(2, 0, 0))
+		require.ErrorIs(t, err, ErrInvalidRange)
+	})
+
+	t.Run("deleted with the event", func(t *testing.T) {
+		require.Equal(t, 2, blobs(t))
+		require.NoError(t, a.DeleteEvent(ctx, "alice", event.ID))
+		require.Equal(t, 0, blobs(t))
+	})
+
+	t.Run("swept after the calendar is deleted", func(t *testing.T) {
+		team, err := a.CreateCalendar(ctx, "alice", "Team")
+		require.NoError(t, err)
+		meeting, err := a.CreateEvent(ctx, storage.Event{
+			Title: "meeting", UserID: "alice", CalendarID: team.ID, StartAt: start, EndAt: start.Add(time.Hour),
+		}, "")
+		require.NoError(t, err)
+		_, err = a.AddAttachment(ctx, "alice",
+			storage.Attachment{EventID: meeting.ID, Name: "notes.txt"}, strings.NewReader("notes"))
+		require.NoError(t, err)
+		personal, err := a.CreateEvent(ctx, storage.Event{
+			Title: "personal", UserID: "alice", StartAt: start, EndAt: start.Add(time.Hour),
+		}, "")
+		require.NoError(t, err)
+		kept, err := a.AddAttachment(ctx, "alice",
+			storage.Attachment{EventID: personal.ID, Name: "kept.txt"}, strings.NewReader("kept"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a blob"), 0o600))
+
+		require.NoError(t, a.DeleteCalendar(ctx, "alice", team.ID))
+		require.Equal(t, 3, blobs(t))
+		// Contents written after the given time are kept.
+		deleted, err := a.SweepBlobs(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Zero(t, deleted)
+
+		deleted, err = a.SweepBlobs(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+		require.Equal(t, 2, blobs(t))
+		require.Equal(t, "kept", read(t, "alice", kept.ID))
+		require.NoError(t, a.DeleteEvent(ctx, "alice", personal.ID))
+	})
+
+	t.Run("conference url", func(t *testing.T) {
+		for _, u := range []string{"meet.example.com", "ftp://example.com", "https://"} {
+			_, err := a.CreateEvent(ctx, storage.Event{
+				Title: "call", UserID: "alice", StartAt: start, EndAt: start.Add(time.Hour),
+				Location: storage.Location{ConferenceURL: u},
+			}, "")
+			require.ErrorIs(t, err, ErrInvalidEvent, u)
+		}
+	})
+}
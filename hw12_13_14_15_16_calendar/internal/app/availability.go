@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxAvailabilityRange is the longest time range of an availability query.
+const MaxAvailabilityRange = 31 * 24 * time.Hour
+
+var (
+	ErrInvalidAvailability = errors.New("invalid availability settings")
+	// ErrOutOfOffice is returned by the storage as well, when a period is created concurrently.
+	ErrOutOfOffice = storage.ErrOutOfOffice
+)
+
+// SlotStatus tells whether a user may be invited for a time slot.
+type SlotStatus string
+
+const (
+	SlotFree        SlotStatus = "free"
+	SlotBusy        SlotStatus = "busy"
+	SlotOutOfOffice SlotStatus = "out_of_office"
+	SlotOffHours    SlotStatus = "off_hours"
+)
+
+// Slot is a time range [Start, End) of the same status.
+type Slot struct {
+	Start  time.Time
+	End    time.Time
+	Status SlotStatus
+}
+
+// GetAvailability returns the user's availability settings. Users who have not
+// set them work all the time in UTC.
+func (a *App) GetAvailability(ctx context.Context, userID string) (_ storage.Availability, err error) {
+	ctx, span := tracer.Start(ctx, "app.GetAvailability")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return storage.Availability{}, ErrNoUser
+	}
+	availability, err := a.storage.GetAvailability(ctx, userID)
+	if errors.Is(err, storage.ErrAvailabilityNotFound) {
+		return storage.Availability{UserID: userID, TimeZone: "UTC"}, nil
+	}
+	return availability, err
+}
+
+// SetAvailability replaces the user's availability settings. An empty time zone is UTC,
+// no working hours mean working all the time.
+func (a *App) SetAvailability(
+	ctx context.Context, userID string, availability storage.Availability,
+) (_ storage.Availability, err error) {
+	ctx, span := tracer.Start(ctx, "app.SetAvailability")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return storage.Availability{}, ErrNoUser
+	}
+	availability.UserID = userID
+	if availability.TimeZone == "" {
+		availability.TimeZone = "UTC"
+	}
+	if err := validateAvailability(availability); err != nil {
+		return storage.Availability{}, err
+	}
+	sort.Slice(availability.WorkingHours, func(i, j int) bool {
+		hi, hj := availability.WorkingHours[i], availability.WorkingHours[j]
+		if hi.Weekday != hj.Weekday {
+			return hi.Weekday < hj.Weekday
+		}
+		return hi.Start < hj.Start
+	})
+	if err := a.storage.SetAvailability(ctx, availability); err != nil {
+		return storage.Availability{}, err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("availability of user %s updated", userID))
+	return availability, nil
+}
+
+// QueryAvailability splits [from, to) into slots telling when targetUserID is
+// out of office, busy with personal events, off working hours or free, in that
+// order of precedence. Any user may query any other user: slots tell nothing but
+// the status. An empty targetUserID queries the user's own availability.
+func (a *App) QueryAvailability(
+	ctx context.Context, userID, targetUserID string, from, to time.Time,
+) (_ []Slot, err error) {
+	ctx, span := tracer.Start(ctx, "app.QueryAvailability")
+	defer func() { endSpan(span, err) }()
+
+	switch {
+	case userID == "":
+		return nil, ErrNoUser
+	case !to.After(from):
+		return nil, fmt.Errorf("%w: range must end after it starts", ErrInvalidRange)
+	case to.Sub(from) > MaxAvailabilityRange:
+		return nil, fmt.Errorf("%w: range is longer than %s", ErrInvalidRange, MaxAvailabilityRange)
+	}
+	if targetUserID == "" {
+		targetUserID = userID
+	}
+	availability, err := a.GetAvailability(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(availability.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.storage.ListOverlappingEvents(ctx, targetUserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return availabilitySlots(from, to, workingIntervals(availability.WorkingHours, location, from, to), events), nil
+}
+
+// checkOutOfOffice rejects regular personal events overlapping out-of-office periods
+// of their users, telling which period is in the way. The storage enforces the rule
+// in the end, for periods created concurrently or in the same batch.
+func (a *App) checkOutOfOffice(ctx context.Context, event storage.Event) error {
+	if event.Kind == storage.KindOutOfOffice || event.CalendarID != "" {
+		return nil
+	}
+	events, err := a.storage.ListOverlappingEvents(ctx, event.UserID, event.StartAt, event.EndAt)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.ID != event.ID && event.BlockedBy(e) {
+			return fmt.Errorf("%w: from %s to %s", ErrOutOfOffice,
+				e.StartAt.Format(time.RFC3339), e.EndAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func validateAvailability(availability storage.Availability) error {
+	if _, err := time.LoadLocation(availability.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidAvailability, availability.TimeZone)
+	}
+	for i, h := range availability.WorkingHours {
+		switch {
+		case h.Weekday < time.Sunday || h.Weekday > time.Saturday:
+			return fmt.Errorf("%w: unknown weekday %d", ErrInvalidAvailability, h.Weekday)
+		case h.Start < 0 || h.End > 24*time.Hour || h.Start >= h.End:
+			return fmt.Errorf("%w: working hours of %s must end after they start within the day",
+				ErrInvalidAvailability, h.Weekday)
+		}
+		for _, other := range availability.WorkingHours[:i] {
+			if other.Weekday == h.Weekday && other.Start < h.End && h.Start < other.End {
+				return fmt.Errorf("%w: working hours of %s overlap", ErrInvalidAvailability, h.Weekday)
+			}
+		}
+	}
+	return nil
+}
+
+// interval is a time range [start, end).
+type interval struct {
+	start, end time.Time
+}
+
+// workingIntervals returns the working intervals of the days around [from, to)
+// in the location, nil if the user works all the time.
+func workingIntervals(hours []storage.WorkingHours, location *time.Location, from, to time.Time) []interval {
+	if len(hours) == 0 {
+		return nil
+	}
+	intervals := make([]interval, 0)
+	// Days of the location start at most a day before the range in UTC.
+	day := startOfDay(from.In(location)).AddDate(0, 0, -1)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		year, month, date := day.Date()
+		for _, h := range hours {
+			if h.Weekday != day.Weekday() {
+				continue
+			}
+			// Seconds past midnight are normalized by time.Date across DST changes.
+			intervals = append(intervals, interval{
+				start: time.Date(year, month, date, 0, 0, int(h.Start/time.Second), 0, location),
+				end:   time.Date(year, month, date, 0, 0, int(h.End/time.Second), 0, location),
+			})
+		}
+	}
+	return intervals
+}
+
+// availabilitySlots splits [from, to) at the bounds of the working intervals and events,
+// in the location of from, and merges adjacent slots of the same status.
+// Nil working intervals mean working all the time.
+func availabilitySlots(from, to time.Time, working []interval, events []storage.Event) []Slot {
+	bounds := []time.Time{from, to}
+	addBound := func(t time.Time) {
+		if t.After(from) && t.Before(to) {
+			bounds = append(bounds, t.In(from.Location()))
+		}
+	}
+	for _, w := range working {
+		addBound(w.start)
+		addBound(w.end)
+	}
+	for _, e := range events {
+		addBound(e.StartAt)
+		addBound(e.EndAt)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+
+	slots := make([]Slot, 0)
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if !end.After(start) {
+			continue
+		}
+		status := slotStatus(start, end, working, events)
+		if n := len(slots); n > 0 && slots[n-1].Status == status {
+			slots[n-1].End = end
+			continue
+		}
+		slots = append(slots, Slot{Start: start, End: end, Status: status})
+	}
+	return slots
+}
+
+// slotStatus returns the status of [start, end) that no bound splits.
+func slotStatus(start, end time.Time, working []interval, events []storage.Event) SlotStatus {
+	status := SlotFree
+	if working != nil {
+		status = SlotOffHours
+		for _, w := range working {
+			if w.start.Before(end) && start.Before(w.end) {
+				status = SlotFree
+				break
+			}
+		}
+	}
+	for _, e := range events {
+		if !e.Intersects(start, end) {
+			continue
+		}
+		if e.Kind == storage.KindOutOfOffice {
+			return SlotOutOfOffice
+		}
+		status = SlotBusy
+	}
+	return status
+}
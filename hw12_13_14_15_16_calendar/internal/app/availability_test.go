@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailability(t *testing.T) {
+	ctx := context.Background()
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	newEvent := func(startAt time.Time, d time.Duration) storage.Event {
+		return storage.Event{Title: "event", UserID: "alice", StartAt: startAt, EndAt: startAt.Add(d)}
+	}
+	meeting, err := a.CreateEvent(ctx, newEvent(monday.Add(10*time.Hour), time.Hour), "")
+	require.NoError(t, err)
+
+	t.Run("out of office", func(t *testing.T) {
+		wednesday := monday.AddDate(0, 0, 2)
+		standup, err := a.CreateEvent(ctx, newEvent(wednesday.Add(9*time.Hour), time.Hour), "")
+		require.NoError(t, err)
+
+		vacation := newEvent(wednesday, 48*time.Hour)
+		vacation.Kind = storage.KindOutOfOffice
+		_, err = a.CreateEvent(ctx, vacation, "")
+		require.NoError(t, err)
+		_, err = a.CreateEvent(ctx, vacation, "")
+		require.NoError(t, err)
+
+		_, err = a.CreateEvent(ctx, newEvent(wednesday.Add(12*time.Hour), time.Hour), "")
+		require.ErrorIs(t, err, ErrOutOfOffice)
+		other := newEvent(wednesday.Add(12*time.Hour), time.Hour)
+		other.UserID = "bob"
+		_, err = a.CreateEvent(ctx, other, "")
+		require.NoError(t, err)
+
+		// Events already planned may be changed but not moved into the period.
+		standup.Title = "daily"
+		_, err = a.UpdateEvent(ctx, standup.ID, standup)
+		require.NoError(t, err)
+		moved := meeting
+		moved.StartAt, moved.EndAt = wednesday.Add(14*time.Hour), wednesday.Add(15*time.Hour)
+		_, err = a.UpdateEvent(ctx, meeting.ID, moved)
+		require.ErrorIs(t, err, ErrOutOfOffice)
+
+		results, err := a.ApplyBatch(ctx, "alice", []storage.BatchOp{
+			{Kind: storage.ChangeCreated, Event: newEvent(wednesday.Add(16*time.Hour), time.Hour)},
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, results[0].Err, ErrOutOfOffice)
+		// Periods created in the same batch are enforced by the storage.
+		friday := monday.AddDate(0, 0, 4)
+		away := newEvent(friday, 24*time.Hour)
+		away.Kind = storage.KindOutOfOffice
+		results, err = a.ApplyBatch(ctx, "alice", []storage.BatchOp{
+			{Kind: storage.ChangeCreated, Event: away},
+			{Kind: storage.ChangeCreated, Event: newEvent(friday.Add(9*time.Hour), time.Hour)},
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, results[1].Err, ErrOutOfOffice)
+
+		room, err := a.CreateCalendar(ctx, "alice", "Team Room")
+		require.NoError(t, err)
+		shared := vacation
+		shared.CalendarID = room.ID
+		_, err = a.CreateEvent(ctx, shared, "")
+		require.ErrorIs(t, err, ErrInvalidEvent)
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		availability, err := a.GetAvailability(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, storage.Availability{UserID: "alice", TimeZone: "UTC"}, availability)
+
+		_, err = a.SetAvailability(ctx, "alice", storage.Availability{TimeZone: "Mars/Olympus"})
+		require.ErrorIs(t, err, ErrInvalidAvailability)
+		_, err = a.SetAvailability(ctx, "alice", storage.Availability{WorkingHours: []storage.WorkingHours{
+			{Weekday: time.Monday, Start: 9 * time.Hour, End: 13 * time.Hour},
+			{Weekday: time.Monday, Start: 12 * time.Hour, End: 18 * time.Hour},
+		}})
+		require.ErrorIs(t, err, ErrInvalidAvailability)
+		_, err = a.SetAvailability(ctx, "alice", storage.Availability{WorkingHours: []storage.WorkingHours{
+			{Weekday: time.Monday, Start: 18 * time.Hour, End: 9 * time.Hour},
+		}})
+		require.ErrorIs(t, err, ErrInvalidAvailability)
+
+		hours := make([]storage.WorkingHours, 0, 5)
+		for day := time.Friday; day >= time.Monday; day-- {
+			hours = append(hours, storage.WorkingHours{Weekday: day, Start: 9 * time.Hour, End: 18 * time.Hour})
+		}
+		set, err := a.SetAvailability(ctx, "alice", storage.Availability{TimeZone: "Europe/Moscow", WorkingHours: hours})
+		require.NoError(t, err)
+		require.Equal(t, time.Monday, set.WorkingHours[0].Weekday)
+		availability, err = a.GetAvailability(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, set, availability)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		// Moscow is three hours ahead of UTC.
+		slots, err := a.QueryAvailability(ctx, "bob", "alice", monday, monday.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		at := func(hour int) time.Time { return monday.Add(time.Duration(hour) * time.Hour) }
+		require.Equal(t, []Slot{
+			{Start: at(0), End: at(6), Status: SlotOffHours},
+			{Start: at(6), End: at(10), Status: SlotFree},
+			{Start: at(10), End: at(11), Status: SlotBusy},
+			{Start: at(11), End: at(15), Status: SlotFree},
+			{Start: at(15), End: at(24), Status: SlotOffHours},
+		}, slots)
+
+		// Out-of-office periods cover working hours, events and days off.
+		slots, err = a.QueryAvailability(ctx, "alice", "", at(48), at(96))
+		require.NoError(t, err)
+		require.Equal(t, []Slot{{Start: at(48), End: at(96), Status: SlotOutOfOffice}}, slots)
+
+		// Users without settings are always free unless busy.
+		slots, err = a.QueryAvailability(ctx, "alice", "bob", at(48), at(72))
+		require.NoError(t, err)
+		require.Equal(t, []Slot{
+			{Start: at(48), End: at(60), Status: SlotFree},
+			{Start: at(60), End: at(61), Status: SlotBusy},
+			{Start: at(61), End: at(72), Status: SlotFree},
+		}, slots)
+
+		_, err = a.QueryAvailability(ctx, "alice", "bob", at(0), at(0).AddDate(0, 2, 0))
+		require.ErrorIs(t, err, ErrInvalidRange)
+		_, err = a.QueryAvailability(ctx, "", "bob", at(0), at(24))
+		require.ErrorIs(t, err, ErrNoUser)
+	})
+
+	t.Run("daylight saving time", func(t *testing.T) {
+		_, err := a.SetAvailability(ctx, "carol", storage.Availability{
+			TimeZone:     "America/New_York",
+			WorkingHours: []storage.WorkingHours{{Weekday: time.Sunday, Start: time.Hour, End: 4 * time.Hour}},
+		})
+		require.NoError(t, err)
+
+		// Clocks moved from 2:00 EST to 3:00 EDT on March 9, 2025.
+		sunday := monday.AddDate(0, 0, -1)
+		slots, err := a.QueryAvailability(ctx, "carol", "", sunday, monday)
+		require.NoError(t, err)
+		require.Equal(t, []Slot{
+			{Start: sunday, End: sunday.Add(6 * time.Hour), Status: SlotOffHours},
+			{Start: sunday.Add(6 * time.Hour), End: sunday.Add(8 * time.Hour), Status: SlotFree},
+			{Start: sunday.Add(8 * time.Hour), End: monday, Status: SlotOffHours},
+		}, slots)
+	})
+}
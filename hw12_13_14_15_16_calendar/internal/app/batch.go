@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// MaxBatchSize is the maximum number of operations in a batch.
+const MaxBatchSize = 1000
+
+// BatchResult is the outcome of an operation of a batch. Event is the created or updated
+// event; it is set only if the whole batch is applied. Err is nil for operations
+// that succeed, even if the batch is not applied because of other operations.
+type BatchResult struct {
+	Event storage.Event
+	Err   error
+}
+
+// ApplyBatch creates, updates and deletes events on behalf of the user, all or none.
+// Operations are checked as CreateEvent, UpdateEvent and DeleteEvent do and applied
+// in order; an event may be updated or deleted only once per batch. The batch is
+// applied if the errors of all the results are nil, otherwise every failed operation
+// has its error. The returned error is set only if the batch as a whole is rejected.
+func (a *App) ApplyBatch(ctx context.Context, userID string, ops []storage.BatchOp) (_ []BatchResult, err error) {
+	ctx, span := tracer.Start(ctx, "app.ApplyBatch")
+	defer func() { endSpan(span, err) }()
+
+	switch {
+	case userID == "":
+		return nil, ErrNoUser
+	case len(ops) == 0:
+		return nil, fmt.Errorf("%w: no operations", ErrInvalidBatch)
+	case len(ops) > MaxBatchSize:
+		return nil, fmt.Errorf("%w: %d operations, at most %d allowed", ErrInvalidBatch, len(ops), MaxBatchSize)
+	}
+
+	results := make([]BatchResult, len(ops))
+	prepared := make([]storage.BatchOp, len(ops))
+	changed := make(map[string]int, len(ops))
+	failed := false
+	creates := 0
+	for i, op := range ops {
+		if op.Kind == storage.ChangeCreated {
+			creates++
+		} else {
+			if prev, ok := changed[op.Event.ID]; ok {
+				results[i].Err = fmt.Errorf("%w: event %s is already changed by operation %d",
+					ErrInvalidBatch, op.Event.ID, prev)
+				failed = true
+				continue
+			}
+			changed[op.Event.ID] = i
+		}
+		prepared[i], results[i].Err = a.prepareOp(ctx, userID, op)
+		if results[i].Err != nil {
+			failed = true
+		}
+	}
+	if err := a.checkQuota(ctx, userID, creates); err != nil {
+		return nil, err
+	}
+	if failed {
+		// Conflicts of the rest of the operations are reported too,
+		// so that clients can fix the whole batch at once.
+		valid := make([]storage.BatchOp, 0, len(ops))
+		indexes := make([]int, 0, len(ops))
+		for i, op := range prepared {
+			if results[i].Err == nil {
+				valid = append(valid, op)
+				indexes = append(indexes, i)
+			}
+		}
+		errs, err := a.storage.CheckBatch(ctx, valid)
+		if err != nil {
+			return nil, err
+		}
+		for j, err := range errs {
+			results[indexes[j]].Err = err
+		}
+		return results, nil
+	}
+
+	deleted := make([]string, 0)
+	for _, op := range prepared {
+		if op.Kind == storage.ChangeDeleted {
+			deleted = append(deleted, op.Event.ID)
+		}
+	}
+	attachments := make([]storage.Attachment, 0)
+	if len(deleted) > 0 {
+		if attachments, err = a.storage.ListAttachments(ctx, deleted); err != nil {
+			return nil, err
+		}
+	}
+
+	errs, err := a.storage.ApplyBatch(ctx, prepared)
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		if err != nil {
+			results[i].Err = err
+			failed = true
+		}
+	}
+	if failed {
+		return results, nil
+	}
+	a.deleteBlobs(ctx, attachments)
+
+	for i, op := range prepared {
+		event := op.Event
+		if op.Kind == storage.ChangeUpdated {
+			event.Version++
+		}
+		if op.Kind != storage.ChangeDeleted {
+			results[i].Event = event
+		}
+		a.feed.Publish(op.Kind, event)
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("batch of %d operations applied by user %s", len(ops), userID))
+	return results, nil
+}
+
+// prepareOp checks the operation and completes its event as it is to be stored.
+func (a *App) prepareOp(ctx context.Context, userID string, op storage.BatchOp) (storage.BatchOp, error) {
+	event := op.Event
+	event.UserID = userID
+	event.Tags = normalizeTags(event.Tags)
+	switch op.Kind {
+	case storage.ChangeCreated:
+		if err := validateEvent(event); err != nil {
+			return op, err
+		}
+		if event.CalendarID != "" {
+			if err := a.checkRole(ctx, userID, event.CalendarID, storage.Role.CanWrite); err != nil {
+				return op, err
+			}
+		}
+		if err := a.checkCategory(ctx, event); err != nil {
+			return op, err
+		}
+		if err := a.checkOutOfOffice(ctx, event); err != nil {
+			return op, err
+		}
+		event.ID = uuid.NewString()
+		event.Version = 1
+	case storage.ChangeUpdated:
+		if err := validateEvent(event); err != nil {
+			return op, err
+		}
+		current, err := a.writableEvent(ctx, userID, event.ID)
+		if err != nil {
+			return op, err
+		}
+		if event.Version == 0 {
+			event.Version = current.Version
+		}
+		event.UserID = current.UserID
+		event.CalendarID = current.CalendarID
+		if err := a.checkUpdatedEvent(ctx, current, event); err != nil {
+			return op, err
+		}
+		if err := a.checkCategory(ctx, event); err != nil {
+			return op, err
+		}
+	case storage.ChangeDeleted:
+		current, err := a.writableEvent(ctx, userID, event.ID)
+		if err != nil {
+			return op, err
+		}
+		event = storage.Event{ID: current.ID, UserID: current.UserID, CalendarID: current.CalendarID}
+	default:
+		return op, fmt.Errorf("%w: unknown operation %q", ErrInvalidBatch, op.Kind)
+	}
+	return storage.BatchOp{Kind: op.Kind, Event: event}, nil
+}
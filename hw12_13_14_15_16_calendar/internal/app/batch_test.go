@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBatch(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	feed := changefeed.New(100)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), feed,
+		blob.NewFS(t.TempDir()), time.Hour, 5, AttachmentLimits{})
+
+	newEvent := func(startAt time.Time) storage.Event {
+		return storage.Event{Title: "call", StartAt: startAt, EndAt: startAt.Add(time.Hour)}
+	}
+	create := func(startAt time.Time) storage.BatchOp {
+		return storage.BatchOp{Kind: storage.ChangeCreated, Event: newEvent(startAt)}
+	}
+	review, err := a.CreateEvent(ctx, storage.Event{
+		Title: "review", UserID: "alice", StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+
+	t.Run("rejected", func(t *testing.T) {
+		_, err := a.ApplyBatch(ctx, "", []storage.BatchOp{create(start)})
+		require.ErrorIs(t, err, ErrNoUser)
+		_, err = a.ApplyBatch(ctx, "alice", nil)
+		require.ErrorIs(t, err, ErrInvalidBatch)
+		_, err = a.ApplyBatch(ctx, "alice", make([]storage.BatchOp, MaxBatchSize+1))
+		require.ErrorIs(t, err, ErrInvalidBatch)
+
+		ops := make([]storage.BatchOp, 0, 5)
+		for i := 1; i <= 5; i++ {
+			ops = append(ops, create(start.Add(time.Duration(i)*time.Hour)))
+		}
+		_, err = a.ApplyBatch(ctx, "alice", ops)
+		require.ErrorIs(t, err, ErrQuotaExceeded)
+	})
+
+	t.Run("failed operations", func(t *testing.T) {
+		update := review
+		update.Title = "code review"
+		results, err := a.ApplyBatch(ctx, "alice", []storage.BatchOp{
+			{Kind: storage.ChangeUpdated, Event: update},
+			{Kind: storage.ChangeDeleted, Event: storage.Event{ID: review.ID}},
+			create(start.Add(2 * time.Hour)),
+			create(start.Add(150 * time.Minute)),
+			{Kind: storage.ChangeCreated, Event: storage.Event{Title: "empty"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		require.NoError(t, results[0].Err)
+		require.ErrorIs(t, results[1].Err, ErrInvalidBatch)
+		require.NoError(t, results[2].Err)
+		require.ErrorIs(t, results[3].Err, storage.ErrDateBusy)
+		require.ErrorIs(t, results[4].Err, ErrInvalidEvent)
+		require.Empty(t, results[0].Event.ID)
+
+		events, err := a.ListDayEvents(ctx, "alice", nil, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, review, events[0])
+
+		results, err = a.ApplyBatch(ctx, "bob", []storage.BatchOp{
+			{Kind: storage.ChangeDeleted, Event: storage.Event{ID: review.ID}},
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, results[0].Err, storage.ErrEventNotFound)
+	})
+
+	t.Run("applied", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		changes, err := feed.Subscribe(ctx, 0)
+		require.NoError(t, err)
+
+		update := review
+		update.Title = "code review"
+		results, err := a.ApplyBatch(ctx, "alice", []storage.BatchOp{
+			{Kind: storage.ChangeUpdated, Event: update},
+			create(start.Add(2 * time.Hour)),
+			create(start.Add(3 * time.Hour)),
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		for _, result := range results {
+			require.NoError(t, result.Err)
+			require.Equal(t, "alice", result.Event.UserID)
+		}
+		require.Equal(t, int64(2), results[0].Event.Version)
+		require.Equal(t, int64(1), results[1].Event.Version)
+
+		events, err := a.ListDayEvents(ctx, "alice", nil, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{results[0].Event, results[1].Event, results[2].Event}, events)
+
+		for _, kind := range []storage.ChangeKind{storage.ChangeUpdated, storage.ChangeCreated, storage.ChangeCreated} {
+			change := <-changes
+			require.Equal(t, kind, change.Kind)
+		}
+	})
+}
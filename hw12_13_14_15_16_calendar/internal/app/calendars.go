@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// CreateCalendar creates a shared calendar owned by the user.
+func (a *App) CreateCalendar(ctx context.Context, userID, name string) (_ storage.UserCalendar, err error) {
+	ctx, span := tracer.Start(ctx, "app.CreateCalendar")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return storage.UserCalendar{}, ErrNoUser
+	}
+	if name == "" {
+		return storage.UserCalendar{}, fmt.Errorf("%w: name is required", ErrInvalidCalendar)
+	}
+	calendar := storage.Calendar{ID: uuid.NewString(), Name: name}
+	if err := a.storage.CreateCalendar(ctx, calendar, userID); err != nil {
+		return storage.UserCalendar{}, err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("calendar %s created by user %s", calendar.ID, userID))
+	return storage.UserCalendar{Calendar: calendar, Role: storage.RoleOwner}, nil
+}
+
+// ListCalendars returns the calendars the user is a member of.
+func (a *App) ListCalendars(ctx context.Context, userID string) ([]storage.UserCalendar, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListUserCalendars(ctx, userID)
+}
+
+// DeleteCalendar deletes the calendar with all its events. Only owners may delete a calendar.
+// Contents of attachments of the events are deleted by SweepBlobs.
+func (a *App) DeleteCalendar(ctx context.Context, userID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "app.DeleteCalendar")
+	defer func() { endSpan(span, err) }()
+
+	if err := a.checkRole(ctx, userID, id, storage.Role.CanManage); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteCalendar(ctx, id); err != nil {
+		return err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("calendar %s deleted by user %s", id, userID))
+	return nil
+}
+
+// ShareCalendar grants member.UserID the role in the calendar, replacing a previous one.
+// Only owners may share a calendar, and a calendar always keeps at least one owner.
+func (a *App) ShareCalendar(ctx context.Context, userID string, member storage.Member) (err error) {
+	ctx, span := tracer.Start(ctx, "app.ShareCalendar")
+	defer func() { endSpan(span, err) }()
+
+	switch {
+	case member.UserID == "":
+		return fmt.Errorf("%w: member user id is required", ErrInvalidCalendar)
+	case !member.Role.Valid():
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidCalendar, member.Role)
+	}
+	if err := a.checkRole(ctx, userID, member.CalendarID, storage.Role.CanManage); err != nil {
+		return err
+	}
+	if member.Role != storage.RoleOwner {
+		if err := a.checkOtherOwner(ctx, member.CalendarID, member.UserID); err != nil {
+			return err
+		}
+	}
+	if err := a.storage.SetMember(ctx, member); err != nil {
+		return err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("calendar %s shared with user %s as %s by user %s",
+		member.CalendarID, member.UserID, member.Role, userID))
+	return nil
+}
+
+// UnshareCalendar removes memberID from the calendar. Owners may remove anyone,
+// other members may only leave the calendar themselves.
+func (a *App) UnshareCalendar(ctx context.Context, userID, calendarID, memberID string) (err error) {
+	ctx, span := tracer.Start(ctx, "app.UnshareCalendar")
+	defer func() { endSpan(span, err) }()
+
+	if userID != memberID {
+		if err := a.checkRole(ctx, userID, calendarID, storage.Role.CanManage); err != nil {
+			return err
+		}
+	}
+	if err := a.checkOtherOwner(ctx, calendarID, memberID); err != nil {
+		return err
+	}
+	if err := a.storage.RemoveMember(ctx, calendarID, memberID); err != nil {
+		return err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("user %s removed from calendar %s by user %s", memberID, calendarID, userID))
+	return nil
+}
+
+// ListMembers returns the members of a calendar to those who see its events.
+func (a *App) ListMembers(ctx context.Context, userID, calendarID string) ([]storage.Member, error) {
+	if err := a.checkRole(ctx, userID, calendarID, storage.Role.CanRead); err != nil {
+		return nil, err
+	}
+	return a.storage.ListMembers(ctx, calendarID)
+}
+
+// role returns the role of the user in the calendar. Calendars the user
+// is not a member of are reported as not found.
+func (a *App) role(ctx context.Context, userID, calendarID string) (storage.Role, error) {
+	if userID == "" {
+		return "", ErrNoUser
+	}
+	member, err := a.storage.GetMember(ctx, calendarID, userID)
+	if errors.Is(err, storage.ErrMemberNotFound) {
+		return "", storage.ErrCalendarNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+func (a *App) checkRole(ctx context.Context, userID, calendarID string, allowed func(storage.Role) bool) error {
+	role, err := a.role(ctx, userID, calendarID)
+	if err != nil {
+		return err
+	}
+	if !allowed(role) {
+		return fmt.Errorf("%w: not allowed for %s", ErrForbidden, role)
+	}
+	return nil
+}
+
+// checkOtherOwner fails if userID is the only owner of the calendar.
+func (a *App) checkOtherOwner(ctx context.Context, calendarID, userID string) error {
+	members, err := a.storage.ListMembers(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Role == storage.RoleOwner && m.UserID != userID {
+			return nil
+		}
+	}
+	for _, m := range members {
+		if m.UserID == userID && m.Role == storage.RoleOwner {
+			return fmt.Errorf("%w: calendar must keep an owner", ErrInvalidCalendar)
+		}
+	}
+	return nil
+}
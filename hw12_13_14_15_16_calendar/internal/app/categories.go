@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxTags is how many tags an event may have.
+	MaxTags = 20
+	// MaxTagLength is the longest tag in characters, category names have the same limit.
+	MaxTagLength = 50
+)
+
+var (
+	ErrInvalidCategory = errors.New("invalid category")
+
+	colorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+)
+
+// EventFilter selects listed events. Events match if they belong to any of the categories
+// and have all the tags, tags are compared regardless of case. An empty filter matches all events.
+type EventFilter struct {
+	CategoryIDs []string
+	Tags        []string
+}
+
+// Match reports whether the event passes the filter.
+func (f EventFilter) Match(event storage.Event) bool {
+	if len(f.CategoryIDs) > 0 && !slices.Contains(f.CategoryIDs, event.CategoryID) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsFold(event.Tags, strings.TrimSpace(tag)) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateCategory creates a category of the user.
+func (a *App) CreateCategory(
+	ctx context.Context, userID string, category storage.Category,
+) (_ storage.Category, err error) {
+	ctx, span := tracer.Start(ctx, "app.CreateCategory")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return storage.Category{}, ErrNoUser
+	}
+	category, err = normalizeCategory(category)
+	if err != nil {
+		return storage.Category{}, err
+	}
+	category.ID = uuid.NewString()
+	category.UserID = userID
+	if err := a.storage.CreateCategory(ctx, category); err != nil {
+		return storage.Category{}, err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("category %s created by user %s", category.ID, userID))
+	return category, nil
+}
+
+// ListCategories returns the user's categories ordered by name.
+func (a *App) ListCategories(ctx context.Context, userID string) ([]storage.Category, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListCategories(ctx, userID)
+}
+
+// UpdateCategory renames or recolors a category of the user.
+func (a *App) UpdateCategory(
+	ctx context.Context, userID string, category storage.Category,
+) (_ storage.Category, err error) {
+	ctx, span := tracer.Start(ctx, "app.UpdateCategory")
+	defer func() { endSpan(span, err) }()
+
+	if _, err := a.userCategory(ctx, userID, category.ID); err != nil {
+		return storage.Category{}, err
+	}
+	category, err = normalizeCategory(category)
+	if err != nil {
+		return storage.Category{}, err
+	}
+	category.UserID = userID
+	if err := a.storage.UpdateCategory(ctx, category); err != nil {
+		return storage.Category{}, err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("category %s updated by user %s", category.ID, userID))
+	return category, nil
+}
+
+// DeleteCategory deletes a category of the user, its events are left uncategorized.
+func (a *App) DeleteCategory(ctx context.Context, userID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "app.DeleteCategory")
+	defer func() { endSpan(span, err) }()
+
+	if _, err := a.userCategory(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteCategory(ctx, id); err != nil {
+		return err
+	}
+	a.logger.DebugContext(ctx, fmt.Sprintf("category %s deleted by user %s", id, userID))
+	return nil
+}
+
+// userCategory loads a category of the user, categories of other users are not found.
+func (a *App) userCategory(ctx context.Context, userID, id string) (storage.Category, error) {
+	if userID == "" {
+		return storage.Category{}, ErrNoUser
+	}
+	category, err := a.storage.GetCategory(ctx, id)
+	if err != nil {
+		return storage.Category{}, err
+	}
+	if category.UserID != userID {
+		return storage.Category{}, storage.ErrCategoryNotFound
+	}
+	return category, nil
+}
+
+// checkCategory checks that the category of the event belongs to the user who owns the event,
+// so that editors of shared calendars keep the categories of the events they change.
+func (a *App) checkCategory(ctx context.Context, event storage.Event) error {
+	if event.CategoryID == "" {
+		return nil
+	}
+	_, err := a.userCategory(ctx, event.UserID, event.CategoryID)
+	return err
+}
+
+// normalizeCategory trims the name and lowers the color of the category.
+func normalizeCategory(category storage.Category) (storage.Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	category.Color = strings.ToLower(strings.TrimSpace(category.Color))
+	switch {
+	case category.Name == "":
+		return category, fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	case utf8.RuneCountInString(category.Name) > MaxTagLength:
+		return category, fmt.Errorf("%w: name is longer than %d characters", ErrInvalidCategory, MaxTagLength)
+	case category.Color != "" && !colorPattern.MatchString(category.Color):
+		return category, fmt.Errorf("%w: color must be like #1e90ff", ErrInvalidCategory)
+	}
+	return category, nil
+}
+
+// normalizeTags trims the tags and drops empty ones and repeats differing in case only,
+// the first spelling of a tag is kept.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsFold(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func validateTags(tags []string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("%w: more than %d tags", ErrInvalidEvent, MaxTags)
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidEvent, tag, MaxTagLength)
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
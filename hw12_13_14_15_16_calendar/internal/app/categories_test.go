@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategories(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	travel, err := a.CreateCategory(ctx, "alice", storage.Category{Name: " Travel ", Color: "#1E90FF"})
+	require.NoError(t, err)
+	require.Equal(t, storage.Category{ID: travel.ID, UserID: "alice", Name: "Travel", Color: "#1e90ff"}, travel)
+	work, err := a.CreateCategory(ctx, "alice", storage.Category{Name: "Work"})
+	require.NoError(t, err)
+	bobs, err := a.CreateCategory(ctx, "bob", storage.Category{Name: "Travel"})
+	require.NoError(t, err)
+
+	newEvent := func(hour int, categoryID string, tags ...string) storage.Event {
+		start := day.Add(time.Duration(hour) * time.Hour)
+		return storage.Event{
+			Title: "event", UserID: "alice", StartAt: start, EndAt: start.Add(time.Hour),
+			CategoryID: categoryID, Tags: tags,
+		}
+	}
+
+	t.Run("manage", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			category storage.Category
+			err      error
+		}{
+			{name: "no name", category: storage.Category{Name: " "}, err: ErrInvalidCategory},
+			{name: "long name", category: storage.Category{Name: strings.Repeat("x", MaxTagLength+1)}, err: ErrInvalidCategory},
+			{name: "bad color", category: storage.Category{Name: "Oncall", Color: "red"}, err: ErrInvalidCategory},
+			{name: "taken name", category: storage.Category{Name: "travel"}, err: storage.ErrCategoryExists},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := a.CreateCategory(ctx, "alice", tc.category)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+
+		_, err := a.UpdateCategory(ctx, "alice", storage.Category{ID: bobs.ID, Name: "Mine"})
+		require.ErrorIs(t, err, storage.ErrCategoryNotFound)
+		require.ErrorIs(t, a.DeleteCategory(ctx, "alice", bobs.ID), storage.ErrCategoryNotFound)
+		work, err = a.UpdateCategory(ctx, "alice", storage.Category{ID: work.ID, Name: "Work", Color: "#aa0000"})
+		require.NoError(t, err)
+
+		categories, err := a.ListCategories(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Category{travel, work}, categories)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		_, err := a.CreateEvent(ctx, newEvent(8, bobs.ID), "")
+		require.ErrorIs(t, err, storage.ErrCategoryNotFound)
+		tooMany := make([]string, 0, MaxTags+1)
+		for i := 0; i <= MaxTags; i++ {
+			tooMany = append(tooMany, strings.Repeat("x", i+1))
+		}
+		_, err = a.CreateEvent(ctx, newEvent(8, "", tooMany...), "")
+		require.ErrorIs(t, err, ErrInvalidEvent)
+
+		event, err := a.CreateEvent(ctx, newEvent(8, travel.ID, " 1:1", "oncall", "", "OnCall"), "")
+		require.NoError(t, err)
+		require.Equal(t, []string{"1:1", "oncall"}, event.Tags)
+
+		event.CategoryID = bobs.ID
+		_, err = a.UpdateEvent(ctx, event.ID, event)
+		require.ErrorIs(t, err, storage.ErrCategoryNotFound)
+		results, err := a.ApplyBatch(ctx, "alice", []storage.BatchOp{
+			{Kind: storage.ChangeCreated, Event: newEvent(9, bobs.ID)},
+		})
+		require.NoError(t, err)
+		require.ErrorIs(t, results[0].Err, storage.ErrCategoryNotFound)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		_, err := a.CreateEvent(ctx, newEvent(10, work.ID, "oncall"), "")
+		require.NoError(t, err)
+		_, err = a.CreateEvent(ctx, newEvent(11, work.ID), "")
+		require.NoError(t, err)
+		_, err = a.CreateEvent(ctx, newEvent(12, ""), "")
+		require.NoError(t, err)
+
+		tests := []struct {
+			name   string
+			filter EventFilter
+			hours  []int
+		}{
+			{name: "none", filter: EventFilter{}, hours: []int{8, 10, 11, 12}},
+			{name: "category", filter: EventFilter{CategoryIDs: []string{work.ID}}, hours: []int{10, 11}},
+			{name: "any category", filter: EventFilter{CategoryIDs: []string{work.ID, travel.ID}}, hours: []int{8, 10, 11}},
+			{name: "tag", filter: EventFilter{Tags: []string{"ONCALL"}}, hours: []int{8, 10}},
+			{name: "all tags", filter: EventFilter{Tags: []string{"oncall", "1:1"}}, hours: []int{8}},
+			{
+				name:   "category and tag",
+				filter: EventFilter{CategoryIDs: []string{work.ID}, Tags: []string{"oncall"}},
+				hours:  []int{10},
+			},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				events, err := a.ListDayEvents(ctx, "alice", nil, tc.filter, day)
+				require.NoError(t, err)
+				hours := make([]int, 0, len(events))
+				for _, event := range events {
+					hours = append(hours, event.StartAt.Hour())
+				}
+				require.Equal(t, tc.hours, hours)
+			})
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		exported, err := a.ExportEvents(ctx, "alice", nil, EventFilter{Tags: []string{"1:1"}}, day, day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, exported, 1)
+		require.Equal(t, travel, exported[0].Category)
+
+		require.NoError(t, a.DeleteCategory(ctx, "alice", travel.ID))
+		events, err := a.ListDayEvents(ctx, "alice", nil, EventFilter{CategoryIDs: []string{travel.ID}}, day)
+		require.NoError(t, err)
+		require.Empty(t, events)
+		events, err = a.ListDayEvents(ctx, "alice", nil, EventFilter{Tags: []string{"1:1"}}, day)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Empty(t, events[0].CategoryID)
+	})
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxExportRange is the longest time range of an export.
+const MaxExportRange = 366 * 24 * time.Hour
+
+var ErrInvalidRange = errors.New("invalid time range")
+
+// ExportedEvent is an event with its category and the files attached to it.
+type ExportedEvent struct {
+	storage.Event
+	// Category is the category of the event, zero if it has none.
+	Category    storage.Category
+	Attachments []storage.Attachment
+}
+
+// ExportEvents returns events starting in [from, to) as ListDayEvents does,
+// along with their categories and attachments. Events of free/busy calendars
+// come without attachments.
+func (a *App) ExportEvents(
+	ctx context.Context, userID string, calendarIDs []string, filter EventFilter, from, to time.Time,
+) (_ []ExportedEvent, err error) {
+	ctx, span := tracer.Start(ctx, "app.ExportEvents")
+	defer func() { endSpan(span, err) }()
+
+	switch {
+	case !to.After(from):
+		return nil, fmt.Errorf("%w: range must end after it starts", ErrInvalidRange)
+	case to.Sub(from) > MaxExportRange:
+		return nil, fmt.Errorf("%w: range is longer than %s", ErrInvalidRange, MaxExportRange)
+	}
+	events, err := a.listEvents(ctx, userID, calendarIDs, filter, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		if !isBusyEvent(event) {
+			ids = append(ids, event.ID)
+		}
+	}
+	byEvent := make(map[string][]storage.Attachment, len(ids))
+	if len(ids) > 0 {
+		attachments, err := a.storage.ListAttachments(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, attachment := range attachments {
+			byEvent[attachment.EventID] = append(byEvent[attachment.EventID], attachment)
+		}
+	}
+
+	categories := make(map[string]storage.Category)
+	for _, event := range events {
+		if _, ok := categories[event.CategoryID]; ok || event.CategoryID == "" {
+			continue
+		}
+		category, err := a.storage.GetCategory(ctx, event.CategoryID)
+		// The category may have been deleted since the events were listed.
+		if err != nil && !errors.Is(err, storage.ErrCategoryNotFound) {
+			return nil, err
+		}
+		categories[event.CategoryID] = category
+	}
+
+	exported := make([]ExportedEvent, 0, len(events))
+	for _, event := range events {
+		exported = append(exported, ExportedEvent{
+			Event:       event,
+			Category:    categories[event.CategoryID],
+			Attachments: byEvent[event.ID],
+		})
+	}
+	return exported, nil
+}
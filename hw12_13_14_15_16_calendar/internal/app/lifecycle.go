@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrStopTimeout is returned by Lifecycle.Run when components did not stop before the deadline.
+var ErrStopTimeout = errors.New("components did not stop in time")
+
+// Component is a part of the service run by Lifecycle, e.g. a server, a storage or
+// a background worker. All its funcs are optional.
+type Component struct {
+	Name string
+	// Start prepares the component, e.g. connects to a database.
+	Start func(ctx context.Context) error
+	// Run serves in the background after Start until Stop is called or its context
+	// is canceled. An error returned before the shutdown stops the service.
+	Run func(ctx context.Context) error
+	// Stop stops the component, finishing the work in progress until ctx is done.
+	Stop func(ctx context.Context) error
+}
+
+// Lifecycle starts components in the order they are added and stops them in reverse order,
+// so that a component may rely on those added before it.
+type Lifecycle struct {
+	logger     Logger
+	timeout    time.Duration
+	components []Component
+}
+
+// NewLifecycle returns a Lifecycle that gives the components timeout to stop, in total.
+func NewLifecycle(logger Logger, timeout time.Duration) *Lifecycle {
+	return &Lifecycle{logger: logger, timeout: timeout}
+}
+
+// Add adds components started after those added before.
+func (l *Lifecycle) Add(components ...Component) {
+	l.components = append(l.components, components...)
+}
+
+type runningComponent struct {
+	Component
+	cancel context.CancelFunc
+	// done is closed when Run returns, nil if there is no Run.
+	done chan struct{}
+}
+
+// Run starts the components and waits until ctx is done, a component fails to start or
+// its Run fails. Then the started components are stopped in reverse order: Stop is called,
+// the context of Run is canceled and Run is waited for. Components left when the timeout
+// is over are not waited for and are reported by an error wrapping ErrStopTimeout.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	failed := make(chan error, len(l.components))
+	started := make([]runningComponent, 0, len(l.components))
+	var err error
+	for _, c := range l.components {
+		if c.Start != nil {
+			if err = c.Start(ctx); err != nil {
+				err = fmt.Errorf("failed to start %s: %w", c.Name, err)
+				break
+			}
+		}
+		running := runningComponent{Component: c}
+		if c.Run != nil {
+			var runCtx context.Context
+			runCtx, running.cancel = context.WithCancel(context.WithoutCancel(ctx))
+			running.done = make(chan struct{})
+			go func() {
+				defer close(running.done)
+				if err := c.Run(runCtx); err != nil {
+					failed <- fmt.Errorf("%s failed: %w", c.Name, err)
+				}
+			}()
+		}
+		started = append(started, running)
+		l.logger.DebugContext(ctx, c.Name+" started")
+	}
+	if err == nil {
+		select {
+		case <-ctx.Done():
+		case err = <-failed:
+		}
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.timeout)
+	defer cancel()
+	errs := []error{err}
+	var late []string
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		stopped, err := stopComponent(stopCtx, c)
+		switch {
+		case !stopped:
+			l.logger.ErrorContext(ctx, c.Name+" did not stop in time")
+			late = append(late, c.Name)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, err))
+		default:
+			l.logger.DebugContext(ctx, c.Name+" stopped")
+		}
+	}
+	if len(late) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrStopTimeout, strings.Join(late, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// stopComponent reports whether the component stopped before ctx is done.
+func stopComponent(ctx context.Context, c runningComponent) (bool, error) {
+	result := make(chan error, 1)
+	go func() {
+		var err error
+		if c.Stop != nil {
+			err = c.Stop(ctx)
+		}
+		if c.cancel != nil {
+			c.cancel()
+			<-c.done
+		}
+		result <- err
+	}()
+
+	if ctx.Err() != nil {
+		return false, nil
+	}
+	select {
+	case err := <-result:
+		// Stop gives up draining when ctx is done.
+		if err != nil && ctx.Err() != nil {
+			return false, nil
+		}
+		return true, err
+	case <-ctx.Done():
+		return false, nil
+	}
+}
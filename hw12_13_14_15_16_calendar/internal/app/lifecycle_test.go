@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	logg := logger.NewWithWriter("error", io.Discard)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	// newComponent returns a component that records its calls, workers run until canceled
+	// and servers until stopped.
+	newComponent := func(name string, server bool) Component {
+		stopped := make(chan struct{})
+		c := Component{
+			Name: name,
+			Start: func(context.Context) error {
+				record("start " + name)
+				return nil
+			},
+			Run: func(ctx context.Context) error {
+				if server {
+					<-stopped
+				} else {
+					<-ctx.Done()
+				}
+				record("done " + name)
+				return nil
+			},
+		}
+		if server {
+			c.Stop = func(context.Context) error {
+				record("stop " + name)
+				close(stopped)
+				return nil
+			}
+		}
+		return c
+	}
+
+	t.Run("start in order and stop in reverse", func(t *testing.T) {
+		calls = nil
+		l := NewLifecycle(logg, time.Second)
+		l.Add(newComponent("storage", false), newComponent("worker", false), newComponent("http", true))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- l.Run(ctx) }()
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(calls) == 3
+		}, time.Second, time.Millisecond)
+		cancel()
+
+		require.NoError(t, <-done)
+		require.Equal(t, []string{
+			"start storage", "start worker", "start http",
+			"stop http", "done http", "done worker", "done storage",
+		}, calls)
+	})
+
+	t.Run("start failure", func(t *testing.T) {
+		calls = nil
+		errConnect := errors.New("connection refused")
+		l := NewLifecycle(logg, time.Second)
+		failing := Component{Name: "storage", Start: func(context.Context) error { return errConnect }}
+		l.Add(newComponent("metrics", true), failing, newComponent("http", true))
+
+		err := l.Run(context.Background())
+		require.ErrorIs(t, err, errConnect)
+		require.EqualError(t, err, "failed to start storage: connection refused")
+		require.Equal(t, []string{"start metrics", "stop metrics", "done metrics"}, calls)
+	})
+
+	t.Run("run failure", func(t *testing.T) {
+		calls = nil
+		errListen := errors.New("address already in use")
+		l := NewLifecycle(logg, time.Second)
+		failing := Component{Name: "grpc", Run: func(context.Context) error { return errListen }}
+		l.Add(newComponent("worker", false), failing)
+
+		err := l.Run(context.Background())
+		require.ErrorIs(t, err, errListen)
+		require.Equal(t, []string{"start worker", "done worker"}, calls)
+	})
+
+	t.Run("stop timeout", func(t *testing.T) {
+		l := NewLifecycle(logg, 50*time.Millisecond)
+		// The server drains until the deadline and the worker ignores cancellation.
+		draining := Component{Name: "http", Stop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+		stuck := make(chan struct{})
+		t.Cleanup(func() { close(stuck) })
+		ignoring := Component{Name: "worker", Run: func(context.Context) error {
+			<-stuck
+			return nil
+		}}
+		// Components left after the deadline are not waited for.
+		l.Add(newComponent("storage", true), ignoring, draining)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		started := time.Now()
+		err := l.Run(ctx)
+		require.Less(t, time.Since(started), time.Second)
+		require.ErrorIs(t, err, ErrStopTimeout)
+		require.ErrorContains(t, err, "components did not stop in time: http, worker, storage")
+	})
+
+	t.Run("stop errors", func(t *testing.T) {
+		errClose := errors.New("close failed")
+		l := NewLifecycle(logg, time.Second)
+		l.Add(Component{Name: "storage", Stop: func(context.Context) error { return errClose }})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := l.Run(ctx)
+		require.ErrorIs(t, err, errClose)
+		require.NotErrorIs(t, err, ErrStopTimeout)
+		require.EqualError(t, err, "failed to stop storage: close failed")
+	})
+}
@@ -0,0 +1,637 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Quick-add plans occurrences of phrases like "every Monday" as separate events, there is no
+// recurrence rule: DefaultWeeklyOccurrences of them unless the caller asks for another number,
+// at most MaxWeeklyOccurrences.
+const (
+	DefaultWeeklyOccurrences = 10
+	MaxWeeklyOccurrences     = 52
+)
+
+// defaultDuration is the duration of events whose phrases give a start time only.
+const defaultDuration = time.Hour
+
+var ErrInvalidQuickAdd = errors.New("cannot understand the phrase")
+
+// QuickAddDraft is an event draft parsed from a phrase like "lunch with Ana tomorrow 1pm for 45m".
+type QuickAddDraft struct {
+	Title   string
+	StartAt time.Time
+	EndAt   time.Time
+	// AllDay is set for phrases without a time, the event takes whole days.
+	AllDay bool
+	// Weekly is set for phrases like "every Monday", the event repeats every week.
+	Weekly bool
+	// Occurrences is how many occurrences of a weekly event are planned, the series ends
+	// after them. Zero means DefaultWeeklyOccurrences.
+	Occurrences int
+}
+
+// Events returns the events of the draft: the event itself or its next Occurrences
+// occurrences if it repeats. The occurrences are not linked to each other.
+func (q QuickAddDraft) Events() []storage.Event {
+	n := 1
+	if q.Weekly {
+		n = q.Occurrences
+		if n == 0 {
+			n = DefaultWeeklyOccurrences
+		}
+	}
+	events := make([]storage.Event, 0, n)
+	for week := 0; week < n; week++ {
+		events = append(events, storage.Event{
+			Title:   q.Title,
+			StartAt: q.StartAt.AddDate(0, 0, 7*week),
+			EndAt:   q.EndAt.AddDate(0, 0, 7*week),
+		})
+	}
+	return events
+}
+
+// QuickAdd parses the phrase into events in the user's time zone, see ParseQuickAdd.
+// Weekly events get the given number of occurrences, DefaultWeeklyOccurrences if it is zero;
+// draft.Occurrences tells how many are planned. The events are created in the calendar,
+// a personal one if calendarID is empty, only if create is set; repeated events are created
+// all or none.
+func (a *App) QuickAdd(
+	ctx context.Context, userID, calendarID, text string, occurrences int, create bool,
+) (_ QuickAddDraft, _ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "app.QuickAdd")
+	defer func() { endSpan(span, err) }()
+
+	if occurrences < 0 || occurrences > MaxWeeklyOccurrences {
+		return QuickAddDraft{}, nil, fmt.Errorf("%w: at most %d occurrences are planned",
+			ErrInvalidQuickAdd, MaxWeeklyOccurrences)
+	}
+	availability, err := a.GetAvailability(ctx, userID)
+	if err != nil {
+		return QuickAddDraft{}, nil, err
+	}
+	location, err := time.LoadLocation(availability.TimeZone)
+	if err != nil {
+		return QuickAddDraft{}, nil, err
+	}
+	draft, err := ParseQuickAdd(text, time.Now().In(location))
+	if err != nil {
+		return QuickAddDraft{}, nil, err
+	}
+	if draft.Weekly {
+		draft.Occurrences = cmp.Or(occurrences, DefaultWeeklyOccurrences)
+	}
+	events := draft.Events()
+	for i := range events {
+		events[i].UserID = userID
+		events[i].CalendarID = calendarID
+	}
+	if !create {
+		return draft, events, nil
+	}
+
+	if len(events) == 1 {
+		created, err := a.CreateEvent(ctx, events[0], "")
+		if err != nil {
+			return QuickAddDraft{}, nil, err
+		}
+		return draft, []storage.Event{created}, nil
+	}
+	ops := make([]storage.BatchOp, 0, len(events))
+	for _, event := range events {
+		ops = append(ops, storage.BatchOp{Kind: storage.ChangeCreated, Event: event})
+	}
+	results, err := a.ApplyBatch(ctx, userID, ops)
+	if err != nil {
+		return QuickAddDraft{}, nil, err
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			day := events[i].StartAt.Format(time.DateOnly)
+			return QuickAddDraft{}, nil, fmt.Errorf("occurrence on %s: %w", day, result.Err)
+		}
+		events[i] = result.Event
+	}
+	return draft, events, nil
+}
+
+// ParseQuickAdd parses an English phrase into an event draft relative to now, in its location.
+// Words that tell neither the date nor the time make the title. Understood are:
+//   - dates: "today", "tomorrow", "day after tomorrow", weekdays ("friday", "on fri",
+//     "next friday" that is never today), "in 3 days", "in 2 weeks", "march 15", "15 mar",
+//     "2025-03-15";
+//   - times: "1pm", "at 1:30 pm", "13:00", "noon", "midnight", "in 2 hours",
+//     ranges "1pm-2pm", "1-2pm", "from 10am to 11:30am", and ends "until 5pm";
+//   - durations: "for 45m", "for 1h30m", "for 2 hours", "for an hour", "for 3 days";
+//   - repetition: "every monday", "every week", "weekly".
+//
+// A weekday or a time alone mean their next occurrence. Events without a time take whole
+// days, events without an end or a duration last an hour.
+func ParseQuickAdd(text string, now time.Time) (QuickAddDraft, error) {
+	p := &quickAddParser{now: now}
+	for _, word := range strings.Fields(text) {
+		p.words = append(p.words, word)
+		p.tokens = append(p.tokens, strings.Trim(strings.ToLower(word), ",.!?;:"))
+	}
+	p.used = make([]bool, len(p.words))
+	for i := 0; i < len(p.tokens); {
+		n, err := p.match(i)
+		if err != nil {
+			return QuickAddDraft{}, err
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		for j := i; j < i+n; j++ {
+			p.used[j] = true
+		}
+		i += n
+	}
+	return p.draft()
+}
+
+var (
+	timePattern     = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	timeSpanPattern = regexp.MustCompile(`^(\d{1,2}(?::\d{2})?(?:am|pm)?)-(\d{1,2}(?::\d{2})?(?:am|pm)?)$`)
+	durationPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)(m|min|mins|h|hr|hrs|d)$`)
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+	months = map[string]time.Month{
+		"january": time.January, "jan": time.January,
+		"february": time.February, "feb": time.February,
+		"march": time.March, "mar": time.March,
+		"april": time.April, "apr": time.April,
+		"may":  time.May,
+		"june": time.June, "jun": time.June,
+		"july": time.July, "jul": time.July,
+		"august": time.August, "aug": time.August,
+		"september": time.September, "sep": time.September, "sept": time.September,
+		"october": time.October, "oct": time.October,
+		"november": time.November, "nov": time.November,
+		"december": time.December, "dec": time.December,
+	}
+	durationUnits = map[string]time.Duration{
+		"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+		"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+		"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+		"week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	}
+)
+
+// quickAddParser collects the parts of a phrase. Matchers look at the tokens from
+// a position and return how many they consume.
+type quickAddParser struct {
+	now    time.Time
+	words  []string
+	tokens []string
+	used   []bool
+
+	// date is the day of the event, set along with hasDate.
+	date    time.Time
+	hasDate bool
+	// weekday is set for weekdays, resolved once the time is known.
+	weekday    *time.Weekday
+	nextWeek   bool
+	start, end *time.Duration
+	// startAt is an exact start, e.g. of "in 2 hours".
+	startAt  time.Time
+	duration *quickDuration
+	weekly   bool
+}
+
+// quickDuration keeps days apart, so that they stay days across DST changes.
+type quickDuration struct {
+	days int
+	rest time.Duration
+	// clock is set for durations given in hours or minutes, e.g. "24 hours", as
+	// offsets by them keep the time of the day.
+	clock bool
+}
+
+func (p *quickAddParser) token(i int) string {
+	if i < len(p.tokens) {
+		return p.tokens[i]
+	}
+	return ""
+}
+
+func (p *quickAddParser) match(i int) (int, error) {
+	for _, matcher := range []func(int) (int, error){
+		p.matchRepeat, p.matchDuration, p.matchTimeRange, p.matchTime, p.matchRelative, p.matchDate,
+	} {
+		if n, err := matcher(i); n > 0 || err != nil {
+			return n, err
+		}
+	}
+	return 0, nil
+}
+
+// matchRepeat matches "every monday", "every week" and "weekly".
+func (p *quickAddParser) matchRepeat(i int) (int, error) {
+	switch p.token(i) {
+	case "weekly":
+		p.weekly = true
+		return 1, nil
+	case "every":
+		if p.token(i+1) == "week" {
+			p.weekly = true
+			return 2, nil
+		}
+		if weekday, ok := weekdays[p.token(i+1)]; ok {
+			p.weekly = true
+			return 2, p.setWeekday(weekday, false)
+		}
+	}
+	return 0, nil
+}
+
+// matchDuration matches "for 45m", "for 2 hours" and "for an hour".
+func (p *quickAddParser) matchDuration(i int) (int, error) {
+	if p.token(i) != "for" {
+		return 0, nil
+	}
+	d, n := p.parseDuration(i + 1)
+	if n == 0 {
+		return 0, nil
+	}
+	if p.duration != nil || p.end != nil {
+		return 0, fmt.Errorf("%w: more than one duration or end", ErrInvalidQuickAdd)
+	}
+	p.duration = &d
+	return n + 1, nil
+}
+
+// parseDuration parses "45m", "1h30m", "1.5 hours", "an hour" and "half an hour".
+func (p *quickAddParser) parseDuration(i int) (quickDuration, int) {
+	tok := p.token(i)
+	switch {
+	case tok == "an" || tok == "a":
+		if unit, ok := durationUnits[p.token(i+1)]; ok {
+			return newClockDuration(unit, unit), 2
+		}
+	case tok == "half" && (p.token(i+1) == "an" || p.token(i+1) == "a") && p.token(i+2) == "hour":
+		return newClockDuration(30*time.Minute, time.Minute), 3
+	}
+	if m := durationPattern.FindStringSubmatch(tok); m != nil {
+		return scaledDuration(m[1], durationUnits[m[2]])
+	}
+	if d, err := time.ParseDuration(tok); err == nil && d > 0 {
+		return newClockDuration(d, time.Hour), 1
+	}
+	if unit, ok := durationUnits[p.token(i+1)]; ok {
+		if d, n := scaledDuration(tok, unit); n > 0 {
+			return d, 2
+		}
+	}
+	return quickDuration{}, 0
+}
+
+func scaledDuration(number string, unit time.Duration) (quickDuration, int) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return quickDuration{}, 0
+	}
+	return newClockDuration(time.Duration(value*float64(unit)), unit), 1
+}
+
+func newQuickDuration(d time.Duration) quickDuration {
+	return quickDuration{days: int(d / (24 * time.Hour)), rest: d % (24 * time.Hour)}
+}
+
+func newClockDuration(d time.Duration, unit time.Duration) quickDuration {
+	duration := newQuickDuration(d)
+	duration.clock = unit < 24*time.Hour
+	return duration
+}
+
+// matchTimeRange matches "1pm-2pm", "1-2pm", "1pm - 2pm", "from 10am to 11am" and "until 5pm".
+func (p *quickAddParser) matchTimeRange(i int) (int, error) {
+	if tok := p.token(i); tok == "until" || tok == "till" {
+		end, n := p.parseTime(i+1, false)
+		if n == 0 {
+			return 0, nil
+		}
+		return n + 1, p.setEnd(end)
+	}
+	offset := 0
+	if p.token(i) == "from" {
+		offset = 1
+	}
+	if m := timeSpanPattern.FindStringSubmatch(p.token(i + offset)); m != nil {
+		start, end, ok := parseTimeSpan(m[1], m[2])
+		if !ok {
+			return 0, nil
+		}
+		if err := p.setStart(start); err != nil {
+			return 0, err
+		}
+		return offset + 1, p.setEnd(end)
+	}
+	start, n := p.parseTime(i+offset, offset == 0)
+	if n == 0 {
+		return 0, nil
+	}
+	if sep := p.token(i + offset + n); sep == "-" || sep == "to" || sep == "until" {
+		end, m := p.parseTime(i+offset+n+1, false)
+		if m > 0 {
+			if err := p.setStart(start); err != nil {
+				return 0, err
+			}
+			return offset + n + 1 + m, p.setEnd(end)
+		}
+	}
+	return 0, nil
+}
+
+// matchTime matches "1pm", "at 13:00", "at 1" and "noon".
+func (p *quickAddParser) matchTime(i int) (int, error) {
+	offset := 0
+	if p.token(i) == "at" {
+		offset = 1
+	}
+	start, n := p.parseTime(i+offset, offset == 0)
+	if n == 0 {
+		return 0, nil
+	}
+	return offset + n, p.setStart(start)
+}
+
+// parseTime parses a time of the day. Bare hours like "3" are times only when
+// not strict, e.g. after "at": "3 people" is not a time.
+func (p *quickAddParser) parseTime(i int, strict bool) (time.Duration, int) {
+	tok := p.token(i)
+	switch tok {
+	case "noon", "midday":
+		return 12 * time.Hour, 1
+	case "midnight":
+		return 0, 1
+	}
+	n := 1
+	if next := p.token(i + 1); (next == "am" || next == "pm") && timePattern.MatchString(tok+next) {
+		tok += next
+		n = 2
+	}
+	m := timePattern.FindStringSubmatch(tok)
+	if m == nil || (strict && m[2] == "" && m[3] == "") {
+		return 0, 0
+	}
+	d, ok := clockTime(m[1], m[2], m[3])
+	if !ok {
+		return 0, 0
+	}
+	return d, n
+}
+
+// parseTimeSpan parses the ends of "1-2pm", the start takes the meridiem of the end
+// if it has none and stays before the end.
+func parseTimeSpan(start, end string) (time.Duration, time.Duration, bool) {
+	ms, me := timePattern.FindStringSubmatch(start), timePattern.FindStringSubmatch(end)
+	if ms == nil || me == nil || ms[3] == "" && me[3] == "" && ms[2] == "" && me[2] == "" {
+		return 0, 0, false
+	}
+	endTime, ok := clockTime(me[1], me[2], me[3])
+	if !ok {
+		return 0, 0, false
+	}
+	meridiem := ms[3]
+	if meridiem == "" && me[3] != "" {
+		meridiem = me[3]
+		if t, ok := clockTime(ms[1], ms[2], meridiem); !ok || t > endTime {
+			meridiem = "am"
+		}
+	}
+	startTime, ok := clockTime(ms[1], ms[2], meridiem)
+	return startTime, endTime, ok
+}
+
+func clockTime(hours, minutes, meridiem string) (time.Duration, bool) {
+	h, _ := strconv.Atoi(hours)
+	m := 0
+	if minutes != "" {
+		m, _ = strconv.Atoi(minutes)
+	}
+	switch {
+	case m > 59:
+		return 0, false
+	case meridiem == "" && h > 23:
+		return 0, false
+	case meridiem != "" && (h < 1 || h > 12):
+		return 0, false
+	case meridiem == "am" && h == 12:
+		h = 0
+	case meridiem == "pm" && h < 12:
+		h += 12
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, true
+}
+
+// matchRelative matches "today", "tomorrow", "day after tomorrow", "in 3 days" and "in 2 hours".
+// Offsets in hours or minutes, "in 24 hours" included, start the event at the current time.
+func (p *quickAddParser) matchRelative(i int) (int, error) {
+	today := startOfDay(p.now)
+	switch p.token(i) {
+	case "today":
+		return 1, p.setDate(today)
+	case "tomorrow":
+		return 1, p.setDate(today.AddDate(0, 0, 1))
+	case "day":
+		if p.token(i+1) == "after" && p.token(i+2) == "tomorrow" {
+			return 3, p.setDate(today.AddDate(0, 0, 2))
+		}
+	case "in":
+		d, n := p.parseDuration(i + 1)
+		if n == 0 {
+			return 0, nil
+		}
+		if d.rest == 0 && !d.clock {
+			// Whole days and weeks move the date only, an explicit time may follow.
+			return n + 1, p.setDate(today.AddDate(0, 0, d.days))
+		}
+		if p.hasDate || p.start != nil {
+			return 0, fmt.Errorf("%w: more than one date or time", ErrInvalidQuickAdd)
+		}
+		p.startAt = p.now.AddDate(0, 0, d.days).Add(d.rest).Truncate(time.Minute)
+		p.hasDate = true
+		return n + 1, nil
+	}
+	return 0, nil
+}
+
+// matchDate matches weekdays, "on friday", "next friday", "march 15", "15 mar" and "2025-03-15".
+func (p *quickAddParser) matchDate(i int) (int, error) {
+	offset := 0
+	if p.token(i) == "on" {
+		offset = 1
+	}
+	tok := p.token(i + offset)
+	if tok == "next" || tok == "this" {
+		if weekday, ok := weekdays[p.token(i+offset+1)]; ok {
+			return offset + 2, p.setWeekday(weekday, tok == "next")
+		}
+		return 0, nil
+	}
+	if weekday, ok := weekdays[tok]; ok {
+		return offset + 1, p.setWeekday(weekday, false)
+	}
+	if isoDatePattern.MatchString(tok) {
+		date, err := time.ParseInLocation(time.DateOnly, tok, p.now.Location())
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not a date", ErrInvalidQuickAdd, tok)
+		}
+		return offset + 1, p.setDate(date)
+	}
+	if month, ok := months[tok]; ok {
+		if day, ok := dayOfMonth(p.token(i + offset + 1)); ok {
+			return offset + 2, p.setDate(p.nextDate(month, day))
+		}
+	}
+	if day, ok := dayOfMonth(tok); ok {
+		if month, ok := months[p.token(i+offset+1)]; ok {
+			return offset + 2, p.setDate(p.nextDate(month, day))
+		}
+	}
+	return 0, nil
+}
+
+// dayOfMonth parses "15", "15th", "1st", "2nd" and "3rd".
+func dayOfMonth(tok string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		tok = strings.TrimSuffix(tok, suffix)
+	}
+	day, err := strconv.Atoi(tok)
+	return day, err == nil && day >= 1 && day <= 31
+}
+
+// nextDate returns the day of this year, or of the next one if it has passed.
+func (p *quickAddParser) nextDate(month time.Month, day int) time.Time {
+	date := time.Date(p.now.Year(), month, day, 0, 0, 0, 0, p.now.Location())
+	if date.Before(startOfDay(p.now)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date
+}
+
+func (p *quickAddParser) setDate(date time.Time) error {
+	if p.hasDate {
+		return fmt.Errorf("%w: more than one date", ErrInvalidQuickAdd)
+	}
+	p.date, p.hasDate = date, true
+	return nil
+}
+
+func (p *quickAddParser) setWeekday(weekday time.Weekday, nextWeek bool) error {
+	if p.hasDate {
+		return fmt.Errorf("%w: more than one date", ErrInvalidQuickAdd)
+	}
+	p.weekday, p.nextWeek, p.hasDate = &weekday, nextWeek, true
+	return nil
+}
+
+func (p *quickAddParser) setStart(start time.Duration) error {
+	if p.start != nil || !p.startAt.IsZero() {
+		return fmt.Errorf("%w: more than one time", ErrInvalidQuickAdd)
+	}
+	p.start = &start
+	return nil
+}
+
+func (p *quickAddParser) setEnd(end time.Duration) error {
+	if p.end != nil || p.duration != nil {
+		return fmt.Errorf("%w: more than one duration or end", ErrInvalidQuickAdd)
+	}
+	p.end = &end
+	return nil
+}
+
+// draft resolves the collected parts into the event.
+func (p *quickAddParser) draft() (QuickAddDraft, error) {
+	words := make([]string, 0, len(p.words))
+	for i, word := range p.words {
+		if !p.used[i] {
+			words = append(words, word)
+		}
+	}
+	draft := QuickAddDraft{Title: strings.Trim(strings.Join(words, " "), " ,.;:-"), Weekly: p.weekly}
+	switch {
+	case draft.Title == "":
+		return QuickAddDraft{}, fmt.Errorf("%w: title is required", ErrInvalidQuickAdd)
+	case !p.hasDate && p.start == nil:
+		return QuickAddDraft{}, fmt.Errorf("%w: no date or time", ErrInvalidQuickAdd)
+	case p.start == nil && p.end != nil:
+		return QuickAddDraft{}, fmt.Errorf("%w: end time without a start time", ErrInvalidQuickAdd)
+	}
+
+	switch {
+	case !p.startAt.IsZero():
+		draft.StartAt = p.startAt
+	case p.start == nil:
+		draft.AllDay = true
+		draft.StartAt = p.day(0)
+	default:
+		draft.StartAt = atTime(p.day(*p.start), *p.start)
+	}
+
+	switch {
+	case p.end != nil:
+		draft.EndAt = atTime(startOfDay(draft.StartAt), *p.end)
+		if !draft.EndAt.After(draft.StartAt) {
+			// "10pm-1am" ends the next day.
+			draft.EndAt = atTime(startOfDay(draft.StartAt).AddDate(0, 0, 1), *p.end)
+		}
+	case p.duration != nil:
+		draft.EndAt = draft.StartAt.AddDate(0, 0, p.duration.days).Add(p.duration.rest)
+	case draft.AllDay:
+		draft.EndAt = draft.StartAt.AddDate(0, 0, 1)
+	default:
+		draft.EndAt = draft.StartAt.Add(defaultDuration)
+	}
+	return draft, nil
+}
+
+// day returns the day of the event that starts at the time of the day.
+func (p *quickAddParser) day(start time.Duration) time.Time {
+	today := startOfDay(p.now)
+	switch {
+	case p.weekday != nil:
+		offset := (int(*p.weekday) - int(today.Weekday()) + 7) % 7
+		if p.nextWeek && offset == 0 {
+			offset = 7
+		}
+		if offset == 0 && p.start != nil && !atTime(today, start).After(p.now) {
+			offset = 7
+		}
+		return today.AddDate(0, 0, offset)
+	case p.hasDate:
+		return p.date
+	case !atTime(today, start).After(p.now):
+		// A time alone is its next occurrence.
+		return today.AddDate(0, 0, 1)
+	}
+	return today
+}
+
+// atTime returns the time of the day, normalized by time.Date across DST changes.
+func atTime(day time.Time, d time.Duration) time.Time {
+	year, month, date := day.Date()
+	return time.Date(year, month, date, 0, 0, int(d/time.Second), 0, day.Location())
+}
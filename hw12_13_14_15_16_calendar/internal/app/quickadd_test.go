@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2025, 3, 12, 10, 30, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		input    string
+		expected QuickAddDraft
+	}{
+		{input: "lunch with Ana tomorrow 1pm for 45m", expected: QuickAddDraft{
+			Title: "lunch with Ana", StartAt: at(3, 13, 13, 0), EndAt: at(3, 13, 13, 45),
+		}},
+		{input: "Standup every Monday 9:30am for 15 minutes", expected: QuickAddDraft{
+			Title: "Standup", StartAt: at(3, 17, 9, 30), EndAt: at(3, 17, 9, 45), Weekly: true,
+		}},
+		{input: "Dentist on Friday at 3 pm", expected: QuickAddDraft{
+			Title: "Dentist", StartAt: at(3, 14, 15, 0), EndAt: at(3, 14, 16, 0),
+		}},
+		{input: "call mom at 9am", expected: QuickAddDraft{
+			Title: "call mom", StartAt: at(3, 13, 9, 0), EndAt: at(3, 13, 10, 0),
+		}},
+		{input: "call mom at 11", expected: QuickAddDraft{
+			Title: "call mom", StartAt: at(3, 12, 11, 0), EndAt: at(3, 12, 12, 0),
+		}},
+		{input: "retro wednesday 10am", expected: QuickAddDraft{
+			Title: "retro", StartAt: at(3, 19, 10, 0), EndAt: at(3, 19, 11, 0),
+		}},
+		{input: "next wednesday: review", expected: QuickAddDraft{
+			Title: "review", StartAt: at(3, 19, 0, 0), EndAt: at(3, 20, 0, 0), AllDay: true,
+		}},
+		{input: "conference march 20th for 3 days", expected: QuickAddDraft{
+			Title: "conference", StartAt: at(3, 20, 0, 0), EndAt: at(3, 23, 0, 0), AllDay: true,
+		}},
+		{input: "vacation 2025-07-01 for 2 weeks", expected: QuickAddDraft{
+			Title: "vacation", StartAt: at(7, 1, 0, 0), EndAt: at(7, 15, 0, 0), AllDay: true,
+		}},
+		{input: "New Year party 31 dec 10pm-1am", expected: QuickAddDraft{
+			Title: "New Year party", StartAt: at(12, 31, 22, 0), EndAt: time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+		}},
+		{input: "workshop on 15 mar from 10am to 11:30am", expected: QuickAddDraft{
+			Title: "workshop", StartAt: at(3, 15, 10, 0), EndAt: at(3, 15, 11, 30),
+		}},
+		{input: "sync 1-2pm", expected: QuickAddDraft{
+			Title: "sync", StartAt: at(3, 12, 13, 0), EndAt: at(3, 12, 14, 0),
+		}},
+		{input: "coffee in 2 hours", expected: QuickAddDraft{
+			Title: "coffee", StartAt: at(3, 12, 12, 30), EndAt: at(3, 12, 13, 30),
+		}},
+		{input: "trip in 3 days", expected: QuickAddDraft{
+			Title: "trip", StartAt: at(3, 15, 0, 0), EndAt: at(3, 16, 0, 0), AllDay: true,
+		}},
+		{input: "follow up in 24 hours", expected: QuickAddDraft{
+			Title: "follow up", StartAt: at(3, 13, 10, 30), EndAt: at(3, 13, 11, 30),
+		}},
+		{input: "follow up in 48h", expected: QuickAddDraft{
+			Title: "follow up", StartAt: at(3, 14, 10, 30), EndAt: at(3, 14, 11, 30),
+		}},
+		{input: "demo in 2 days at 10", expected: QuickAddDraft{
+			Title: "demo", StartAt: at(3, 14, 10, 0), EndAt: at(3, 14, 11, 0),
+		}},
+		{input: "demo at 10 in 1 week", expected: QuickAddDraft{
+			Title: "demo", StartAt: at(3, 19, 10, 0), EndAt: at(3, 19, 11, 0),
+		}},
+		{input: "meet 3 people at noon", expected: QuickAddDraft{
+			Title: "meet 3 people", StartAt: at(3, 12, 12, 0), EndAt: at(3, 12, 13, 0),
+		}},
+		{input: "planning feb 1", expected: QuickAddDraft{
+			Title: "planning", StartAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			EndAt: time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC), AllDay: true,
+		}},
+		{input: "party day after tomorrow at 8pm until 11pm", expected: QuickAddDraft{
+			Title: "party", StartAt: at(3, 14, 20, 0), EndAt: at(3, 14, 23, 0),
+		}},
+		{input: "focus time weekly 8am for 1.5 hours", expected: QuickAddDraft{
+			Title: "focus time", StartAt: at(3, 13, 8, 0), EndAt: at(3, 13, 9, 30), Weekly: true,
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			draft, err := ParseQuickAdd(tc.input, now)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, draft)
+		})
+	}
+
+	invalid := []string{
+		"", "tomorrow 5pm", "lunch", "lunch today tomorrow", "lunch 1pm 2pm",
+		"lunch today until 5pm", "lunch 2025-02-30", "lunch 1pm for 1h until 3pm",
+	}
+	for _, input := range invalid {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseQuickAdd(input, now)
+			require.ErrorIs(t, err, ErrInvalidQuickAdd)
+		})
+	}
+}
+
+func TestQuickAdd(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	_, err = a.SetAvailability(ctx, "alice", storage.Availability{TimeZone: newYork.String()})
+	require.NoError(t, err)
+
+	t.Run("draft", func(t *testing.T) {
+		draft, events, err := a.QuickAdd(ctx, "alice", "", "standup every monday 9am for 15m", 0, false)
+		require.NoError(t, err)
+		require.True(t, draft.Weekly)
+		require.Equal(t, DefaultWeeklyOccurrences, draft.Occurrences)
+		require.Len(t, events, DefaultWeeklyOccurrences)
+		// Occurrences keep the time of the day in the user's zone across DST changes.
+		for i, event := range events {
+			require.Equal(t, "standup", event.Title)
+			require.Equal(t, "alice", event.UserID)
+			require.Empty(t, event.ID)
+			local := event.StartAt.In(newYork)
+			require.Equal(t, time.Monday, local.Weekday())
+			require.Equal(t, 9, local.Hour())
+			if i > 0 {
+				require.Equal(t, events[i-1].StartAt.In(newYork).AddDate(0, 0, 7), local)
+			}
+		}
+		list, err := a.ListMonthEvents(ctx, "alice", nil, EventFilter{}, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, list)
+
+		_, _, err = a.QuickAdd(ctx, "alice", "", "standup", 0, false)
+		require.ErrorIs(t, err, ErrInvalidQuickAdd)
+
+		// The caller chooses how many occurrences are planned, up to the cap.
+		draft, events, err = a.QuickAdd(ctx, "alice", "", "standup every monday 9am", MaxWeeklyOccurrences, false)
+		require.NoError(t, err)
+		require.Equal(t, MaxWeeklyOccurrences, draft.Occurrences)
+		require.Len(t, events, MaxWeeklyOccurrences)
+		_, _, err = a.QuickAdd(ctx, "alice", "", "standup every monday 9am", MaxWeeklyOccurrences+1, false)
+		require.ErrorIs(t, err, ErrInvalidQuickAdd)
+		draft, events, err = a.QuickAdd(ctx, "alice", "", "standup tomorrow 9am", 3, false)
+		require.NoError(t, err)
+		require.Zero(t, draft.Occurrences)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		_, events, err := a.QuickAdd(ctx, "alice", "", "standup every monday 9am for 15m", 0, true)
+		require.NoError(t, err)
+		require.Len(t, events, DefaultWeeklyOccurrences)
+		for _, event := range events {
+			require.NotEmpty(t, event.ID)
+		}
+		// Repeated events are created all or none.
+		_, _, err = a.QuickAdd(ctx, "alice", "", "review every monday 9:10am", 0, true)
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		_, events, err = a.QuickAdd(ctx, "alice", "", "review tomorrow 7am", 0, true)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		got, err := a.storage.GetEvent(ctx, events[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, events[0], got)
+
+		_, _, err = a.QuickAdd(ctx, "", "", "review tomorrow 7am", 0, true)
+		require.ErrorIs(t, err, ErrNoUser)
+	})
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxReportRange is the longest time range of a report.
+const MaxReportRange = 366 * 24 * time.Hour
+
+var ErrInvalidReport = errors.New("invalid report")
+
+// ReportPeriod is the time unit a report is aggregated over.
+type ReportPeriod string
+
+const (
+	// ReportWeek aggregates over weeks starting on Monday.
+	ReportWeek  ReportPeriod = "week"
+	ReportMonth ReportPeriod = "month"
+)
+
+// Report returns the time taken by events in [from, to) per week or month of the user's time zone,
+// per user who created the events and per tag, see storage.Usage. Like ListDayEvents, it covers
+// personal events of the user and events of their calendars, or only events of calendarIDs.
+// Events of free/busy calendars are not counted. The first and last periods are cut to the range.
+// Recurring instances are not expanded: events are stored one by one, weekly occurrences
+// planned by QuickAdd included, and each stored event is counted as it is.
+func (a *App) Report(
+	ctx context.Context, userID string, calendarIDs []string, period ReportPeriod, from, to time.Time,
+) (_ []storage.Usage, err error) {
+	ctx, span := tracer.Start(ctx, "app.Report")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	switch {
+	case !to.After(from):
+		return nil, fmt.Errorf("%w: range must end after it starts", ErrInvalidRange)
+	case to.Sub(from) > MaxReportRange:
+		return nil, fmt.Errorf("%w: range is longer than %s", ErrInvalidRange, MaxReportRange)
+	}
+	if period != ReportWeek && period != ReportMonth {
+		return nil, fmt.Errorf("%w: unknown period %q", ErrInvalidReport, period)
+	}
+
+	roles, err := a.roles(ctx, userID, calendarIDs)
+	if err != nil {
+		return nil, err
+	}
+	query := storage.UsageQuery{CalendarIDs: make([]string, 0, len(roles))}
+	if len(calendarIDs) == 0 {
+		query.UserID = userID
+	}
+	for id, role := range roles {
+		switch {
+		case role.CanRead():
+			query.CalendarIDs = append(query.CalendarIDs, id)
+		case len(calendarIDs) > 0:
+			return nil, fmt.Errorf("%w: calendar %s shows only free/busy time", ErrForbidden, id)
+		}
+	}
+
+	availability, err := a.GetAvailability(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(availability.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	query.Periods = reportPeriods(period, from.In(location), to.In(location))
+
+	a.logger.DebugContext(ctx, fmt.Sprintf("report of %d %ss for user %s", len(query.Periods), period, userID))
+	return a.storage.ReportUsage(ctx, query)
+}
+
+// reportPeriods splits [from, to) into weeks or months of the location of from.
+func reportPeriods(period ReportPeriod, from, to time.Time) []storage.Period {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	if period == ReportMonth {
+		start = start.AddDate(0, 0, 1-start.Day())
+	} else {
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	}
+
+	var periods []storage.Period
+	for start.Before(to) {
+		var end time.Time
+		if period == ReportMonth {
+			end = start.AddDate(0, 1, 0)
+		} else {
+			end = start.AddDate(0, 0, 7)
+		}
+		p := storage.Period{Start: start, End: end}
+		if p.Start.Before(from) {
+			p.Start = from
+		}
+		if p.End.After(to) {
+			p.End = to.In(from.Location())
+		}
+		periods = append(periods, p)
+		start = end
+	}
+	return periods
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	team, err := a.CreateCalendar(ctx, "alice", "Team")
+	require.NoError(t, err)
+	require.NoError(t, a.ShareCalendar(ctx, "alice",
+		storage.Member{CalendarID: team.ID, UserID: "bob", Role: storage.RoleEditor}))
+	private, err := a.CreateCalendar(ctx, "carol", "Private")
+	require.NoError(t, err)
+	require.NoError(t, a.ShareCalendar(ctx, "carol",
+		storage.Member{CalendarID: private.ID, UserID: "alice", Role: storage.RoleFreeBusy}))
+
+	events := []storage.Event{
+		{UserID: "alice", StartAt: day.Add(10 * time.Hour), EndAt: day.Add(11 * time.Hour), Tags: []string{"oncall"}},
+		{
+			UserID: "alice", CalendarID: team.ID, StartAt: day.Add(10*time.Hour + 30*time.Minute),
+			EndAt: day.Add(12 * time.Hour), Tags: []string{"Oncall", "review"},
+		},
+		{UserID: "alice", StartAt: day.Add(6*24*time.Hour + 23*time.Hour), EndAt: day.Add(7*24*time.Hour + time.Hour)},
+		{UserID: "alice", StartAt: day.Add(24 * time.Hour), EndAt: day.Add(48 * time.Hour), Kind: storage.KindOutOfOffice},
+		{
+			UserID: "bob", CalendarID: team.ID, StartAt: day.Add(2*24*time.Hour + 14*time.Hour),
+			EndAt: day.Add(2*24*time.Hour + 15*time.Hour), Tags: []string{"review"},
+		},
+		{UserID: "carol", CalendarID: private.ID, StartAt: day.Add(9 * time.Hour), EndAt: day.Add(10 * time.Hour)},
+	}
+	for _, event := range events {
+		event.Title = "event"
+		_, err := a.CreateEvent(ctx, event, "")
+		require.NoError(t, err)
+	}
+
+	t.Run("weeks", func(t *testing.T) {
+		usage, err := a.Report(ctx, "alice", nil, ReportWeek, day, day.AddDate(0, 0, 14))
+		require.NoError(t, err)
+		week := storage.Period{Start: day, End: day.AddDate(0, 0, 7)}
+		next := storage.Period{Start: day.AddDate(0, 0, 7), End: day.AddDate(0, 0, 14)}
+		require.Equal(t, []storage.Usage{
+			{Period: week, UserID: "alice", Events: 3, Duration: 3 * time.Hour},
+			{Period: week, UserID: "alice", Tag: "oncall", Events: 2, Duration: 2 * time.Hour},
+			{Period: week, UserID: "alice", Tag: "review", Events: 1, Duration: 90 * time.Minute},
+			{Period: week, UserID: "bob", Events: 1, Duration: time.Hour},
+			{Period: week, UserID: "bob", Tag: "review", Events: 1, Duration: time.Hour},
+			{Period: next, UserID: "alice", Events: 1, Duration: time.Hour},
+		}, usage)
+	})
+
+	t.Run("month cut to range", func(t *testing.T) {
+		from := day.AddDate(0, 0, -5)
+		usage, err := a.Report(ctx, "alice", nil, ReportMonth, from, day.AddDate(0, 0, 22))
+		require.NoError(t, err)
+		require.Equal(t, storage.Usage{
+			Period: storage.Period{Start: from, End: day.AddDate(0, 0, 22)},
+			UserID: "alice", Events: 3, Duration: 4 * time.Hour,
+		}, usage[0])
+	})
+
+	t.Run("calendars", func(t *testing.T) {
+		usage, err := a.Report(ctx, "alice", []string{team.ID}, ReportMonth, day, day.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		require.Len(t, usage, 5)
+		require.Equal(t, 90*time.Minute, usage[0].Duration)
+
+		_, err = a.Report(ctx, "alice", []string{private.ID}, ReportWeek, day, day.AddDate(0, 0, 7))
+		require.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("weekly occurrences", func(t *testing.T) {
+		// Occurrences planned by quick-add are stored events, each of them is counted.
+		_, occurrences, err := a.QuickAdd(ctx, "dave", "", "sync every monday 9am", 3, true)
+		require.NoError(t, err)
+		from := occurrences[0].StartAt.Truncate(24 * time.Hour)
+		usage, err := a.Report(ctx, "dave", nil, ReportWeek, from, from.AddDate(0, 0, 21))
+		require.NoError(t, err)
+		require.Len(t, usage, 3)
+		for i, u := range usage {
+			require.Equal(t, from.AddDate(0, 0, 7*i), u.Period.Start)
+			require.Equal(t, 1, u.Events)
+			require.Equal(t, time.Hour, u.Duration)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			userID string
+			period ReportPeriod
+			to     time.Time
+			err    error
+		}{
+			{name: "no user", period: ReportWeek, to: day.Add(time.Hour), err: ErrNoUser},
+			{name: "empty range", userID: "alice", period: ReportWeek, to: day, err: ErrInvalidRange},
+			{name: "long range", userID: "alice", period: ReportWeek, to: day.AddDate(2, 0, 0), err: ErrInvalidRange},
+			{name: "unknown period", userID: "alice", period: "day", to: day.Add(time.Hour), err: ErrInvalidReport},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := a.Report(ctx, tc.userID, nil, tc.period, day, tc.to)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+}
+
+func TestReportPeriods(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 0, 0, 0, 0, berlin)
+	}
+
+	t.Run("months", func(t *testing.T) {
+		require.Equal(t, []storage.Period{
+			{Start: date(3, 15), End: date(4, 1)},
+			{Start: date(4, 1), End: date(5, 1)},
+			{Start: date(5, 1), End: date(5, 10)},
+		}, reportPeriods(ReportMonth, date(3, 15), date(5, 10)))
+	})
+
+	t.Run("weeks over daylight saving", func(t *testing.T) {
+		periods := reportPeriods(ReportWeek, date(3, 26), date(4, 7))
+		require.Equal(t, []storage.Period{
+			{Start: date(3, 26), End: date(3, 31)},
+			{Start: date(3, 31), End: date(4, 7)},
+		}, periods)
+		require.Equal(t, 5*24*time.Hour-time.Hour, periods[0].End.Sub(periods[0].Start))
+	})
+}
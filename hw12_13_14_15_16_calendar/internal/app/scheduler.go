@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// SchedulerStatus summarizes notifications the scheduler has yet to publish.
+type SchedulerStatus struct {
+	PendingNotifications int
+	// OldestDueAt is when the longest waiting notification became due, zero if none is pending.
+	OldestDueAt time.Time
+}
+
+// SchedulerStatus returns the backlog of notifications due by now. It reveals no details
+// of other users' events, so any authenticated user may see it.
+func (a *App) SchedulerStatus(ctx context.Context, userID string, now time.Time) (_ SchedulerStatus, err error) {
+	ctx, span := tracer.Start(ctx, "app.SchedulerStatus")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return SchedulerStatus{}, ErrNoUser
+	}
+	events, err := a.storage.ListEventsToNotify(ctx, now)
+	if err != nil {
+		return SchedulerStatus{}, err
+	}
+	status := SchedulerStatus{PendingNotifications: len(events)}
+	for _, event := range events {
+		dueAt := event.StartAt.Add(-event.NotifyBefore)
+		if status.OldestDueAt.IsZero() || dueAt.Before(status.OldestDueAt) {
+			status.OldestDueAt = dueAt
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// syncPageSize is the maximum number of changes returned by a sync request.
+const syncPageSize = 500
+
+// SyncPage is a batch of changes since a sync token.
+type SyncPage struct {
+	Changes []storage.Change
+	// Token is the sync token to continue from.
+	Token int64
+	// More reports whether changes after Token are already available.
+	More bool
+}
+
+// Sync returns the latest changes after the since sync token of events the user sees,
+// as selected by calendarIDs in WatchEvents. Zero since returns all events. Deleted
+// events are reported until their tombstones are purged; after that, syncing from an
+// earlier token returns storage.ErrSyncTokenExpired and the client has to sync from zero.
+func (a *App) Sync(ctx context.Context, userID string, calendarIDs []string, since int64) (_ SyncPage, err error) {
+	ctx, span := tracer.Start(ctx, "app.Sync")
+	defer func() { endSpan(span, err) }()
+
+	if userID == "" {
+		return SyncPage{}, ErrNoUser
+	}
+	roles, err := a.roles(ctx, userID, calendarIDs)
+	if err != nil {
+		return SyncPage{}, err
+	}
+	ids := make([]string, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	personal := userID
+	if len(calendarIDs) > 0 {
+		personal = ""
+	}
+
+	changes, err := a.storage.ListChanges(ctx, personal, ids, since, syncPageSize+1)
+	if err != nil {
+		return SyncPage{}, err
+	}
+	page := SyncPage{Changes: changes, Token: since}
+	if len(changes) > syncPageSize {
+		page.Changes, page.More = changes[:syncPageSize], true
+	}
+	for i, change := range page.Changes {
+		if change.Event.CalendarID != "" && !roles[change.Event.CalendarID].CanRead() {
+			page.Changes[i].Event = busyEvent(change.Event)
+		}
+	}
+	if len(page.Changes) > 0 {
+		page.Token = page.Changes[len(page.Changes)-1].Seq
+	}
+	return page, nil
+}
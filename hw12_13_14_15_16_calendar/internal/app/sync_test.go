@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	room, err := a.CreateCalendar(ctx, "alice", "Team Room")
+	require.NoError(t, err)
+	require.NoError(t, a.ShareCalendar(ctx, "alice", storage.Member{
+		CalendarID: room.ID, UserID: "dave", Role: storage.RoleFreeBusy,
+	}))
+	gym, err := a.CreateEvent(ctx, storage.Event{
+		Title: "gym", UserID: "dave", StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+	planning, err := a.CreateEvent(ctx, storage.Event{
+		Title: "planning", UserID: "alice", CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+
+	_, err = a.Sync(ctx, "", nil, 0)
+	require.ErrorIs(t, err, ErrNoUser)
+	_, err = a.Sync(ctx, "eve", []string{room.ID}, 0)
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+
+	page, err := a.Sync(ctx, "dave", nil, 0)
+	require.NoError(t, err)
+	require.False(t, page.More)
+	require.Len(t, page.Changes, 2)
+	require.Equal(t, gym, page.Changes[0].Event)
+	require.Equal(t, storage.Event{
+		ID: planning.ID, CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+	}, page.Changes[1].Event)
+	require.Equal(t, page.Changes[1].Seq, page.Token)
+
+	// Nothing changed since the last sync.
+	page, err = a.Sync(ctx, "dave", nil, page.Token)
+	require.NoError(t, err)
+	require.Empty(t, page.Changes)
+	token := page.Token
+
+	require.NoError(t, a.DeleteEvent(ctx, "alice", planning.ID))
+	page, err = a.Sync(ctx, "dave", []string{room.ID}, token)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	require.Equal(t, storage.ChangeDeleted, page.Changes[0].Kind)
+	require.Equal(t, planning.ID, page.Changes[0].Event.ID)
+}
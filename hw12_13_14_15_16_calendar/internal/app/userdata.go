@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ExportUserData returns everything stored about the user and records the export by actorID
+// in the audit log. It is meant for admins, callers check that the actor is one.
+func (a *App) ExportUserData(ctx context.Context, actorID, userID string) (_ storage.UserData, err error) {
+	ctx, span := tracer.Start(ctx, "app.ExportUserData")
+	defer func() { endSpan(span, err) }()
+
+	if actorID == "" {
+		return storage.UserData{}, ErrNoUser
+	}
+	data, err := a.storage.ExportUserData(ctx, userID)
+	if err != nil {
+		return storage.UserData{}, err
+	}
+	counts := map[string]int{
+		"events":        len(data.Events),
+		"attachments":   len(data.Attachments),
+		"calendars":     len(data.Calendars),
+		"categories":    len(data.Categories),
+		"availability":  0,
+		"history":       len(data.History),
+		"notifications": len(data.Notifications),
+	}
+	if data.Availability != nil {
+		counts["availability"] = 1
+	}
+	if _, err := a.storage.AddAuditRecord(ctx, storage.AuditRecord{
+		ActorID: actorID, Action: storage.AuditExportUserData, UserID: userID, Counts: counts,
+	}); err != nil {
+		return storage.UserData{}, err
+	}
+	a.logger.InfoContext(ctx, fmt.Sprintf("data of user %s exported by %s", userID, actorID))
+	return data, nil
+}
+
+// EraseUserData deletes everything stored about the user, see storage DeleteUserData,
+// and the contents of the deleted attachments, and returns the audit record of the erasure.
+// Notifications of the user already published to the queue are dropped by the sender
+// as their events are gone, event changes already published keep the user. It is meant
+// for admins, callers check that actorID is one.
+func (a *App) EraseUserData(ctx context.Context, actorID, userID string) (_ storage.AuditRecord, err error) {
+	ctx, span := tracer.Start(ctx, "app.EraseUserData")
+	defer func() { endSpan(span, err) }()
+
+	if actorID == "" {
+		return storage.AuditRecord{}, ErrNoUser
+	}
+	erased, err := a.storage.DeleteUserData(ctx, storage.AuditRecord{
+		ActorID: actorID, Action: storage.AuditEraseUserData, UserID: userID,
+	})
+	if err != nil {
+		return storage.AuditRecord{}, err
+	}
+	a.deleteBlobs(ctx, erased.Attachments)
+	a.logger.InfoContext(ctx, fmt.Sprintf("data of user %s erased by %s", userID, actorID))
+	return erased.Record, nil
+}
+
+// ListAuditRecords returns the audit records about the user, all records if userID is empty,
+// oldest first. It is meant for admins, callers check that the actor is one.
+func (a *App) ListAuditRecords(ctx context.Context, userID string) ([]storage.AuditRecord, error) {
+	return a.storage.ListAuditRecords(ctx, userID)
+}
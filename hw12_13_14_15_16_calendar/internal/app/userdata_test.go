@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserData(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(dir), time.Hour, 0, AttachmentLimits{})
+
+	team, err := a.CreateCalendar(ctx, "alice", "Team")
+	require.NoError(t, err)
+	require.NoError(t, a.ShareCalendar(ctx, "alice",
+		storage.Member{CalendarID: team.ID, UserID: "bob", Role: storage.RoleEditor}))
+	for i, event := range []storage.Event{
+		{UserID: "alice"},
+		{UserID: "bob", CalendarID: team.ID},
+		{UserID: "bob"},
+	} {
+		event.Title = "event"
+		event.StartAt = start.Add(time.Duration(i) * 2 * time.Hour)
+		event.EndAt = event.StartAt.Add(time.Hour)
+		created, err := a.CreateEvent(ctx, event, "")
+		require.NoError(t, err)
+		_, err = a.AddAttachment(ctx, event.UserID,
+			storage.Attachment{EventID: created.ID, Name: "notes.txt", ContentType: "text/plain"},
+			strings.NewReader("notes"))
+		require.NoError(t, err)
+	}
+	blobs := func(t *testing.T) int {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		return len(entries)
+	}
+	require.Equal(t, 3, blobs(t))
+
+	t.Run("export", func(t *testing.T) {
+		_, err := a.ExportUserData(ctx, "", "alice")
+		require.ErrorIs(t, err, ErrNoUser)
+
+		data, err := a.ExportUserData(ctx, "admin", "alice")
+		require.NoError(t, err)
+		require.Len(t, data.Events, 1)
+		require.Len(t, data.Attachments, 1)
+		require.Len(t, data.Calendars, 1)
+
+		records, err := a.ListAuditRecords(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, "admin", records[0].ActorID)
+		require.Equal(t, storage.AuditExportUserData, records[0].Action)
+		require.Equal(t, 1, records[0].Counts["events"])
+	})
+
+	t.Run("erase", func(t *testing.T) {
+		record, err := a.EraseUserData(ctx, "admin", "alice")
+		require.NoError(t, err)
+		require.Equal(t, storage.AuditEraseUserData, record.Action)
+		require.Equal(t, 2, record.Counts["events"])
+		require.Equal(t, 2, record.Counts["attachments"])
+		// Only the attachment on the personal event of bob is left.
+		require.Equal(t, 1, blobs(t))
+
+		events, err := a.ListDayEvents(ctx, "bob", nil, EventFilter{}, start)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+
+		records, err := a.ListAuditRecords(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, record, records[1])
+	})
+}
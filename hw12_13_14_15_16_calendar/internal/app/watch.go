@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// WatchEvents streams changes of events the user sees: those of the given calendars,
+// or the user's personal events and events of all the user's calendars if calendarIDs
+// is empty. Access is checked once, when watching starts. Streaming resumes after the
+// since cursor; zero since streams new changes only. The channel is closed when ctx
+// is done or when the watcher falls behind and has to resume from its last cursor.
+func (a *App) WatchEvents(
+	ctx context.Context, userID string, calendarIDs []string, since int64,
+) (<-chan storage.Change, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	roles, err := a.roles(ctx, userID, calendarIDs)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := a.feed.Subscribe(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(chan storage.Change)
+	go func() {
+		defer close(visible)
+		for change := range changes {
+			event := change.Event
+			if event.CalendarID == "" {
+				if len(calendarIDs) > 0 || event.UserID != userID {
+					continue
+				}
+			} else {
+				role, ok := roles[event.CalendarID]
+				if !ok {
+					continue
+				}
+				if !role.CanRead() {
+					change.Event = busyEvent(event)
+				}
+			}
+			select {
+			case visible <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return visible, nil
+}
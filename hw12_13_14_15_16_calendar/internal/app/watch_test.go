@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	a := New(logger.NewWithWriter("error", io.Discard), memorystorage.New(), changefeed.New(100),
+		blob.NewFS(t.TempDir()), time.Hour, 0, AttachmentLimits{})
+
+	room, err := a.CreateCalendar(ctx, "alice", "Team Room")
+	require.NoError(t, err)
+	require.NoError(t, a.ShareCalendar(ctx, "alice", storage.Member{
+		CalendarID: room.ID, UserID: "dave", Role: storage.RoleFreeBusy,
+	}))
+
+	_, err = a.WatchEvents(ctx, "eve", []string{room.ID}, 0)
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+	_, err = a.WatchEvents(ctx, "dave", nil, 100)
+	require.ErrorIs(t, err, storage.ErrCursorExpired)
+
+	all, err := a.WatchEvents(ctx, "dave", nil, 0)
+	require.NoError(t, err)
+	shared, err := a.WatchEvents(ctx, "dave", []string{room.ID}, 0)
+	require.NoError(t, err)
+
+	_, err = a.CreateEvent(ctx, storage.Event{
+		Title: "dentist", UserID: "alice", StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+	personal, err := a.CreateEvent(ctx, storage.Event{
+		Title: "gym", UserID: "dave", StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+	planning, err := a.CreateEvent(ctx, storage.Event{
+		Title: "planning", UserID: "alice", CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+	}, "")
+	require.NoError(t, err)
+
+	change := <-all
+	require.Equal(t, storage.ChangeCreated, change.Kind)
+	require.Equal(t, personal, change.Event)
+	change = <-all
+	require.Equal(t, storage.Event{
+		ID: planning.ID, CalendarID: room.ID, StartAt: start, EndAt: start.Add(time.Hour),
+	}, change.Event)
+
+	change = <-shared
+	require.Equal(t, planning.ID, change.Event.ID)
+	require.Empty(t, change.Event.Title)
+
+	// A reconnecting watcher resumes after the last change it received.
+	resumed, err := a.WatchEvents(ctx, "dave", nil, change.Seq-1)
+	require.NoError(t, err)
+	require.Equal(t, planning.ID, (<-resumed).Event.ID)
+}
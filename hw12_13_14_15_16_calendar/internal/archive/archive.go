@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// record is an archived event, a line of an archive file.
+type record struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	StartAt       time.Time `json:"startAt"`
+	EndAt         time.Time `json:"endAt"`
+	Description   string    `json:"description,omitempty"`
+	UserID        string    `json:"userId"`
+	CalendarID    string    `json:"calendarId,omitempty"`
+	Kind          string    `json:"kind,omitempty"`
+	Address       string    `json:"address,omitempty"`
+	ConferenceURL string    `json:"conferenceUrl,omitempty"`
+	NotifyBefore  string    `json:"notifyBefore,omitempty"`
+	Version       int64     `json:"version"`
+	CategoryID    string    `json:"categoryId,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+}
+
+func newRecord(event storage.Event) record {
+	r := record{
+		ID:            event.ID,
+		Title:         event.Title,
+		StartAt:       event.StartAt,
+		EndAt:         event.EndAt,
+		Description:   event.Description,
+		UserID:        event.UserID,
+		CalendarID:    event.CalendarID,
+		Kind:          string(event.Kind),
+		Address:       event.Location.Address,
+		ConferenceURL: event.Location.ConferenceURL,
+		Version:       event.Version,
+		CategoryID:    event.CategoryID,
+		Tags:          event.Tags,
+	}
+	if event.NotifyBefore > 0 {
+		r.NotifyBefore = event.NotifyBefore.String()
+	}
+	return r
+}
+
+func (r record) event() (storage.Event, error) {
+	event := storage.Event{
+		ID:          r.ID,
+		Title:       r.Title,
+		StartAt:     r.StartAt,
+		EndAt:       r.EndAt,
+		Description: r.Description,
+		UserID:      r.UserID,
+		CalendarID:  r.CalendarID,
+		Kind:        storage.EventKind(r.Kind),
+		Location:    storage.Location{Address: r.Address, ConferenceURL: r.ConferenceURL},
+		Version:     r.Version,
+		CategoryID:  r.CategoryID,
+		Tags:        r.Tags,
+	}
+	if r.NotifyBefore != "" {
+		d, err := time.ParseDuration(r.NotifyBefore)
+		if err != nil {
+			return storage.Event{}, fmt.Errorf("event %s: notify before: %w", r.ID, err)
+		}
+		event.NotifyBefore = d
+	}
+	return event, nil
+}
+
+// Archive writes events to gzip-compressed JSON-lines files in a directory, a file per call.
+type Archive struct {
+	dir string
+}
+
+func New(dir string) *Archive {
+	return &Archive{dir: dir}
+}
+
+// Archive writes the events to a new file. The file appears complete or not at all.
+func (a *Archive) Archive(_ context.Context, events []storage.Event) (err error) {
+	if err := os.MkdirAll(a.dir, 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(a.dir, ".events-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(zw)
+	for _, event := range events {
+		if err := enc.Encode(newRecord(event)); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	name := "events-" + time.Now().UTC().Format("20060102T150405.000000000Z") + ".jsonl.gz"
+	return os.Rename(tmp.Name(), filepath.Join(a.dir, name))
+}
+
+// Read calls fn for every event of the archive file in order.
+func Read(path string, fn func(storage.Event) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	for {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		event, err := r.event()
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
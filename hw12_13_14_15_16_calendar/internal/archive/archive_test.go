@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	events := []storage.Event{
+		{
+			ID: "1", Title: "event 1", StartAt: start, EndAt: start.Add(time.Hour),
+			Description: "notes", UserID: "user", NotifyBefore: 15 * time.Minute, Version: 3,
+			Location:   storage.Location{Address: "Room 1", ConferenceURL: "https://meet.example.com/1"},
+			CategoryID: "deleted", Tags: []string{"oncall"},
+		},
+		{ID: "2", Title: "event 2", StartAt: start.Add(time.Hour), EndAt: start.Add(2 * time.Hour), UserID: "user"},
+	}
+	require.NoError(t, New(dir).Archive(ctx, events))
+	require.NoError(t, New(dir).Archive(ctx, events[1:]))
+
+	paths, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl.gz"))
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	t.Run("read", func(t *testing.T) {
+		read := make([]storage.Event, 0)
+		require.NoError(t, Read(paths[0], func(event storage.Event) error {
+			read = append(read, event)
+			return nil
+		}))
+		require.Equal(t, events, read)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		s := memorystorage.New()
+		busy := events[1]
+		busy.ID = "3"
+		require.NoError(t, s.CreateEvent(ctx, busy))
+
+		restored, err := Restore(ctx, s, paths[0])
+		require.NoError(t, err)
+		require.Equal(t, Restored{Events: 1, Conflicting: []string{"2"}}, restored)
+		// The category of the event no longer exists.
+		event, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		uncategorized := events[0]
+		uncategorized.CategoryID = ""
+		require.Equal(t, uncategorized, event)
+
+		restored, err = Restore(ctx, s, paths[0])
+		require.NoError(t, err)
+		require.Equal(t, Restored{Existing: 1, Conflicting: []string{"2"}}, restored)
+	})
+
+	t.Run("restore out of office", func(t *testing.T) {
+		s := memorystorage.New()
+		require.NoError(t, s.CreateEvent(ctx, storage.Event{
+			ID: "3", Title: "vacation", StartAt: start.Add(time.Hour), EndAt: start.Add(24 * time.Hour),
+			UserID: "user", Kind: storage.KindOutOfOffice,
+		}))
+
+		restored, err := Restore(ctx, s, paths[0])
+		require.NoError(t, err)
+		require.Equal(t, Restored{Events: 1, Conflicting: []string{"2"}}, restored)
+	})
+}
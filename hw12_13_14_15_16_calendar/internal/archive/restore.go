@@ -0,0 +1,48 @@
+package archive
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+}
+
+// Restored is the outcome of restoring an archive file.
+type Restored struct {
+	Events int
+	// Existing are events that are already in the storage, they are left as they are.
+	Existing int
+	// Conflicting are IDs of events that overlap events or out-of-office periods
+	// created since they were archived or belong to deleted calendars.
+	Conflicting []string
+}
+
+// Restore creates the events of the archive file in the storage. Events whose
+// category has been deleted since they were archived are restored without it.
+func Restore(ctx context.Context, store Storage, path string) (Restored, error) {
+	var restored Restored
+	err := Read(path, func(event storage.Event) error {
+		err := store.CreateEvent(ctx, event)
+		if errors.Is(err, storage.ErrCategoryNotFound) {
+			event.CategoryID = ""
+			err = store.CreateEvent(ctx, event)
+		}
+		switch {
+		case err == nil:
+			restored.Events++
+		case errors.Is(err, storage.ErrEventExists):
+			restored.Existing++
+		case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrOutOfOffice),
+			errors.Is(err, storage.ErrCalendarNotFound):
+			restored.Conflicting = append(restored.Conflicting, event.ID)
+		default:
+			return err
+		}
+		return nil
+	})
+	return restored, err
+}
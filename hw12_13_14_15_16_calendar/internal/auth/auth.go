@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoToken      = errors.New("authentication token is required")
+	ErrInvalidToken = errors.New("invalid authentication token")
+)
+
+// Authenticator verifies a bearer token and returns the ID of its user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type userIDKey struct{}
+
+// WithUserID returns ctx carrying the ID of the authenticated user.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the ID of the authenticated user or an empty string.
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" value.
+func BearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Chain tries authenticators in order and returns the first user ID verified.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrNoToken
+	}
+	for _, a := range c {
+		if userID, err := a.Authenticate(ctx, token); err == nil {
+			return userID, nil
+		}
+	}
+	return "", ErrInvalidToken
+}
+
+// StaticTokens authenticates API tokens listed in the config.
+type StaticTokens struct {
+	tokens []staticToken
+}
+
+type staticToken struct {
+	hash   [sha256.Size]byte
+	userID string
+}
+
+// NewStaticTokens creates an authenticator from a token to user ID mapping.
+func NewStaticTokens(tokens map[string]string) *StaticTokens {
+	s := &StaticTokens{tokens: make([]staticToken, 0, len(tokens))}
+	for token, userID := range tokens {
+		s.tokens = append(s.tokens, staticToken{hash: sha256.Sum256([]byte(token)), userID: userID})
+	}
+	return s
+}
+
+// Authenticate compares the token with every configured one in constant time.
+func (s *StaticTokens) Authenticate(_ context.Context, token string) (string, error) {
+	hash := sha256.Sum256([]byte(token))
+	userID := ""
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash[:]) == 1 {
+			userID = t.userID
+		}
+	}
+	if userID == "" {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// JWT authenticates signed JSON web tokens. The user ID is the "sub" claim.
+type JWT struct {
+	key    any
+	parser *jwt.Parser
+}
+
+// NewHS256 verifies tokens signed with the shared secret. An empty issuer
+// accepts tokens of any issuer.
+func NewHS256(secret []byte, issuer string) *JWT {
+	return newJWT(jwt.SigningMethodHS256, secret, issuer)
+}
+
+// NewRS256 verifies tokens signed with the private key of publicKey.
+// An empty issuer accepts tokens of any issuer.
+func NewRS256(publicKey *rsa.PublicKey, issuer string) *JWT {
+	return newJWT(jwt.SigningMethodRS256, publicKey, issuer)
+}
+
+// ParseRSAPublicKey parses a PEM encoded RSA public key.
+func ParseRSAPublicKey(pem []byte) (*rsa.PublicKey, error) {
+	return jwt.ParseRSAPublicKeyFromPEM(pem)
+}
+
+func newJWT(method jwt.SigningMethod, key any, issuer string) *JWT {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{method.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	return &JWT{key: key, parser: jwt.NewParser(opts...)}
+}
+
+func (j *JWT) Authenticate(_ context.Context, token string) (string, error) {
+	claims := jwt.RegisteredClaims{}
+	if _, err := j.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return j.key, nil
+	}); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("%w: subject is required", ErrInvalidToken)
+	}
+	return claims.Subject, nil
+}
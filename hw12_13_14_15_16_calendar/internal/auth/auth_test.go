@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	ctx := context.Background()
+
+	sign := func(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	validClaims := jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "https://auth.example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	t.Run("bearer token", func(t *testing.T) {
+		require.Equal(t, "abc", BearerToken("Bearer abc"))
+		require.Equal(t, "abc", BearerToken("bearer abc"))
+		require.Empty(t, BearerToken("Basic abc"))
+		require.Empty(t, BearerToken("abc"))
+	})
+
+	t.Run("static tokens", func(t *testing.T) {
+		a := NewStaticTokens(map[string]string{"secret-1": "alice", "secret-2": "bob"})
+
+		userID, err := a.Authenticate(ctx, "secret-2")
+		require.NoError(t, err)
+		require.Equal(t, "bob", userID)
+
+		_, err = a.Authenticate(ctx, "secret-3")
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("hs256", func(t *testing.T) {
+		secret := []byte("jwt-secret")
+		a := NewHS256(secret, "https://auth.example.com")
+
+		userID, err := a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, secret, validClaims))
+		require.NoError(t, err)
+		require.Equal(t, "alice", userID)
+
+		_, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, []byte("other"), validClaims))
+		require.ErrorIs(t, err, ErrInvalidToken)
+
+		claims := validClaims
+		claims.Issuer = "https://evil.example.com"
+		_, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, secret, claims))
+		require.ErrorIs(t, err, ErrInvalidToken)
+
+		claims = validClaims
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		_, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, secret, claims))
+		require.ErrorIs(t, err, ErrInvalidToken)
+
+		claims = validClaims
+		claims.Subject = ""
+		_, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, secret, claims))
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("rs256", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		a := NewRS256(&key.PublicKey, "")
+
+		userID, err := a.Authenticate(ctx, sign(t, jwt.SigningMethodRS256, key, validClaims))
+		require.NoError(t, err)
+		require.Equal(t, "alice", userID)
+
+		// A token signed with the public key as an HMAC secret must be rejected.
+		_, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, []byte("public key"), validClaims))
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		secret := []byte("jwt-secret")
+		a := Chain{NewStaticTokens(map[string]string{"api-token": "bob"}), NewHS256(secret, "")}
+
+		userID, err := a.Authenticate(ctx, "api-token")
+		require.NoError(t, err)
+		require.Equal(t, "bob", userID)
+
+		userID, err = a.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, secret, validClaims))
+		require.NoError(t, err)
+		require.Equal(t, "alice", userID)
+
+		_, err = a.Authenticate(ctx, "")
+		require.ErrorIs(t, err, ErrNoToken)
+		_, err = a.Authenticate(ctx, "unknown")
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
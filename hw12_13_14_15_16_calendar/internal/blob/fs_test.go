@@ -0,0 +1,95 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("put, open and delete", func(t *testing.T) {
+		s := NewFS(t.TempDir() + "/blobs")
+
+		n, err := s.Put(ctx, "a", strings.NewReader("hello"))
+		require.NoError(t, err)
+		require.Equal(t, int64(5), n)
+		_, err = s.Put(ctx, "a", strings.NewReader("replaced"))
+		require.NoError(t, err)
+
+		r, err := s.Open(ctx, "a")
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, "replaced", string(content))
+
+		require.NoError(t, s.Delete(ctx, "a"))
+		require.NoError(t, s.Delete(ctx, "a"))
+		_, err = s.Open(ctx, "a")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("failed put", func(t *testing.T) {
+		dir := t.TempDir()
+		s := NewFS(dir)
+
+		_, err := s.Put(ctx, "a", io.MultiReader(strings.NewReader("partial"), failingReader{}))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		_, err = s.Open(ctx, "a")
+		require.ErrorIs(t, err, ErrNotFound)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		dir := t.TempDir()
+		s := NewFS(dir)
+		keys, err := s.List(ctx, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		for _, key := range []string{"old", "new"} {
+			_, err := s.Put(ctx, key, strings.NewReader(key))
+			require.NoError(t, err)
+		}
+		hourAgo := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(dir+"/old", hourAgo, hourAgo))
+		require.NoError(t, os.WriteFile(dir+"/.blob-1.tmp", []byte("partial"), 0o600))
+		require.NoError(t, os.Chtimes(dir+"/.blob-1.tmp", hourAgo, hourAgo))
+
+		keys, err = s.List(ctx, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, []string{"old"}, keys)
+		keys, err = s.List(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"old", "new"}, keys)
+		keys, err = NewFS(dir+"/missing").List(ctx, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		s := NewFS(t.TempDir())
+
+		for _, key := range []string{"", "..", "../a", "a/b", ".hidden"} {
+			_, err := s.Put(ctx, key, strings.NewReader("x"))
+			require.ErrorIs(t, err, ErrInvalidKey, key)
+			_, err = s.Open(ctx, key)
+			require.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,92 @@
+package changefeed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// subscriberBuffer is how many changes a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 64
+
+// Feed fans out event changes to subscribers and keeps the latest changes
+// so that subscribers can resume after a reconnect.
+type Feed struct {
+	mu          sync.Mutex
+	seq         int64
+	history     []storage.Change
+	historySize int
+	subscribers map[chan storage.Change]struct{}
+}
+
+// New creates a feed remembering the last historySize changes.
+func New(historySize int) *Feed {
+	return &Feed{
+		historySize: historySize,
+		subscribers: make(map[chan storage.Change]struct{}),
+	}
+}
+
+// Publish assigns the next sequence number to the change and sends it to subscribers.
+// Subscribers that do not keep up are dropped: their channel is closed and
+// they are expected to resume from the last change they received.
+func (f *Feed) Publish(kind storage.ChangeKind, event storage.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	change := storage.Change{Seq: f.seq, Kind: kind, Event: event}
+	if f.historySize > 0 {
+		if len(f.history) == f.historySize {
+			f.history = append(f.history[:0], f.history[1:]...)
+		}
+		f.history = append(f.history, change)
+	}
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns changes published after the since cursor until ctx is done.
+// Zero since subscribes to new changes only. If changes after since are no longer
+// kept, storage.ErrCursorExpired is returned.
+func (f *Feed) Subscribe(ctx context.Context, since int64) (<-chan storage.Change, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var replay []storage.Change
+	if since > 0 {
+		if since > f.seq || since < f.seq && (len(f.history) == 0 || since+1 < f.history[0].Seq) {
+			return nil, storage.ErrCursorExpired
+		}
+		for _, change := range f.history {
+			if change.Seq > since {
+				replay = append(replay, change)
+			}
+		}
+	}
+
+	ch := make(chan storage.Change, len(replay)+subscriberBuffer)
+	for _, change := range replay {
+		ch <- change
+	}
+	f.subscribers[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}()
+	return ch, nil
+}
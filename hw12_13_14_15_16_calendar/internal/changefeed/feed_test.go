@@ -0,0 +1,68 @@
+package changefeed
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeed(t *testing.T) {
+	publish := func(f *Feed, n int) {
+		for i := 0; i < n; i++ {
+			f.Publish(storage.ChangeCreated, storage.Event{ID: strconv.Itoa(i)})
+		}
+	}
+
+	t.Run("replay and live changes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		f := New(10)
+		publish(f, 3)
+
+		changes, err := f.Subscribe(ctx, 1)
+		require.NoError(t, err)
+		f.Publish(storage.ChangeDeleted, storage.Event{ID: "0"})
+
+		for _, seq := range []int64{2, 3, 4} {
+			change := <-changes
+			require.Equal(t, seq, change.Seq)
+		}
+
+		cancel()
+		_, ok := <-changes
+		require.False(t, ok)
+	})
+
+	t.Run("expired cursor", func(t *testing.T) {
+		ctx := context.Background()
+		f := New(2)
+		publish(f, 5)
+
+		_, err := f.Subscribe(ctx, 2)
+		require.ErrorIs(t, err, storage.ErrCursorExpired)
+		_, err = f.Subscribe(ctx, 6)
+		require.ErrorIs(t, err, storage.ErrCursorExpired)
+
+		changes, err := f.Subscribe(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		_, err = f.Subscribe(ctx, 5)
+		require.NoError(t, err)
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		f := New(0)
+		changes, err := f.Subscribe(context.Background(), 0)
+		require.NoError(t, err)
+		publish(f, subscriberBuffer+1)
+
+		received := 0
+		for range changes {
+			received++
+		}
+		require.Equal(t, subscriberBuffer, received)
+	})
+}
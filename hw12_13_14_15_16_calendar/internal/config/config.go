@@ -0,0 +1,297 @@
+// Package config loads configurations of the calendar binaries. Every binary keeps
+// its schema in a struct of its own: sections are struct fields holding structs,
+// keys are fields of strings, bools, numbers, durations and slices.
+//
+// Keys are read from a TOML file and overridden by environment variables named
+// after the prefix of the binary and the path of the key in upper snake case,
+// e.g. CALENDAR_STORAGE_DSN for the dsn key of the [storage] section. Slices of
+// strings are comma-separated, slices of tables are read from the file only.
+//
+// String fields tagged `secret:"true"` are redacted when printed, fields tagged
+// `secret:"url"` keep everything but the password of the URL or DSN they hold.
+//
+// Running binaries reload their config on SIGHUP with a Reloader, only the keys
+// they declare safe may change without a restart.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+)
+
+const redacted = "REDACTED"
+
+var (
+	ErrUnknownKey = errors.New("unknown config key")
+
+	durationType = reflect.TypeOf(time.Duration(0))
+	dsnPassword  = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+)
+
+// Load reads the file into config and applies the environment overrides. Config
+// holds the defaults, keys missing from the file and the environment keep them.
+func Load(path, prefix string, config any) error {
+	return load(path, prefix, config, os.LookupEnv)
+}
+
+func load(path, prefix string, config any, lookupEnv func(string) (string, bool)) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", config)
+	}
+	md, err := toml.DecodeFile(path, config)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownKey, strings.Join(keys, ", "))
+	}
+	return applyEnv(v.Elem(), strings.TrimSuffix(prefix, "_"), lookupEnv)
+}
+
+// applyEnv sets the fields of the struct from the variables named after them.
+func applyEnv(v reflect.Value, prefix string, lookupEnv func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + "_" + envName(field.Name)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, name, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s cannot be set from the environment", v.Type())
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("%s cannot be set from the environment", v.Type())
+	}
+	return nil
+}
+
+// Print writes the config as TOML with its secrets redacted.
+func Print(w io.Writer, config any) error {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a struct, got %T", config)
+	}
+	p := &printer{w: w}
+	p.table(v, "", false)
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+	// started is set once anything is written, tables are separated by blank lines.
+	started bool
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+		p.started = true
+	}
+}
+
+// table writes the keys of the struct and then its subtables, header names it
+// unless it is the root; arrays of tables are written as [[header]].
+func (p *printer) table(v reflect.Value, header string, array bool) {
+	if header != "" {
+		if p.started {
+			p.printf("\n")
+		}
+		if array {
+			p.printf("[[%s]]\n", header)
+		} else {
+			p.printf("[%s]\n", header)
+		}
+	}
+	t := v.Type()
+	type subtable struct {
+		name string
+		v    reflect.Value
+	}
+	subtables := make([]subtable, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		key := keyName(field.Name)
+		switch {
+		case fv.Kind() == reflect.Struct, fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			subtables = append(subtables, subtable{name: join(header, key), v: fv})
+		default:
+			p.printf("%s = %s\n", key, formatValue(fv, field.Tag.Get("secret")))
+		}
+	}
+	for _, sub := range subtables {
+		if sub.v.Kind() == reflect.Struct {
+			p.table(sub.v, sub.name, false)
+			continue
+		}
+		for i := 0; i < sub.v.Len(); i++ {
+			p.table(sub.v.Index(i), sub.name, true)
+		}
+	}
+}
+
+func formatValue(v reflect.Value, secret string) string {
+	if v.Type() == durationType {
+		return strconv.Quote(time.Duration(v.Int()).String())
+	}
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return strconv.Quote(redact(v.String(), secret))
+	case reflect.Slice:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatValue(v.Index(i), secret))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// redact hides the secret of the value, see the package documentation.
+func redact(s, secret string) string {
+	switch {
+	case s == "" || secret == "":
+		return s
+	case secret == "url":
+		if u, err := url.Parse(s); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				return u.String()
+			}
+			return s
+		}
+		return dsnPassword.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return redacted
+}
+
+// keyName returns the TOML key of a field: its name with the leading capitals
+// lowered, e.g. "dsn" for DSN and "idempotencyTTL" for IdempotencyTTL.
+func keyName(field string) string {
+	runes := []rune(field)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// The last capital of an acronym starts the next word, e.g. "ID" in "IDHeader".
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// envName returns the part of the environment variable named after a field:
+// its words in upper snake case, e.g. "IDEMPOTENCY_TTL" for IdempotencyTTL.
+func envName(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func join(header, key string) string {
+	if header == "" {
+		return key
+	}
+	return header + "." + key
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// absent stands for the value of a key missing from one of the compared configs,
+// e.g. of an item appended to a slice of tables.
+const absent = "(none)"
+
+// Change is a key whose value differs between two configs, secrets are redacted.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff returns the keys whose values differ between the configs of the same type
+// in the order they are printed. Items of slices of tables are keyed by their
+// index, e.g. "rateLimit.routes[1].rate". Secrets are compared as they are, a
+// changed secret is reported with both values redacted.
+func Diff(old, new any) []Change {
+	oldValues := flatten(reflect.Indirect(reflect.ValueOf(old)))
+	newValues := flatten(reflect.Indirect(reflect.ValueOf(new)))
+	changes := make([]Change, 0)
+	seen := make(map[string]bool, len(oldValues))
+	for _, kv := range oldValues {
+		seen[kv.key] = true
+		next, ok := lookup(newValues, kv.key)
+		if !ok {
+			changes = append(changes, Change{Key: kv.key, Old: kv.value, New: absent})
+		} else if next.raw != kv.raw {
+			changes = append(changes, Change{Key: kv.key, Old: kv.value, New: next.value})
+		}
+	}
+	for _, kv := range newValues {
+		if !seen[kv.key] {
+			changes = append(changes, Change{Key: kv.key, Old: absent, New: kv.value})
+		}
+	}
+	return changes
+}
+
+// keyValue is a key with its printed value and the value unredacted.
+type keyValue struct {
+	key   string
+	value string
+	raw   string
+}
+
+func lookup(values []keyValue, key string) (keyValue, bool) {
+	for _, kv := range values {
+		if kv.key == key {
+			return kv, true
+		}
+	}
+	return keyValue{}, false
+}
+
+// flatten lists the keys of the struct with their values.
+func flatten(v reflect.Value) []keyValue {
+	values := make([]keyValue, 0)
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			key := join(prefix, keyName(field.Name))
+			switch {
+			case fv.Kind() == reflect.Struct:
+				walk(fv, key)
+			case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+				for j := 0; j < fv.Len(); j++ {
+					walk(fv.Index(j), fmt.Sprintf("%s[%d]", key, j))
+				}
+			default:
+				values = append(values, keyValue{
+					key:   key,
+					value: formatValue(fv, field.Tag.Get("secret")),
+					raw:   formatValue(fv, ""),
+				})
+			}
+		}
+	}
+	walk(v, "")
+	return values
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+// Reloader reloads a config on SIGHUP and applies the changes of the keys that are
+// safe to change at runtime. A reload changing any other key is rejected as a whole
+// and the process keeps running with the config it has.
+type Reloader[T any] struct {
+	logger Logger
+	load   func() (T, error)
+	apply  func(T) error
+	safe   []string
+
+	mu      sync.Mutex
+	current T
+}
+
+// NewReloader creates a reloader of the current config. Safe lists the keys that may
+// change, a key covers its subkeys, e.g. "rateLimit" covers "rateLimit.routes[0].rate".
+// Apply is called with the reloaded config if only safe keys changed.
+func NewReloader[T any](logger Logger, current T, load func() (T, error), safe []string,
+	apply func(T) error,
+) *Reloader[T] {
+	return &Reloader[T]{
+		logger:  logger,
+		load:    load,
+		apply:   apply,
+		safe:    safe,
+		current: current,
+	}
+}
+
+// Run reloads the config every time the process receives SIGHUP until ctx is done.
+func (r *Reloader[T]) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			_ = r.Reload()
+		}
+	}
+}
+
+// Reload loads the config and applies its changes, the outcome is logged.
+func (r *Reloader[T]) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return r.reject(err)
+	}
+	changes := Diff(r.current, next)
+	if len(changes) == 0 {
+		r.logger.Info("config reloaded, nothing changed")
+		return nil
+	}
+	unsafe := make([]string, 0)
+	for _, change := range changes {
+		if !r.isSafe(change.Key) {
+			unsafe = append(unsafe, change.Key)
+		}
+	}
+	if len(unsafe) > 0 {
+		return r.reject(fmt.Errorf("%s cannot be changed without a restart", strings.Join(unsafe, ", ")))
+	}
+	if err := r.apply(next); err != nil {
+		return r.reject(err)
+	}
+	r.current = next
+	for _, change := range changes {
+		r.logger.Info("config changed: " + change.String())
+	}
+	return nil
+}
+
+func (r *Reloader[T]) reject(err error) error {
+	r.logger.Error("config reload rejected: " + err.Error())
+	return err
+}
+
+func (r *Reloader[T]) isSafe(key string) bool {
+	for _, safe := range r.safe {
+		if key == safe || strings.HasPrefix(key, safe+".") || strings.HasPrefix(key, safe+"[") {
+			return true
+		}
+	}
+	return false
+}
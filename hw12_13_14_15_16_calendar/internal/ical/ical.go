@@ -0,0 +1,179 @@
+// Package ical writes events as iCalendar files (RFC 5545).
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ContentType is the media type of iCalendar files.
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	prodID        = "-//fixme_my_friend//calendar//EN"
+	dateTimeUTC   = "20060102T150405Z"
+	maxLineOctets = 75
+)
+
+// Event is an event with the name of its category and links to the files attached to it.
+type Event struct {
+	storage.Event
+	// Category is exported along with the tags of the event as CATEGORIES.
+	Category    string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	URL         string
+	ContentType string
+}
+
+// Encode writes the events as a calendar, now is the time the calendar is created at.
+func Encode(w io.Writer, now time.Time, events []Event) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", prodID)
+	e.line("CALSCALE", "GREGORIAN")
+	for _, event := range events {
+		e.event(now, event)
+	}
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(now time.Time, event Event) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", event.ID)
+	e.line("DTSTAMP", now.UTC().Format(dateTimeUTC))
+	e.line("DTSTART", event.StartAt.UTC().Format(dateTimeUTC))
+	e.line("DTEND", event.EndAt.UTC().Format(dateTimeUTC))
+	if event.Version > 0 {
+		// Versions start at 1, sequences at 0.
+		e.line("SEQUENCE", strconv.FormatInt(event.Version-1, 10))
+	}
+	e.text("SUMMARY", event.Title)
+	e.text("DESCRIPTION", event.Description)
+	e.text("LOCATION", event.Location.Address)
+	if event.Location.ConferenceURL != "" {
+		// CONFERENCE is defined by RFC 7986, clients that do not know it use URL.
+		e.line("URL", event.Location.ConferenceURL)
+		e.line("CONFERENCE;VALUE=URI", event.Location.ConferenceURL)
+	}
+	e.categories(event)
+	for _, attachment := range event.Attachments {
+		name := "ATTACH"
+		if attachment.ContentType != "" {
+			name += ";FMTTYPE=" + paramValue(attachment.ContentType)
+		}
+		e.line(name, attachment.URL)
+	}
+	if event.NotifyBefore > 0 {
+		e.line("BEGIN", "VALARM")
+		e.line("ACTION", "DISPLAY")
+		e.text("DESCRIPTION", event.Title)
+		e.line("TRIGGER", "-"+duration(event.NotifyBefore))
+		e.line("END", "VALARM")
+	}
+	e.line("END", "VEVENT")
+}
+
+// text writes a property of type TEXT, empty ones are omitted.
+func (e *encoder) text(name, value string) {
+	if value == "" {
+		return
+	}
+	e.line(name, escapeText(value))
+}
+
+// categories writes the category and the tags of the event as a list of TEXT values.
+func (e *encoder) categories(event Event) {
+	values := make([]string, 0, len(event.Tags)+1)
+	if event.Category != "" {
+		values = append(values, escapeText(event.Category))
+	}
+	for _, tag := range event.Tags {
+		values = append(values, escapeText(tag))
+	}
+	if len(values) > 0 {
+		e.line("CATEGORIES", strings.Join(values, ","))
+	}
+}
+
+// line writes a content line folded to lines of at most 75 octets.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	line := name + ":" + value
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if width+n > maxLineOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	b.WriteString("\r\n")
+	_, e.err = e.w.WriteString(b.String())
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// paramValue quotes parameter values with characters that separate parameters.
+// Quoted values cannot hold quotes, those are dropped.
+func paramValue(s string) string {
+	s = strings.ReplaceAll(s, `"`, "")
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// duration formats a positive duration as an iCalendar duration of whole seconds.
+func duration(d time.Duration) string {
+	secs := int64(d / time.Second)
+	days, secs := secs/86400, secs%86400
+	hours, secs := secs/3600, secs%3600
+	minutes, secs := secs/60, secs%60
+
+	var b strings.Builder
+	b.WriteString("P")
+	if days > 0 {
+		b.WriteString(strconv.FormatInt(days, 10) + "D")
+	}
+	if hours > 0 || minutes > 0 || secs > 0 || days == 0 {
+		b.WriteString("T")
+		if hours > 0 {
+			b.WriteString(strconv.FormatInt(hours, 10) + "H")
+		}
+		if minutes > 0 {
+			b.WriteString(strconv.FormatInt(minutes, 10) + "M")
+		}
+		if secs > 0 || (days == 0 && hours == 0 && minutes == 0) {
+			b.WriteString(strconv.FormatInt(secs, 10) + "S")
+		}
+	}
+	return b.String()
+}
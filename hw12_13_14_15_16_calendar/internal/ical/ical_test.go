@@ -0,0 +1,102 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("events", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Encode(&buf, now, []Event{
+			{
+				Event: storage.Event{
+					ID: "1", Title: "Planning; Q2, part 1", Description: "Agenda:\nitems\\notes",
+					StartAt: start, EndAt: start.Add(90 * time.Minute), NotifyBefore: 15 * time.Minute, Version: 3,
+					Location: storage.Location{Address: "Room 1", ConferenceURL: "https://meet.example.com/1"},
+					Tags:     []string{"1:1", "q2, planning"},
+				},
+				Category: "Work",
+				Attachments: []Attachment{
+					{URL: "https://calendar.example.com/v1/attachments/a/content", ContentType: "text/plain; charset=utf-8"},
+				},
+			},
+			{Event: storage.Event{ID: "2", StartAt: start, EndAt: start.Add(time.Hour)}},
+		}))
+
+		require.Equal(t, strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"PRODID:-//fixme_my_friend//calendar//EN",
+			"CALSCALE:GREGORIAN",
+			"BEGIN:VEVENT",
+			"UID:1",
+			"DTSTAMP:20250301T120000Z",
+			"DTSTART:20250310T070000Z",
+			"DTEND:20250310T083000Z",
+			"SEQUENCE:2",
+			`SUMMARY:Planning\; Q2\, part 1`,
+			`DESCRIPTION:Agenda:\nitems\\notes`,
+			"LOCATION:Room 1",
+			"URL:https://meet.example.com/1",
+			"CONFERENCE;VALUE=URI:https://meet.example.com/1",
+			`CATEGORIES:Work,1:1,q2\, planning`,
+			`ATTACH;FMTTYPE="text/plain; charset=utf-8":https://calendar.example.com/v1/`,
+			" attachments/a/content",
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			`DESCRIPTION:Planning\; Q2\, part 1`,
+			"TRIGGER:-PT15M",
+			"END:VALARM",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:2",
+			"DTSTAMP:20250301T120000Z",
+			"DTSTART:20250310T070000Z",
+			"DTEND:20250310T080000Z",
+			"END:VEVENT",
+			"END:VCALENDAR",
+			"",
+		}, "\r\n"), buf.String())
+	})
+
+	t.Run("folding", func(t *testing.T) {
+		var buf bytes.Buffer
+		title := strings.Repeat("планирование ", 20)
+		require.NoError(t, Encode(&buf, now, []Event{
+			{Event: storage.Event{ID: "1", Title: title, StartAt: start, EndAt: start.Add(time.Hour)}},
+		}))
+
+		var unfolded strings.Builder
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+			require.LessOrEqual(t, len(line), 75)
+			require.True(t, utf8.ValidString(line), line)
+			if strings.HasPrefix(line, " ") {
+				unfolded.WriteString(line[1:])
+			} else {
+				unfolded.WriteString("\n" + line)
+			}
+		}
+		require.Contains(t, unfolded.String(), "\nSUMMARY:"+title+"\n")
+	})
+
+	t.Run("durations", func(t *testing.T) {
+		for d, want := range map[time.Duration]string{
+			time.Second:                  "PT1S",
+			90 * time.Minute:             "PT1H30M",
+			24 * time.Hour:               "P1D",
+			25*time.Hour + 5*time.Second: "P1DT1H5S",
+		} {
+			require.Equal(t, want, duration(d))
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FileLock is a lock for replicas running on the same host, e.g. in local setups.
+// The operating system releases it when its holder exits.
+type FileLock struct {
+	path string
+	file *os.File
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+func (l *FileLock) TryLock(_ context.Context) (bool, error) {
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return false, err
+	}
+	locked, err := tryLockFile(file)
+	if err != nil || !locked {
+		file.Close()
+		return false, err
+	}
+	l.file = file
+	return true, nil
+}
+
+// Check fails if the lock file has been removed or replaced: another replica
+// could take the lock on the new file.
+func (l *FileLock) Check(_ context.Context) error {
+	if l.file == nil {
+		return errors.New("lock is not taken")
+	}
+	held, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(held, current) {
+		return fmt.Errorf("lock file %s was replaced", l.path)
+	}
+	return nil
+}
+
+// Unlock releases the lock by closing the file, the file itself is kept.
+func (l *FileLock) Unlock(_ context.Context) error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
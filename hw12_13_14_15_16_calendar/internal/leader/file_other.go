@@ -0,0 +1,12 @@
+//go:build !unix
+
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLockFile(_ *os.File) (bool, error) {
+	return false, errors.ErrUnsupported
+}
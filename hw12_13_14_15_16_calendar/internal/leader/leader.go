@@ -0,0 +1,98 @@
+package leader
+
+import (
+	"context"
+	"time"
+)
+
+// unlockTimeout bounds releasing the lock when leadership ends.
+const unlockTimeout = 3 * time.Second
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+// Lock is held by at most one of the replicas sharing it. It must be released
+// when its holder dies, so that another replica can take over.
+type Lock interface {
+	// TryLock takes the lock unless another replica holds it and reports whether it is taken.
+	TryLock(ctx context.Context) (bool, error)
+	// Check returns an error if the lock taken by TryLock has been lost.
+	Check(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+// Elector makes sure that only one of the replicas sharing the lock leads at a time.
+type Elector struct {
+	logger   Logger
+	lock     Lock
+	interval time.Duration
+}
+
+// New creates an elector that tries to take the lock and, while leading,
+// checks that the lock is still held every interval.
+func New(logger Logger, lock Lock, interval time.Duration) *Elector {
+	return &Elector{
+		logger:   logger,
+		lock:     lock,
+		interval: interval,
+	}
+}
+
+// Run calls lead whenever the replica becomes the leader until ctx is done. The context
+// passed to lead is canceled when the lock is lost, lead must return once it is done.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		locked, err := e.lock.TryLock(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			e.logger.ErrorContext(ctx, "failed to take leader lock: "+err.Error())
+		case locked:
+			e.logger.InfoContext(ctx, "became the leader")
+			e.lead(ctx, lead)
+			ticker.Reset(e.interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) lead(ctx context.Context, lead func(ctx context.Context)) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for stop := false; !stop; {
+		select {
+		case <-done:
+			stop = true
+		case <-ticker.C:
+			if err := e.lock.Check(leaderCtx); err != nil && ctx.Err() == nil {
+				e.logger.ErrorContext(ctx, "lost the leader lock: "+err.Error())
+				cancel()
+				<-done
+				stop = true
+			}
+		}
+	}
+
+	unlockCtx, cancelUnlock := context.WithTimeout(context.WithoutCancel(ctx), unlockTimeout)
+	defer cancelUnlock()
+	if err := e.lock.Unlock(unlockCtx); err != nil {
+		e.logger.ErrorContext(ctx, "failed to release leader lock: "+err.Error())
+	}
+	e.logger.InfoContext(ctx, "stopped leading")
+}
@@ -0,0 +1,113 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) InfoContext(context.Context, string)  {}
+func (nopLogger) ErrorContext(context.Context, string) {}
+
+// replica counts how many times it started and stopped leading.
+type replica struct {
+	mu      sync.Mutex
+	started int
+	stopped int
+}
+
+func (r *replica) lead(ctx context.Context) {
+	r.mu.Lock()
+	r.started++
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	r.mu.Lock()
+	r.stopped++
+	r.mu.Unlock()
+}
+
+func (r *replica) leading() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started > r.stopped
+}
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+
+	first, second := NewFileLock(path), NewFileLock(path)
+	locked, err := first.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.NoError(t, first.Check(ctx))
+
+	locked, err = second.TryLock(ctx)
+	require.NoError(t, err)
+	require.False(t, locked)
+	require.Error(t, second.Check(ctx))
+
+	require.NoError(t, first.Unlock(ctx))
+	locked, err = second.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	require.NoError(t, os.Remove(path))
+	require.Error(t, second.Check(ctx))
+	require.NoError(t, second.Unlock(ctx))
+}
+
+func TestElector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	interval := 10 * time.Millisecond
+
+	run := func(ctx context.Context) (*replica, chan struct{}) {
+		r := &replica{}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			New(nopLogger{}, NewFileLock(path), interval).Run(ctx, r.lead)
+		}()
+		return r, done
+	}
+
+	ctx, cancelFirst := context.WithCancel(context.Background())
+	first, firstDone := run(ctx)
+	require.Eventually(t, first.leading, time.Second, interval)
+
+	ctx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	second, secondDone := run(ctx)
+	time.Sleep(5 * interval)
+	require.False(t, second.leading())
+
+	t.Run("failover", func(t *testing.T) {
+		cancelFirst()
+		<-firstDone
+		require.False(t, first.leading())
+		require.Eventually(t, second.leading, time.Second, interval)
+	})
+
+	t.Run("lost lock", func(t *testing.T) {
+		// The replaced lock file can be taken by another replica, the leader steps down.
+		require.NoError(t, os.Remove(path))
+		require.Eventually(t, func() bool {
+			second.mu.Lock()
+			defer second.mu.Unlock()
+			return second.stopped == 1
+		}, time.Second, interval)
+		require.Eventually(t, second.leading, time.Second, interval)
+	})
+
+	cancelSecond()
+	<-secondDone
+}
@@ -1,20 +1,123 @@
 package logger
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
-type Logger struct { // TODO
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel converts a config value such as "info" or "WARN" into a Level.
+func ParseLevel(level string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(level, name) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", level)
 }
 
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// New creates a logger writing to stdout. Unknown levels fall back to info.
 func New(level string) *Logger {
-	return &Logger{}
+	return NewWithWriter(level, os.Stdout)
+}
+
+func NewWithWriter(level string, out io.Writer) *Logger {
+	l, _ := ParseLevel(level)
+	return &Logger{
+		out:   out,
+		level: l,
+	}
 }
 
-func (l Logger) Info(msg string) {
-	fmt.Println(msg)
+// SetLevel changes the level of a running logger, unknown levels are rejected.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = lvl
+	return nil
 }
 
-func (l Logger) Error(msg string) {
-	// TODO
+func (l *Logger) Debug(msg string) {
+	l.log(context.Background(), LevelDebug, msg)
 }
 
-// TODO
+func (l *Logger) Info(msg string) {
+	l.log(context.Background(), LevelInfo, msg)
+}
+
+func (l *Logger) Warn(msg string) {
+	l.log(context.Background(), LevelWarn, msg)
+}
+
+func (l *Logger) Error(msg string) {
+	l.log(context.Background(), LevelError, msg)
+}
+
+// DebugContext and the other *Context methods add the trace and span IDs
+// of the span in ctx to the log line.
+func (l *Logger) DebugContext(ctx context.Context, msg string) {
+	l.log(ctx, LevelDebug, msg)
+}
+
+func (l *Logger) InfoContext(ctx context.Context, msg string) {
+	l.log(ctx, LevelInfo, msg)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string) {
+	l.log(ctx, LevelWarn, msg)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string) {
+	l.log(ctx, LevelError, msg)
+}
+
+func (l *Logger) log(ctx context.Context, level Level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fmt.Fprintf(l.out, "%s %-5s trace_id=%s span_id=%s %s\n",
+			time.Now().Format(time.RFC3339), level, sc.TraceID(), sc.SpanID(), msg)
+		return
+	}
+	fmt.Fprintf(l.out, "%s %-5s %s\n", time.Now().Format(time.RFC3339), level, msg)
+}
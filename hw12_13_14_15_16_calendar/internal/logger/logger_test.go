@@ -1,7 +1,67 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
 
 func TestLogger(t *testing.T) {
-	// TODO
+	t.Run("filters by level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logg := NewWithWriter("warn", out)
+
+		logg.Debug("debug message")
+		logg.Info("info message")
+		logg.Warn("warn message")
+		logg.Error("error message")
+
+		require.NotContains(t, out.String(), "debug message")
+		require.NotContains(t, out.String(), "info message")
+		require.Contains(t, out.String(), "WARN  warn message")
+		require.Contains(t, out.String(), "ERROR error message")
+	})
+
+	t.Run("set level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logg := NewWithWriter("warn", out)
+
+		logg.Info("before")
+		require.NoError(t, logg.SetLevel("info"))
+		logg.Info("after")
+		require.Error(t, logg.SetLevel("verbose"))
+		logg.Info("still info")
+
+		require.NotContains(t, out.String(), "before")
+		require.Contains(t, out.String(), "INFO  after")
+		require.Contains(t, out.String(), "INFO  still info")
+	})
+
+	t.Run("parse level", func(t *testing.T) {
+		level, err := ParseLevel("DeBuG")
+		require.NoError(t, err)
+		require.Equal(t, LevelDebug, level)
+
+		_, err = ParseLevel("verbose")
+		require.Error(t, err)
+	})
+
+	t.Run("trace ids", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logg := NewWithWriter("info", out)
+
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  spanID,
+		}))
+
+		logg.InfoContext(ctx, "traced message")
+		require.Contains(t, out.String(),
+			"INFO  trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 traced message")
+	})
 }
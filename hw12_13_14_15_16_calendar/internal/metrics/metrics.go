@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type Config struct {
+	// Addr is the host:port serving metrics for Prometheus at /metrics, empty disables metrics.
+	Addr string
+}
+
+// Setup installs the global meter provider and serves its metrics at conf.Addr.
+// The returned function stops serving metrics.
+func Setup(ctx context.Context, serviceName string, conf Config) (func(context.Context) error, error) {
+	if conf.Addr == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, fmt.Errorf("create metric exporter: %w", err)
+	}
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", conf.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for metrics: %w", err)
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetMeterProvider(provider)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go server.Serve(listener) //nolint:errcheck
+
+	return func(ctx context.Context) error {
+		return errors.Join(server.Shutdown(ctx), provider.Shutdown(ctx))
+	}, nil
+}
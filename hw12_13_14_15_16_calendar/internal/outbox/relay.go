@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/outbox")
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+type Storage interface {
+	ListOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	MarkSent(ctx context.Context, ids []int64) error
+	PurgeOutbox(ctx context.Context, before time.Time) (int, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg queue.Message) error
+}
+
+// Relay publishes outbox messages to the queues of their topics and marks them sent.
+// Messages are published at least once: a crash after publishing a message and
+// before marking it sent publishes it again. Messages of topics without a publisher
+// are marked sent without being published.
+type Relay struct {
+	logger     Logger
+	storage    Storage
+	publishers map[string]Publisher
+	interval   time.Duration
+	batchSize  int
+	retention  time.Duration
+}
+
+// New creates a relay that checks the outbox every interval and publishes up to
+// batchSize messages at a time. Sent messages are kept for retention.
+func New(
+	logger Logger, storage Storage, publishers map[string]Publisher,
+	interval time.Duration, batchSize int, retention time.Duration,
+) *Relay {
+	return &Relay{
+		logger:     logger,
+		storage:    storage,
+		publishers: publishers,
+		interval:   interval,
+		batchSize:  batchSize,
+		retention:  retention,
+	}
+}
+
+// Run relays messages every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.relayAll(ctx); err != nil && ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "outbox relay failed: "+err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relayAll(ctx context.Context) error {
+	for {
+		n, err := r.Relay(ctx)
+		if err != nil {
+			return err
+		}
+		if n < r.batchSize {
+			break
+		}
+	}
+	purged, err := r.storage.PurgeOutbox(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		return fmt.Errorf("purge outbox: %w", err)
+	}
+	if purged > 0 {
+		r.logger.InfoContext(ctx, fmt.Sprintf("purged %d sent outbox messages", purged))
+	}
+	return nil
+}
+
+// Relay publishes a batch of unsent messages in order and returns the number of messages
+// it has marked sent. It stops at the first message that fails to publish.
+func (r *Relay) Relay(ctx context.Context) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "outbox.Relay")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	messages, err := r.storage.ListOutbox(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list outbox: %w", err)
+	}
+	sent := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		if publisher, ok := r.publishers[msg.Topic]; ok {
+			// The message is published within the trace of the change it announces.
+			published := queue.Message{Body: msg.Body, Headers: msg.Headers}
+			if err = publisher.Publish(queue.ExtractTraceContext(ctx, published), published); err != nil {
+				err = fmt.Errorf("publish message %d: %w", msg.ID, err)
+				break
+			}
+		}
+		sent = append(sent, msg.ID)
+	}
+	if len(sent) == 0 {
+		return 0, err
+	}
+	if markErr := r.storage.MarkSent(ctx, sent); markErr != nil {
+		return 0, fmt.Errorf("mark sent: %w", markErr)
+	}
+	return len(sent), err
+}
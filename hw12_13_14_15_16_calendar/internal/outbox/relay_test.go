@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) InfoContext(context.Context, string)  {}
+func (nopLogger) ErrorContext(context.Context, string) {}
+
+// publisher fails after publishing limit messages, a negative limit never fails.
+type publisher struct {
+	messages []queue.Message
+	limit    int
+}
+
+func (p *publisher) Publish(_ context.Context, msg queue.Message) error {
+	if p.limit >= 0 && len(p.messages) == p.limit {
+		return errors.New("queue is down")
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	newStorage := func(t *testing.T) *memorystorage.Storage {
+		t.Helper()
+		s := memorystorage.New()
+		for _, id := range []string{"1", "2", "3"} {
+			require.NoError(t, s.CreateEvent(ctx, storage.Event{
+				ID: id, Title: "event " + id, StartAt: start, EndAt: start.Add(time.Hour), UserID: id,
+			}))
+		}
+		require.NoError(t, s.EnqueueNotification(ctx, "1", storage.OutboxMessage{
+			Topic: storage.TopicNotifications, Body: []byte(`{}`), Headers: map[string]string{"key": "value"},
+		}))
+		return s
+	}
+
+	t.Run("publishes messages to the queues of their topics", func(t *testing.T) {
+		s := newStorage(t)
+		changes, notifications := &publisher{limit: -1}, &publisher{limit: -1}
+		relay := New(nopLogger{}, s, map[string]Publisher{
+			storage.TopicEventChanges:  changes,
+			storage.TopicNotifications: notifications,
+		}, time.Second, 2, 0)
+
+		require.NoError(t, relay.relayAll(ctx))
+		require.Len(t, changes.messages, 3)
+		require.Len(t, notifications.messages, 1)
+		require.Equal(t, "value", notifications.messages[0].Headers["key"])
+
+		messages, err := s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("keeps messages unsent when publish fails", func(t *testing.T) {
+		s := newStorage(t)
+		changes := &publisher{limit: 1}
+		relay := New(nopLogger{}, s, map[string]Publisher{storage.TopicEventChanges: changes}, time.Second, 10, 0)
+
+		n, err := relay.Relay(ctx)
+		require.Error(t, err)
+		require.Equal(t, 1, n)
+
+		messages, err := s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+
+		changes.limit = -1
+		n, err = relay.Relay(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+		require.Len(t, changes.messages, 3)
+	})
+
+	t.Run("purges sent messages", func(t *testing.T) {
+		s := newStorage(t)
+		relay := New(nopLogger{}, s, map[string]Publisher{}, time.Second, 10, -time.Minute)
+
+		require.NoError(t, relay.relayAll(ctx))
+		purged, err := s.PurgeOutbox(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Zero(t, purged)
+	})
+}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type Message struct {
+	Body    []byte
+	Headers map[string]string
+}
+
+// ErrRetry is wrapped by handler errors that are transient, the message is returned
+// to the queue to be delivered again instead of being rejected.
+var ErrRetry = errors.New("retry later")
+
+// Handler processes a consumed message. A returned error rejects the message,
+// unless it wraps ErrRetry.
+type Handler func(ctx context.Context, msg Message) error
+
+// InjectTraceContext stores the trace context of ctx in the message headers.
+func InjectTraceContext(ctx context.Context, msg *Message) {
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+}
+
+// ExtractTraceContext returns ctx continuing the trace stored in the message headers.
+func ExtractTraceContext(ctx context.Context, msg Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+}
@@ -0,0 +1,159 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrNotConnected = errors.New("queue is not connected")
+	ErrClosed       = errors.New("queue connection closed")
+)
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit")
+
+// Queue publishes to a durable direct exchange and consumes from a durable queue
+// bound to it with the queue name as the routing key.
+type Queue struct {
+	url      string
+	exchange string
+	queue    string
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+}
+
+func New(url, exchange, queue string) *Queue {
+	return &Queue{
+		url:      url,
+		exchange: exchange,
+		queue:    queue,
+	}
+}
+
+func (q *Queue) Connect(_ context.Context) error {
+	conn, err := amqp.Dial(q.url)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("open channel: %w", err)
+	}
+	if err := ch.ExchangeDeclare(q.exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("declare exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(q.queue, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("declare queue: %w", err)
+	}
+	if err := ch.QueueBind(q.queue, q.queue, q.exchange, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("bind queue: %w", err)
+	}
+	q.conn = conn
+	q.ch = ch
+	return nil
+}
+
+func (q *Queue) Close() error {
+	if q.conn == nil {
+		return nil
+	}
+	return q.conn.Close()
+}
+
+// Publish sends the message with the current trace context in its headers.
+func (q *Queue) Publish(ctx context.Context, msg queue.Message) (err error) {
+	if q.ch == nil {
+		return ErrNotConnected
+	}
+	ctx, span := tracer.Start(ctx, q.queue+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingSystemRabbitmq, semconv.MessagingDestinationName(q.exchange)))
+	defer func() { endSpan(span, err) }()
+
+	queue.InjectTraceContext(ctx, &msg)
+	return q.ch.PublishWithContext(ctx, q.exchange, q.queue, false, false, amqp.Publishing{
+		Headers:      toTable(msg.Headers),
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         msg.Body,
+	})
+}
+
+// Consume calls handler for every delivered message until ctx is done.
+// Messages are acknowledged after the handler succeeds and dropped if it fails,
+// unless the error wraps queue.ErrRetry, in which case they are requeued.
+func (q *Queue) Consume(ctx context.Context, handler queue.Handler) error {
+	if q.ch == nil {
+		return ErrNotConnected
+	}
+	deliveries, err := q.ch.ConsumeWithContext(ctx, q.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return ErrClosed
+			}
+			if err := q.handle(ctx, d, handler); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (q *Queue) handle(ctx context.Context, d amqp.Delivery, handler queue.Handler) error {
+	msg := queue.Message{Body: d.Body, Headers: fromTable(d.Headers)}
+	ctx, span := tracer.Start(queue.ExtractTraceContext(ctx, msg), q.queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconv.MessagingSystemRabbitmq, semconv.MessagingDestinationName(q.queue)))
+
+	err := handler(ctx, msg)
+	endSpan(span, err)
+	if err != nil {
+		return d.Nack(false, errors.Is(err, queue.ErrRetry))
+	}
+	return d.Ack(false)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func toTable(headers map[string]string) amqp.Table {
+	table := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		table[k] = v
+	}
+	return table
+}
+
+func fromTable(table amqp.Table) map[string]string {
+	headers := make(map[string]string, len(table))
+	for k, v := range table {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	return headers
+}
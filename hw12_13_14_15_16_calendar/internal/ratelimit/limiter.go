@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const sweepInterval = time.Minute
+
+// Rule is a token bucket: Rate tokens per second refill a bucket of Burst tokens.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// Route overrides the default rule for one API method. The same method is
+// named differently by transports, e.g. HTTP "POST /v1/events" and gRPC
+// "/event.EventService/CreateEvent"; both share the same buckets.
+type Route struct {
+	HTTP string
+	GRPC string
+	Rule Rule
+}
+
+type bucketKey struct {
+	rule int
+	key  string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per route and client key (user ID or IP address).
+type Limiter struct {
+	mu        sync.Mutex
+	rules     []Rule
+	routes    map[string]int
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+func New(defaultRule Rule, routes []Route) *Limiter {
+	l := &Limiter{lastSweep: time.Now()}
+	l.setRules(defaultRule, routes)
+	return l
+}
+
+// Update replaces the rules of a running limiter. Buckets are kept per rule, so
+// every client starts over with a full bucket of the new rules.
+func (l *Limiter) Update(defaultRule Rule, routes []Route) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setRules(defaultRule, routes)
+}
+
+func (l *Limiter) setRules(defaultRule Rule, routes []Route) {
+	l.rules = []Rule{defaultRule}
+	l.routes = make(map[string]int)
+	l.buckets = make(map[bucketKey]*bucket)
+	for _, route := range routes {
+		l.rules = append(l.rules, route.Rule)
+		if route.HTTP != "" {
+			l.routes[route.HTTP] = len(l.rules) - 1
+		}
+		if route.GRPC != "" {
+			l.routes[route.GRPC] = len(l.rules) - 1
+		}
+	}
+}
+
+// Allow takes a token from the bucket of every given key and reports whether
+// the request fits into all of them. A rejected request takes no tokens, so that
+// e.g. requests rejected by the bucket of an IP address do not drain the bucket
+// of the user. Empty keys are ignored.
+func (l *Limiter) Allow(route string, keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	ruleIdx := l.routes[route]
+	rule := l.rules[ruleIdx]
+	if rule.Rate <= 0 {
+		return true
+	}
+
+	reservations := make([]*rate.Reservation, 0, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		k := bucketKey{rule: ruleIdx, key: key}
+		b, ok := l.buckets[k]
+		if !ok {
+			b = &bucket{limiter: rate.NewLimiter(rate.Limit(rule.Rate), rule.Burst)}
+			l.buckets[k] = b
+		}
+		b.lastSeen = now
+		r := b.limiter.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			// The reservation took a token even if it has to wait for it.
+			r.CancelAt(now)
+			for i := len(reservations) - 1; i >= 0; i-- {
+				reservations[i].CancelAt(now)
+			}
+			return false
+		}
+		reservations = append(reservations, r)
+	}
+	return true
+}
+
+// sweep drops buckets that have been idle long enough to refill completely,
+// so forgetting them does not change any decision.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		rule := l.rules[k.rule]
+		refill := time.Duration(float64(rule.Burst) / rule.Rate * float64(time.Second))
+		if now.Sub(b.lastSeen) >= refill {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(Rule{Rate: 1, Burst: 2}, []Route{
+		{HTTP: "POST /v1/events", GRPC: "/event.EventService/CreateEvent", Rule: Rule{Rate: 1, Burst: 1}},
+		{HTTP: "GET /v1/events/day", Rule: Rule{}},
+	})
+
+	t.Run("default rule", func(t *testing.T) {
+		require.True(t, l.Allow("GET /v1/events/week", "alice"))
+		require.True(t, l.Allow("GET /v1/events/week", "alice"))
+		require.False(t, l.Allow("GET /v1/events/week", "alice"))
+		require.True(t, l.Allow("GET /v1/events/week", "bob"))
+	})
+
+	t.Run("route is shared by transports", func(t *testing.T) {
+		require.True(t, l.Allow("POST /v1/events", "alice"))
+		require.False(t, l.Allow("/event.EventService/CreateEvent", "alice"))
+	})
+
+	t.Run("every key is limited", func(t *testing.T) {
+		require.True(t, l.Allow("/event.EventService/DeleteEvent", "carol", "10.0.0.1"))
+		require.True(t, l.Allow("/event.EventService/DeleteEvent", "dave", "10.0.0.1"))
+		require.False(t, l.Allow("/event.EventService/DeleteEvent", "erin", "10.0.0.1"))
+	})
+
+	t.Run("rejected requests take no tokens", func(t *testing.T) {
+		l := New(Rule{Rate: 0.001, Burst: 2}, nil)
+		require.True(t, l.Allow("GET /v1/events/week", "frank", "10.0.0.2"))
+		require.True(t, l.Allow("GET /v1/events/week", "grace", "10.0.0.2"))
+		for i := 0; i < 3; i++ {
+			require.False(t, l.Allow("GET /v1/events/week", "frank", "10.0.0.2"))
+		}
+		require.True(t, l.Allow("GET /v1/events/week", "frank", "10.0.0.3"))
+		require.False(t, l.Allow("GET /v1/events/week", "frank", "10.0.0.3"))
+		// The bucket of the IP address is not drained by the rejection above.
+		require.True(t, l.Allow("GET /v1/events/week", "heidi", "10.0.0.3"))
+	})
+
+	t.Run("zero rate is unlimited", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			require.True(t, l.Allow("GET /v1/events/day", "alice"))
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		l := New(Rule{Rate: 1, Burst: 1}, nil)
+		require.True(t, l.Allow("GET /v1/events/week", "alice"))
+		require.False(t, l.Allow("GET /v1/events/week", "alice"))
+
+		l.Update(Rule{Rate: 1, Burst: 1}, []Route{{HTTP: "GET /v1/events/week", Rule: Rule{}}})
+		for i := 0; i < 10; i++ {
+			require.True(t, l.Allow("GET /v1/events/week", "alice"))
+		}
+		require.True(t, l.Allow("GET /v1/events/day", "alice"))
+		require.False(t, l.Allow("GET /v1/events/day", "alice"))
+	})
+}
@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler")
+	meter  = otel.Meter("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler")
+
+	purgedEvents, _ = meter.Int64Counter("calendar.events.purged",
+		metric.WithDescription("Events deleted by retention policies."), metric.WithUnit("{event}"))
+	archivedEvents, _ = meter.Int64Counter("calendar.events.archived",
+		metric.WithDescription("Events archived before deletion."), metric.WithUnit("{event}"))
+	purgedTombstones, _ = meter.Int64Counter("calendar.tombstones.purged",
+		metric.WithDescription("Tombstones of deleted events forgotten."), metric.WithUnit("{tombstone}"))
+	purgedKeys, _ = meter.Int64Counter("calendar.idempotency_keys.purged",
+		metric.WithDescription("Expired idempotency keys forgotten."), metric.WithUnit("{key}"))
+)
+
+// expireBatchSize is the number of expired events archived and deleted at a time.
+const expireBatchSize = 1000
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+type Storage interface {
+	ListEventsToNotify(ctx context.Context, now time.Time) ([]storage.Event, error)
+	EnqueueNotification(ctx context.Context, eventID string, msg storage.OutboxMessage) error
+	ListExpiredEvents(ctx context.Context, filter storage.ExpiryFilter, limit int) ([]storage.Event, error)
+	DeleteEvents(ctx context.Context, events []storage.Event) (int, error)
+	PurgeTombstones(ctx context.Context, before time.Time) (int, error)
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+}
+
+type Archiver interface {
+	Archive(ctx context.Context, events []storage.Event) error
+}
+
+// Retention is how long events are kept after they end, zero keeps them forever.
+type Retention struct {
+	Default time.Duration
+	// Calendars overrides Default for events of the calendars.
+	Calendars map[string]time.Duration
+	// IdempotencyKeys is how long idempotency keys of create requests are kept,
+	// zero keeps them forever.
+	IdempotencyKeys time.Duration
+}
+
+// Scheduler periodically queues notifications for upcoming events in the outbox,
+// archives and deletes events that ended longer than their retention ago and purges
+// tombstones of events deleted longer than the default retention ago and expired
+// idempotency keys.
+type Scheduler struct {
+	logger   Logger
+	storage  Storage
+	archiver Archiver
+
+	mu        sync.Mutex
+	interval  time.Duration
+	retention Retention
+	// reconfigured wakes Run up to reset its ticker to the new interval.
+	reconfigured chan struct{}
+}
+
+// New creates a scheduler. Expired events are deleted without archiving if archiver is nil.
+func New(logger Logger, storage Storage, archiver Archiver, interval time.Duration, retention Retention) *Scheduler {
+	return &Scheduler{
+		logger:       logger,
+		storage:      storage,
+		archiver:     archiver,
+		interval:     interval,
+		retention:    retention,
+		reconfigured: make(chan struct{}, 1),
+	}
+}
+
+// Reconfigure changes the interval and retention of a running scheduler, the next
+// scan is due an interval after the previous one.
+func (s *Scheduler) Reconfigure(interval time.Duration, retention Retention) {
+	s.mu.Lock()
+	s.interval = interval
+	s.retention = retention
+	s.mu.Unlock()
+
+	select {
+	case s.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) settings() (time.Duration, Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval, s.retention
+}
+
+// Run scans the storage every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	interval, _ := s.settings()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Scan(ctx, time.Now()); err != nil {
+			s.logger.ErrorContext(ctx, "scan failed: "+err.Error())
+		}
+		if !s.wait(ctx, ticker) {
+			return
+		}
+	}
+}
+
+// wait waits for the next tick and reports whether it came before ctx was done.
+func (s *Scheduler) wait(ctx context.Context, ticker *time.Ticker) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			return true
+		case <-s.reconfigured:
+			interval, _ := s.settings()
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// Scan queues notifications that are due by now and removes outdated events.
+// Events are marked as notified in the same transaction that queues their notification.
+func (s *Scheduler) Scan(ctx context.Context, now time.Time) (err error) {
+	ctx, span := tracer.Start(ctx, "scheduler.Scan")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	_, retention := s.settings()
+	events, err := s.storage.ListEventsToNotify(ctx, now)
+	if err != nil {
+		return fmt.Errorf("list events to notify: %w", err)
+	}
+	for _, event := range events {
+		if err := s.notify(ctx, event); err != nil {
+			return fmt.Errorf("notify about event %s: %w", event.ID, err)
+		}
+	}
+
+	calendarIDs := slices.Sorted(maps.Keys(retention.Calendars))
+	for _, id := range calendarIDs {
+		if keep := retention.Calendars[id]; keep > 0 {
+			filter := storage.ExpiryFilter{Before: now.Add(-keep), CalendarIDs: []string{id}}
+			if err := s.expire(ctx, id, filter); err != nil {
+				return fmt.Errorf("expire events of calendar %s: %w", id, err)
+			}
+		}
+	}
+	if retention.Default > 0 {
+		filter := storage.ExpiryFilter{Before: now.Add(-retention.Default), ExceptCalendarIDs: calendarIDs}
+		if err := s.expire(ctx, "default", filter); err != nil {
+			return fmt.Errorf("expire events: %w", err)
+		}
+		purged, err := s.storage.PurgeTombstones(ctx, now.Add(-retention.Default))
+		if err != nil {
+			return fmt.Errorf("purge tombstones: %w", err)
+		}
+		purgedTombstones.Add(ctx, int64(purged))
+		if purged > 0 {
+			s.logger.InfoContext(ctx, fmt.Sprintf("purged %d tombstones", purged))
+		}
+	}
+	if retention.IdempotencyKeys > 0 {
+		purged, err := s.storage.PurgeIdempotencyKeys(ctx, now.Add(-retention.IdempotencyKeys))
+		if err != nil {
+			return fmt.Errorf("purge idempotency keys: %w", err)
+		}
+		purgedKeys.Add(ctx, int64(purged))
+		if purged > 0 {
+			s.logger.InfoContext(ctx, fmt.Sprintf("purged %d idempotency keys", purged))
+		}
+	}
+	return nil
+}
+
+// expire archives and deletes the events selected by the filter. Policy is the calendar
+// of the retention policy or "default", it labels the metrics.
+// Contents of attachments of the events are left to the sweep of the API, see app.SweepBlobs.
+func (s *Scheduler) expire(ctx context.Context, policy string, filter storage.ExpiryFilter) error {
+	attrs := metric.WithAttributes(attribute.String("policy", policy))
+	for {
+		events, err := s.storage.ListExpiredEvents(ctx, filter, expireBatchSize)
+		if err != nil {
+			return fmt.Errorf("list expired events: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		// Events are archived first, those modified before they are deleted are archived again later.
+		if s.archiver != nil {
+			if err := s.archiver.Archive(ctx, events); err != nil {
+				return fmt.Errorf("archive events: %w", err)
+			}
+			archivedEvents.Add(ctx, int64(len(events)), attrs)
+		}
+		deleted, err := s.storage.DeleteEvents(ctx, events)
+		if err != nil {
+			return fmt.Errorf("delete expired events: %w", err)
+		}
+		purgedEvents.Add(ctx, int64(deleted), attrs)
+		s.logger.InfoContext(ctx, fmt.Sprintf("deleted %d expired events by %s retention policy", deleted, policy))
+		if len(events) < expireBatchSize || deleted == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) notify(ctx context.Context, event storage.Event) error {
+	ctx, span := tracer.Start(ctx, "scheduler.Notify", trace.WithAttributes(attribute.String("event.id", event.ID)))
+	defer span.End()
+
+	body, err := json.Marshal(storage.Notification{
+		EventID: event.ID,
+		Title:   event.Title,
+		Date:    event.StartAt,
+		UserID:  event.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	// The relay publishes the notification within the trace of the scan.
+	msg := queue.Message{Body: body}
+	queue.InjectTraceContext(ctx, &msg)
+	if err := s.storage.EnqueueNotification(ctx, event.ID, storage.OutboxMessage{
+		Topic:   storage.TopicNotifications,
+		Body:    msg.Body,
+		Headers: msg.Headers,
+	}); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, fmt.Sprintf("notification for event %s queued", event.ID))
+	return nil
+}
@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) InfoContext(context.Context, string)  {}
+func (nopLogger) ErrorContext(context.Context, string) {}
+
+// failingStorage fails to queue notifications.
+type failingStorage struct {
+	*memorystorage.Storage
+}
+
+func (failingStorage) EnqueueNotification(context.Context, string, storage.OutboxMessage) error {
+	return errors.New("storage is down")
+}
+
+func notifications(t *testing.T, s *memorystorage.Storage) []storage.OutboxMessage {
+	t.Helper()
+	messages, err := s.ListOutbox(context.Background(), 100)
+	require.NoError(t, err)
+	result := make([]storage.OutboxMessage, 0)
+	for _, msg := range messages {
+		if msg.Topic == storage.TopicNotifications {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+type archiver struct {
+	events []storage.Event
+	err    error
+}
+
+func (a *archiver) Archive(_ context.Context, events []storage.Event) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.events = append(a.events, events...)
+	return nil
+}
+
+func (a *archiver) ids() []string {
+	ids := make([]string, 0, len(a.events))
+	for _, event := range a.events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+var reader = sdkmetric.NewManualReader()
+
+func init() {
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+}
+
+// purged returns the number of events purged by the policy.
+func purged(t *testing.T, policy string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "calendar.events.purged" {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if v, _ := point.Attributes.Value("policy"); v.AsString() == policy {
+					return point.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 9, 30, 0, 0, time.UTC)
+
+	newStorage := func(t *testing.T) *memorystorage.Storage {
+		t.Helper()
+		s := memorystorage.New()
+		require.NoError(t, s.CreateEvent(ctx, storage.Event{
+			ID: "soon", Title: "standup", UserID: "user", NotifyBefore: time.Hour,
+			StartAt: now.Add(time.Minute), EndAt: now.Add(time.Hour),
+		}))
+		require.NoError(t, s.CreateEvent(ctx, storage.Event{
+			ID: "later", Title: "review", UserID: "user", NotifyBefore: time.Hour,
+			StartAt: now.AddDate(0, 0, 1), EndAt: now.AddDate(0, 0, 1).Add(time.Hour),
+		}))
+		require.NoError(t, s.CreateEvent(ctx, storage.Event{
+			ID: "old", Title: "retro", UserID: "user",
+			StartAt: now.AddDate(-2, 0, 0), EndAt: now.AddDate(-2, 0, 0).Add(time.Hour),
+		}))
+		return s
+	}
+
+	t.Run("queues due notifications once", func(t *testing.T) {
+		s := newStorage(t)
+		sched := New(nopLogger{}, s, nil, time.Minute, Retention{})
+
+		require.NoError(t, sched.Scan(ctx, now))
+		require.NoError(t, sched.Scan(ctx, now))
+		messages := notifications(t, s)
+		require.Len(t, messages, 1)
+
+		var n storage.Notification
+		require.NoError(t, json.Unmarshal(messages[0].Body, &n))
+		require.Equal(t, storage.Notification{
+			EventID: "soon", Title: "standup", Date: now.Add(time.Minute), UserID: "user",
+		}, n)
+	})
+
+	t.Run("keeps events unnotified when queueing fails", func(t *testing.T) {
+		s := newStorage(t)
+		sched := New(nopLogger{}, failingStorage{s}, nil, time.Minute, Retention{})
+
+		require.Error(t, sched.Scan(ctx, now))
+
+		events, err := s.ListEventsToNotify(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("deletes old events", func(t *testing.T) {
+		s := newStorage(t)
+		sched := New(nopLogger{}, s, nil, time.Minute, Retention{Default: 365 * 24 * time.Hour})
+
+		require.NoError(t, sched.Scan(ctx, now))
+
+		_, err := s.GetEvent(ctx, "old")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		_, err = s.GetEvent(ctx, "later")
+		require.NoError(t, err)
+	})
+
+	t.Run("purges expired idempotency keys", func(t *testing.T) {
+		s := newStorage(t)
+		event := storage.Event{
+			ID: "keyed", Title: "sync", UserID: "user",
+			StartAt: now.AddDate(0, 0, 2), EndAt: now.AddDate(0, 0, 2).Add(time.Hour),
+		}
+		_, err := s.CreateEventWithKey(ctx, event, "key", now)
+		require.NoError(t, err)
+		sched := New(nopLogger{}, s, nil, time.Minute, Retention{IdempotencyKeys: 24 * time.Hour})
+
+		require.NoError(t, sched.Scan(ctx, time.Now()))
+		event.ID = "retried"
+		replayed, err := s.CreateEventWithKey(ctx, event, "key", time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, "keyed", replayed.ID)
+
+		require.NoError(t, sched.Scan(ctx, time.Now().Add(25*time.Hour)))
+		purged, err := s.PurgeIdempotencyKeys(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Zero(t, purged)
+		_, err = s.GetEvent(ctx, "keyed")
+		require.NoError(t, err)
+	})
+
+	t.Run("reconfigure", func(t *testing.T) {
+		s := newStorage(t)
+		sched := New(nopLogger{}, s, nil, time.Minute, Retention{})
+
+		require.NoError(t, sched.Scan(ctx, now))
+		_, err := s.GetEvent(ctx, "old")
+		require.NoError(t, err)
+
+		sched.Reconfigure(time.Hour, Retention{Default: 365 * 24 * time.Hour})
+		require.NoError(t, sched.Scan(ctx, now))
+		_, err = s.GetEvent(ctx, "old")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("archives expired events by calendar policies", func(t *testing.T) {
+		s := newStorage(t)
+		for _, id := range []string{"short", "forever"} {
+			require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: id, Name: id}, "user"))
+			require.NoError(t, s.CreateEvent(ctx, storage.Event{
+				ID: id, Title: id, UserID: "user", CalendarID: id,
+				StartAt: now.AddDate(0, -2, 0), EndAt: now.AddDate(0, -2, 0).Add(time.Hour),
+			}))
+		}
+		a := &archiver{}
+		purgedBefore := purged(t, "default")
+		sched := New(nopLogger{}, s, a, time.Minute, Retention{
+			Default:   365 * 24 * time.Hour,
+			Calendars: map[string]time.Duration{"short": 30 * 24 * time.Hour, "forever": 0},
+		})
+
+		require.NoError(t, sched.Scan(ctx, now))
+		require.Equal(t, []string{"short", "old"}, a.ids())
+		_, err := s.GetEvent(ctx, "short")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		_, err = s.GetEvent(ctx, "forever")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged(t, "short"))
+		require.Equal(t, purgedBefore+1, purged(t, "default"))
+
+		a.err = errors.New("disk is full")
+		require.NoError(t, s.CreateEvent(ctx, storage.Event{
+			ID: "ended", Title: "ended", UserID: "user",
+			StartAt: now.AddDate(-1, -1, 0), EndAt: now.AddDate(-1, -1, 0).Add(time.Hour),
+		}))
+		require.Error(t, sched.Scan(ctx, now))
+		_, err = s.GetEvent(ctx, "ended")
+		require.NoError(t, err)
+	})
+}
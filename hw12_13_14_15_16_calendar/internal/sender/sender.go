@@ -0,0 +1,112 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// checkAttempts is how many times the event of a notification is looked up before
+// the notification is returned to the queue.
+const checkAttempts = 3
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+type Consumer interface {
+	Consume(ctx context.Context, handler queue.Handler) error
+}
+
+// Sink delivers a notification to its user.
+type Sink interface {
+	Send(ctx context.Context, n storage.Notification) error
+}
+
+// Storage tells whether the events of notifications still exist.
+type Storage interface {
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+}
+
+// Sender delivers notifications consumed from the queue to the sink. Notifications
+// of events deleted after they were queued, e.g. with the data of an erased user,
+// are dropped. Notifications whose event cannot be looked up are returned to the queue.
+type Sender struct {
+	logger     Logger
+	consumer   Consumer
+	storage    Storage
+	retryDelay time.Duration
+
+	mu   sync.Mutex
+	sink Sink
+}
+
+func New(logger Logger, consumer Consumer, storage Storage, sink Sink) *Sender {
+	return &Sender{
+		logger:     logger,
+		consumer:   consumer,
+		storage:    storage,
+		retryDelay: time.Second,
+		sink:       sink,
+	}
+}
+
+// SetSink replaces the sink of a running sender, deliveries in progress finish
+// with the previous one.
+func (s *Sender) SetSink(sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink = sink
+}
+
+// Run consumes notifications until ctx is done.
+func (s *Sender) Run(ctx context.Context) error {
+	return s.consumer.Consume(ctx, s.send)
+}
+
+func (s *Sender) send(ctx context.Context, msg queue.Message) error {
+	var n storage.Notification
+	if err := json.Unmarshal(msg.Body, &n); err != nil {
+		s.logger.ErrorContext(ctx, "invalid notification: "+err.Error())
+		return err
+	}
+	if err := s.checkEvent(ctx, n.EventID); err != nil {
+		if errors.Is(err, storage.ErrEventNotFound) {
+			s.logger.InfoContext(ctx, fmt.Sprintf("dropped notification of deleted event %s", n.EventID))
+			return nil
+		}
+		s.logger.ErrorContext(ctx, fmt.Sprintf("failed to check event %s: %s", n.EventID, err))
+		return fmt.Errorf("%w: %w", queue.ErrRetry, err)
+	}
+	s.mu.Lock()
+	sink := s.sink
+	s.mu.Unlock()
+	if err := sink.Send(ctx, n); err != nil {
+		s.logger.ErrorContext(ctx, fmt.Sprintf("failed to notify user %s of event %s: %s", n.UserID, n.EventID, err))
+		return err
+	}
+	return nil
+}
+
+// checkEvent tells whether the event still exists, retrying the lookup while the
+// storage fails.
+func (s *Sender) checkEvent(ctx context.Context, id string) error {
+	for attempt := 1; ; attempt++ {
+		_, err := s.storage.GetEvent(ctx, id)
+		if err == nil || errors.Is(err, storage.ErrEventNotFound) || attempt == checkAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * s.retryDelay):
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type consumer struct {
+	messages []queue.Message
+}
+
+func (c *consumer) Consume(ctx context.Context, handler queue.Handler) error {
+	for _, msg := range c.messages {
+		if err := handler(queue.ExtractTraceContext(ctx, msg), msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSender(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	out := &bytes.Buffer{}
+	logg := logger.NewWithWriter("info", out)
+	c := &consumer{messages: []queue.Message{{
+		Body: []byte(`{"eventId":"1","title":"standup","date":"2025-03-10T10:00:00Z","userId":"user"}`),
+		Headers: map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+	}}}
+
+	store := memorystorage.New()
+	require.NoError(t, store.CreateEvent(context.Background(), storage.Event{
+		ID: "1", Title: "standup", UserID: "user", StartAt: time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC),
+		EndAt: time.Date(2025, 3, 10, 11, 0, 0, 0, time.UTC),
+	}))
+
+	require.NoError(t, New(logg, c, store, NewLogSink(logg)).Run(context.Background()))
+	require.Contains(t, out.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736")
+	require.Contains(t, out.String(), `notify user user: event "standup" (1) starts at 2025-03-10T10:00:00Z`)
+
+	other := &bytes.Buffer{}
+	s := New(logg, c, store, NewLogSink(logg))
+	s.SetSink(NewLogSink(logger.NewWithWriter("info", other)))
+	require.NoError(t, s.Run(context.Background()))
+	require.Contains(t, other.String(), `notify user user: event "standup" (1)`)
+
+	// Notifications of events deleted since, e.g. by erasing the data of their user, are dropped.
+	require.NoError(t, store.DeleteEvent(context.Background(), "1"))
+	dropped := &bytes.Buffer{}
+	s.SetSink(NewLogSink(logger.NewWithWriter("info", dropped)))
+	require.NoError(t, s.Run(context.Background()))
+	require.Empty(t, dropped.String())
+	require.Contains(t, out.String(), "dropped notification of deleted event 1")
+
+	c.messages = []queue.Message{{Body: []byte("not json")}}
+	require.Error(t, New(logg, c, store, NewLogSink(logg)).Run(context.Background()))
+}
+
+// flakyStorage fails the first lookups of events.
+type flakyStorage struct {
+	Storage
+	failures int
+}
+
+func (s *flakyStorage) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	if s.failures > 0 {
+		s.failures--
+		return storage.Event{}, errors.New("connection refused")
+	}
+	return s.Storage.GetEvent(ctx, id)
+}
+
+func TestSenderStorageFailures(t *testing.T) {
+	logg := logger.NewWithWriter("info", io.Discard)
+	c := &consumer{messages: []queue.Message{{
+		Body: []byte(`{"eventId":"1","title":"standup","date":"2025-03-10T10:00:00Z","userId":"user"}`),
+	}}}
+	store := memorystorage.New()
+	require.NoError(t, store.CreateEvent(context.Background(), storage.Event{
+		ID: "1", Title: "standup", UserID: "user", StartAt: time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC),
+		EndAt: time.Date(2025, 3, 10, 11, 0, 0, 0, time.UTC),
+	}))
+
+	t.Run("retried", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		s := New(logg, c, &flakyStorage{Storage: store, failures: checkAttempts - 1},
+			NewLogSink(logger.NewWithWriter("info", out)))
+		s.retryDelay = time.Millisecond
+		require.NoError(t, s.Run(context.Background()))
+		require.Contains(t, out.String(), `notify user user: event "standup" (1)`)
+	})
+
+	t.Run("requeued", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		s := New(logg, c, &flakyStorage{Storage: store, failures: checkAttempts},
+			NewLogSink(logger.NewWithWriter("info", out)))
+		s.retryDelay = time.Millisecond
+		require.ErrorIs(t, s.Run(context.Background()), queue.ErrRetry)
+		require.Empty(t, out.String())
+	})
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []storage.Notification
+	status := http.StatusNoContent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var n storage.Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received = append(received, n)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL, time.Second)
+	n := storage.Notification{
+		EventID: "1",
+		Title:   "standup",
+		Date:    time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC),
+		UserID:  "user",
+	}
+	require.NoError(t, sink.Send(context.Background(), n))
+	require.Equal(t, []storage.Notification{n}, received)
+
+	status = http.StatusBadGateway
+	require.ErrorContains(t, sink.Send(context.Background(), n), "502")
+}
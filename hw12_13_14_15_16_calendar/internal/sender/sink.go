@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// LogSink delivers notifications as log lines carrying the trace of the request
+// that created the event.
+type LogSink struct {
+	logger Logger
+}
+
+func NewLogSink(logger Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Send(ctx context.Context, n storage.Notification) error {
+	s.logger.InfoContext(ctx, fmt.Sprintf("notify user %s: event %q (%s) starts at %s",
+		n.UserID, n.Title, n.EventID, n.Date.Format(time.RFC3339)))
+	return nil
+}
+
+// WebhookSink delivers notifications as JSON POSTed to a URL, any status but 2xx fails
+// the delivery. Requests carry the trace context in their headers.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+func (s *WebhookSink) Send(ctx context.Context, n storage.Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
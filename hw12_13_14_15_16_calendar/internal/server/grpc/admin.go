@@ -0,0 +1,122 @@
+package internalgrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Service) ExportUserData(ctx context.Context, req *eventpb.UserDataRequest) (*eventpb.UserDataArchive, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	data, err := s.app.ExportUserData(ctx, userID(ctx), req.GetUserId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	archive := &eventpb.UserDataArchive{
+		UserId:        data.UserID,
+		ExportedAt:    timestamppb.New(time.Now()),
+		Events:        make([]*eventpb.Event, 0, len(data.Events)),
+		Attachments:   make([]*eventpb.Attachment, 0, len(data.Attachments)),
+		Calendars:     make([]*eventpb.Calendar, 0, len(data.Calendars)),
+		Categories:    make([]*eventpb.Category, 0, len(data.Categories)),
+		History:       make([]*eventpb.EventChange, 0, len(data.History)),
+		Notifications: make([]*eventpb.Notification, 0, len(data.Notifications)),
+	}
+	for _, event := range data.Events {
+		archive.Events = append(archive.Events, toProto(event))
+	}
+	for _, attachment := range data.Attachments {
+		archive.Attachments = append(archive.Attachments, attachmentToProto(attachment))
+	}
+	for _, calendar := range data.Calendars {
+		archive.Calendars = append(archive.Calendars, calendarToProto(calendar))
+	}
+	for _, category := range data.Categories {
+		archive.Categories = append(archive.Categories, categoryToProto(category))
+	}
+	if data.Availability != nil {
+		archive.Availability = availabilityToProto(*data.Availability)
+	}
+	for _, change := range data.History {
+		archive.History = append(archive.History, changeToProto(change))
+	}
+	for _, n := range data.Notifications {
+		archive.Notifications = append(archive.Notifications, &eventpb.Notification{
+			EventId: n.EventID,
+			Title:   n.Title,
+			Date:    timestamppb.New(n.Date),
+		})
+	}
+	return archive, nil
+}
+
+func (s *Service) EraseUserData(ctx context.Context, req *eventpb.UserDataRequest) (*eventpb.AuditRecord, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	record, err := s.app.EraseUserData(ctx, userID(ctx), req.GetUserId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return auditRecordToProto(record), nil
+}
+
+func (s *Service) ListAuditRecords(
+	ctx context.Context, req *eventpb.ListAuditRecordsRequest,
+) (*eventpb.ListAuditRecordsResponse, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	records, err := s.app.ListAuditRecords(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListAuditRecordsResponse{Records: make([]*eventpb.AuditRecord, 0, len(records))}
+	for _, record := range records {
+		resp.Records = append(resp.Records, auditRecordToProto(record))
+	}
+	return resp, nil
+}
+
+// checkAdmin checks that the caller is an admin.
+func (s *Service) checkAdmin(ctx context.Context) error {
+	caller := userID(ctx)
+	if caller == "" {
+		return s.toStatus(ctx, app.ErrNoUser)
+	}
+	if _, ok := s.admins[caller]; !ok {
+		return s.toStatus(ctx, fmt.Errorf("%w: admin access required", app.ErrForbidden))
+	}
+	return nil
+}
+
+func auditRecordToProto(record storage.AuditRecord) *eventpb.AuditRecord {
+	counts := make(map[string]int32, len(record.Counts))
+	for kind, n := range record.Counts {
+		counts[kind] = int32(n) //nolint:gosec // counts fit in int32
+	}
+	return &eventpb.AuditRecord{
+		Id:      record.ID,
+		Time:    timestamppb.New(record.Time),
+		ActorId: record.ActorID,
+		Action:  string(record.Action),
+		UserId:  record.UserID,
+		Counts:  counts,
+	}
+}
@@ -0,0 +1,153 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// downloadChunkSize keeps messages well below the default 4MB limit of gRPC clients.
+const downloadChunkSize = 64 * 1024
+
+type uploadStream = grpc.ClientStreamingServer[eventpb.UploadAttachmentRequest, eventpb.Attachment]
+
+func (s *Service) UploadAttachment(stream uploadStream) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "metadata is required")
+	}
+	if err != nil {
+		return err
+	}
+	metadata := first.GetMetadata()
+	switch {
+	case metadata == nil:
+		return status.Error(codes.InvalidArgument, "the first message must carry metadata")
+	case metadata.GetEventId() == "":
+		return status.Error(codes.InvalidArgument, "event id is required")
+	}
+
+	content := &chunkReader{stream: stream}
+	attachment, err := s.app.AddAttachment(ctx, userID(ctx), storage.Attachment{
+		EventID:     metadata.GetEventId(),
+		Name:        metadata.GetName(),
+		ContentType: metadata.GetContentType(),
+	}, content)
+	if content.err != nil {
+		return content.err
+	}
+	if err != nil {
+		return s.toStatus(ctx, err)
+	}
+	return stream.SendAndClose(attachmentToProto(attachment))
+}
+
+func (s *Service) DownloadAttachment(
+	req *eventpb.DownloadAttachmentRequest, stream grpc.ServerStreamingServer[eventpb.AttachmentChunk],
+) error {
+	ctx := stream.Context()
+	if req.GetId() == "" {
+		return status.Error(codes.InvalidArgument, "id is required")
+	}
+	attachment, content, err := s.app.OpenAttachment(ctx, userID(ctx), req.GetId())
+	if err != nil {
+		return s.toStatus(ctx, err)
+	}
+	defer content.Close()
+
+	if err := stream.Send(&eventpb.AttachmentChunk{Attachment: attachmentToProto(attachment)}); err != nil {
+		return err
+	}
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&eventpb.AttachmentChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return s.toStatus(ctx, err)
+		}
+	}
+}
+
+func (s *Service) ListAttachments(
+	ctx context.Context, req *eventpb.ListAttachmentsRequest,
+) (*eventpb.ListAttachmentsResponse, error) {
+	if req.GetEventId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "event id is required")
+	}
+	attachments, err := s.app.ListAttachments(ctx, userID(ctx), req.GetEventId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListAttachmentsResponse{Attachments: make([]*eventpb.Attachment, 0, len(attachments))}
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, attachmentToProto(attachment))
+	}
+	return resp, nil
+}
+
+func (s *Service) DeleteAttachment(ctx context.Context, req *eventpb.DeleteAttachmentRequest) (*emptypb.Empty, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.app.DeleteAttachment(ctx, userID(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// chunkReader reads the content of an upload from the messages following the metadata.
+// Failures of the stream are kept in err to be returned as they are.
+type chunkReader struct {
+	stream uploadStream
+	chunk  []byte
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return 0, io.EOF
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if req.GetMetadata() != nil {
+			r.err = status.Error(codes.InvalidArgument, "metadata must be sent only once")
+			return 0, r.err
+		}
+		r.chunk = req.GetChunk()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func attachmentToProto(attachment storage.Attachment) *eventpb.Attachment {
+	return &eventpb.Attachment{
+		Id:          attachment.ID,
+		EventId:     attachment.EventID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		UserId:      attachment.UserID,
+		CreatedAt:   timestamppb.New(attachment.CreatedAt),
+	}
+}
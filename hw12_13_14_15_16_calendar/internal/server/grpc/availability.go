@@ -0,0 +1,118 @@
+package internalgrpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var slotStatuses = map[app.SlotStatus]eventpb.AvailabilityStatus{
+	app.SlotFree:        eventpb.AvailabilityStatus_AVAILABILITY_STATUS_FREE,
+	app.SlotBusy:        eventpb.AvailabilityStatus_AVAILABILITY_STATUS_BUSY,
+	app.SlotOutOfOffice: eventpb.AvailabilityStatus_AVAILABILITY_STATUS_OUT_OF_OFFICE,
+	app.SlotOffHours:    eventpb.AvailabilityStatus_AVAILABILITY_STATUS_OFF_HOURS,
+}
+
+func (s *Service) GetAvailabilitySettings(
+	ctx context.Context, _ *emptypb.Empty,
+) (*eventpb.AvailabilitySettings, error) {
+	availability, err := s.app.GetAvailability(ctx, userID(ctx))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return availabilityToProto(availability), nil
+}
+
+func (s *Service) SetAvailabilitySettings(
+	ctx context.Context, req *eventpb.AvailabilitySettings,
+) (*eventpb.AvailabilitySettings, error) {
+	availability := storage.Availability{
+		TimeZone:     req.GetTimeZone(),
+		WorkingHours: make([]storage.WorkingHours, 0, len(req.GetWorkingHours())),
+	}
+	for _, h := range req.GetWorkingHours() {
+		start, err := parseTimeOfDay(h.GetStart())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		end, err := parseTimeOfDay(h.GetEnd())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		availability.WorkingHours = append(availability.WorkingHours, storage.WorkingHours{
+			Weekday: time.Weekday(h.GetWeekday()),
+			Start:   start,
+			End:     end,
+		})
+	}
+	set, err := s.app.SetAvailability(ctx, userID(ctx), availability)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return availabilityToProto(set), nil
+}
+
+func (s *Service) QueryAvailability(
+	ctx context.Context, req *eventpb.QueryAvailabilityRequest,
+) (*eventpb.QueryAvailabilityResponse, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	slots, err := s.app.QueryAvailability(ctx, userID(ctx), req.GetUserId(),
+		req.GetFrom().AsTime(), req.GetTo().AsTime())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.QueryAvailabilityResponse{Slots: make([]*eventpb.AvailabilitySlot, 0, len(slots))}
+	for _, slot := range slots {
+		resp.Slots = append(resp.Slots, &eventpb.AvailabilitySlot{
+			StartAt: timestamppb.New(slot.Start),
+			EndAt:   timestamppb.New(slot.End),
+			Status:  slotStatuses[slot.Status],
+		})
+	}
+	return resp, nil
+}
+
+func availabilityToProto(availability storage.Availability) *eventpb.AvailabilitySettings {
+	settings := &eventpb.AvailabilitySettings{
+		TimeZone:     availability.TimeZone,
+		WorkingHours: make([]*eventpb.WorkingHours, 0, len(availability.WorkingHours)),
+	}
+	for _, h := range availability.WorkingHours {
+		settings.WorkingHours = append(settings.WorkingHours, &eventpb.WorkingHours{
+			Weekday: int32(h.Weekday),
+			Start:   formatTimeOfDay(h.Start),
+			End:     formatTimeOfDay(h.End),
+		})
+	}
+	return settings
+}
+
+// parseTimeOfDay parses "HH:MM" into the offset from midnight, "24:00" is the end of the day.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if ok && len(hh) == 2 && len(mm) == 2 {
+		hours, errH := strconv.Atoi(hh)
+		minutes, errM := strconv.Atoi(mm)
+		if errH == nil && errM == nil && minutes >= 0 && minutes < 60 &&
+			hours >= 0 && (hours < 24 || hours == 24 && minutes == 0) {
+			return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
@@ -0,0 +1,68 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Service) BatchEvents(ctx context.Context, req *eventpb.BatchEventsRequest) (*eventpb.BatchEventsResponse, error) {
+	ops := make([]storage.BatchOp, 0, len(req.GetOperations()))
+	for i, op := range req.GetOperations() {
+		batchOp, err := fromBatchProto(op)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("operation %d: %s", i, err))
+		}
+		ops = append(ops, batchOp)
+	}
+
+	results, err := s.app.ApplyBatch(ctx, userID(ctx), ops)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.BatchEventsResponse{
+		Applied: true,
+		Results: make([]*eventpb.BatchResult, 0, len(results)),
+	}
+	for _, result := range results {
+		item := &eventpb.BatchResult{}
+		if result.Err != nil {
+			st := status.Convert(s.toStatus(ctx, result.Err))
+			item.Code, item.Message = int32(st.Code()), st.Message() //nolint:gosec // codes fit in int32
+			resp.Applied = false
+		} else if result.Event.ID != "" {
+			item.Event = toProto(result.Event)
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	return resp, nil
+}
+
+func fromBatchProto(op *eventpb.BatchOperation) (storage.BatchOp, error) {
+	switch {
+	case op.GetCreate() != nil:
+		return storage.BatchOp{Kind: storage.ChangeCreated, Event: fromProto(op.GetCreate())}, nil
+	case op.GetUpdate() != nil:
+		update := op.GetUpdate()
+		if update.GetId() == "" {
+			return storage.BatchOp{}, errors.New("id is required")
+		}
+		if update.GetEvent() == nil {
+			return storage.BatchOp{}, errors.New("event is required")
+		}
+		event := fromProto(update.GetEvent())
+		event.ID = update.GetId()
+		return storage.BatchOp{Kind: storage.ChangeUpdated, Event: event}, nil
+	case op.GetDelete() != nil:
+		if op.GetDelete().GetId() == "" {
+			return storage.BatchOp{}, errors.New("id is required")
+		}
+		return storage.BatchOp{Kind: storage.ChangeDeleted, Event: storage.Event{ID: op.GetDelete().GetId()}}, nil
+	}
+	return storage.BatchOp{}, errors.New("operation is required")
+}
@@ -0,0 +1,113 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var roles = map[storage.Role]eventpb.Role{
+	storage.RoleOwner:    eventpb.Role_ROLE_OWNER,
+	storage.RoleEditor:   eventpb.Role_ROLE_EDITOR,
+	storage.RoleViewer:   eventpb.Role_ROLE_VIEWER,
+	storage.RoleFreeBusy: eventpb.Role_ROLE_FREE_BUSY,
+}
+
+func (s *Service) CreateCalendar(ctx context.Context, req *eventpb.CreateCalendarRequest) (*eventpb.Calendar, error) {
+	calendar, err := s.app.CreateCalendar(ctx, userID(ctx), req.GetName())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return calendarToProto(calendar), nil
+}
+
+func (s *Service) ListCalendars(ctx context.Context, _ *emptypb.Empty) (*eventpb.ListCalendarsResponse, error) {
+	calendars, err := s.app.ListCalendars(ctx, userID(ctx))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListCalendarsResponse{Calendars: make([]*eventpb.Calendar, 0, len(calendars))}
+	for _, calendar := range calendars {
+		resp.Calendars = append(resp.Calendars, calendarToProto(calendar))
+	}
+	return resp, nil
+}
+
+func (s *Service) DeleteCalendar(ctx context.Context, req *eventpb.DeleteCalendarRequest) (*emptypb.Empty, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.app.DeleteCalendar(ctx, userID(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Service) ShareCalendar(ctx context.Context, req *eventpb.ShareCalendarRequest) (*eventpb.Member, error) {
+	if req.GetCalendarId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "calendar id is required")
+	}
+	member := storage.Member{
+		CalendarID: req.GetCalendarId(),
+		UserID:     req.GetUserId(),
+		Role:       roleFromProto(req.GetRole()),
+	}
+	if err := s.app.ShareCalendar(ctx, userID(ctx), member); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return memberToProto(member), nil
+}
+
+func (s *Service) UnshareCalendar(ctx context.Context, req *eventpb.UnshareCalendarRequest) (*emptypb.Empty, error) {
+	if req.GetCalendarId() == "" || req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "calendar id and user id are required")
+	}
+	if err := s.app.UnshareCalendar(ctx, userID(ctx), req.GetCalendarId(), req.GetUserId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Service) ListMembers(ctx context.Context, req *eventpb.ListMembersRequest) (*eventpb.ListMembersResponse, error) {
+	if req.GetCalendarId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "calendar id is required")
+	}
+	members, err := s.app.ListMembers(ctx, userID(ctx), req.GetCalendarId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListMembersResponse{Members: make([]*eventpb.Member, 0, len(members))}
+	for _, member := range members {
+		resp.Members = append(resp.Members, memberToProto(member))
+	}
+	return resp, nil
+}
+
+func roleFromProto(role eventpb.Role) storage.Role {
+	for r, pb := range roles {
+		if pb == role {
+			return r
+		}
+	}
+	return ""
+}
+
+func calendarToProto(calendar storage.UserCalendar) *eventpb.Calendar {
+	return &eventpb.Calendar{
+		Id:   calendar.ID,
+		Name: calendar.Name,
+		Role: roles[calendar.Role],
+	}
+}
+
+func memberToProto(member storage.Member) *eventpb.Member {
+	return &eventpb.Member{
+		CalendarId: member.CalendarID,
+		UserId:     member.UserID,
+		Role:       roles[member.Role],
+	}
+}
@@ -0,0 +1,68 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *Service) CreateCategory(ctx context.Context, req *eventpb.Category) (*eventpb.Category, error) {
+	category, err := s.app.CreateCategory(ctx, userID(ctx), categoryFromProto(req))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return categoryToProto(category), nil
+}
+
+func (s *Service) ListCategories(ctx context.Context, _ *emptypb.Empty) (*eventpb.ListCategoriesResponse, error) {
+	categories, err := s.app.ListCategories(ctx, userID(ctx))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListCategoriesResponse{Categories: make([]*eventpb.Category, 0, len(categories))}
+	for _, category := range categories {
+		resp.Categories = append(resp.Categories, categoryToProto(category))
+	}
+	return resp, nil
+}
+
+func (s *Service) UpdateCategory(ctx context.Context, req *eventpb.Category) (*eventpb.Category, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	category, err := s.app.UpdateCategory(ctx, userID(ctx), categoryFromProto(req))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return categoryToProto(category), nil
+}
+
+func (s *Service) DeleteCategory(ctx context.Context, req *eventpb.DeleteCategoryRequest) (*emptypb.Empty, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.app.DeleteCategory(ctx, userID(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func categoryFromProto(category *eventpb.Category) storage.Category {
+	return storage.Category{
+		ID:    category.GetId(),
+		Name:  category.GetName(),
+		Color: category.GetColor(),
+	}
+}
+
+func categoryToProto(category storage.Category) *eventpb.Category {
+	return &eventpb.Category{
+		Id:    category.ID,
+		Name:  category.Name,
+		Color: category.Color,
+	}
+}
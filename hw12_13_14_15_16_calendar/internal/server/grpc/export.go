@@ -0,0 +1,46 @@
+package internalgrpc
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Service) ExportEvents(ctx context.Context, req *eventpb.ExportEventsRequest) (*httpbody.HttpBody, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	filter := app.EventFilter{CategoryIDs: req.GetCategoryIds(), Tags: req.GetTags()}
+	exported, err := s.app.ExportEvents(ctx, userID(ctx), req.GetCalendarIds(), filter,
+		req.GetFrom().AsTime(), req.GetTo().AsTime())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	events := make([]ical.Event, 0, len(exported))
+	for _, event := range exported {
+		e := ical.Event{Event: event.Event, Category: event.Category.Name}
+		// Attachments are linked only if the service knows where clients reach it.
+		if s.publicURL != "" {
+			for _, attachment := range event.Attachments {
+				e.Attachments = append(e.Attachments, ical.Attachment{
+					URL:         s.publicURL + "/v1/attachments/" + attachment.ID + "/content",
+					ContentType: attachment.ContentType,
+				})
+			}
+		}
+		events = append(events, e)
+	}
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, time.Now(), events); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &httpbody.HttpBody{ContentType: ical.ContentType, Data: buf.Bytes()}, nil
+}
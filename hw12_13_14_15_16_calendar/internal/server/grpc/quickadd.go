@@ -0,0 +1,26 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+)
+
+func (s *Service) QuickAdd(ctx context.Context, req *eventpb.QuickAddRequest) (*eventpb.QuickAddResponse, error) {
+	draft, events, err := s.app.QuickAdd(ctx, userID(ctx), req.GetCalendarId(), req.GetText(),
+		int(req.GetOccurrences()), req.GetCreate())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.QuickAddResponse{
+		Events:      make([]*eventpb.Event, 0, len(events)),
+		Created:     req.GetCreate(),
+		AllDay:      draft.AllDay,
+		Weekly:      draft.Weekly,
+		Occurrences: int32(draft.Occurrences), //nolint:gosec // at most app.MaxWeeklyOccurrences
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toProto(event))
+	}
+	return resp, nil
+}
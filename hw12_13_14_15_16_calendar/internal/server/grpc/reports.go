@@ -0,0 +1,87 @@
+package internalgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// csvContentType is the media type of exported reports.
+const csvContentType = "text/csv; charset=utf-8"
+
+var reportPeriods = map[eventpb.ReportPeriod]app.ReportPeriod{
+	eventpb.ReportPeriod_REPORT_PERIOD_UNSPECIFIED: app.ReportWeek,
+	eventpb.ReportPeriod_REPORT_PERIOD_WEEK:        app.ReportWeek,
+	eventpb.ReportPeriod_REPORT_PERIOD_MONTH:       app.ReportMonth,
+}
+
+func (s *Service) GetReport(ctx context.Context, req *eventpb.ReportRequest) (*eventpb.Report, error) {
+	usage, err := s.report(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	report := &eventpb.Report{Rows: make([]*eventpb.Usage, 0, len(usage))}
+	for _, u := range usage {
+		report.Rows = append(report.Rows, &eventpb.Usage{
+			PeriodStart: timestamppb.New(u.Period.Start),
+			PeriodEnd:   timestamppb.New(u.Period.End),
+			UserId:      u.UserID,
+			Tag:         u.Tag,
+			Events:      int32(u.Events), //nolint:gosec // events fit in int32
+			Duration:    durationpb.New(u.Duration),
+		})
+	}
+	return report, nil
+}
+
+func (s *Service) ExportReport(ctx context.Context, req *eventpb.ReportRequest) (*httpbody.HttpBody, error) {
+	usage, err := s.report(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"period_start", "period_end", "user_id", "tag", "events", "hours"})
+	for _, u := range usage {
+		_ = w.Write([]string{
+			u.Period.Start.Format(time.RFC3339),
+			u.Period.End.Format(time.RFC3339),
+			u.UserID,
+			u.Tag,
+			strconv.Itoa(u.Events),
+			strconv.FormatFloat(u.Duration.Hours(), 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &httpbody.HttpBody{ContentType: csvContentType, Data: buf.Bytes()}, nil
+}
+
+func (s *Service) report(ctx context.Context, req *eventpb.ReportRequest) ([]storage.Usage, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	period, ok := reportPeriods[req.GetPeriod()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown report period %s", req.GetPeriod())
+	}
+	usage, err := s.app.Report(ctx, userID(ctx), req.GetCalendarIds(), period,
+		req.GetFrom().AsTime(), req.GetTo().AsTime())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,22 @@
+package internalgrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Service) GetSchedulerStatus(ctx context.Context, _ *emptypb.Empty) (*eventpb.SchedulerStatus, error) {
+	status, err := s.app.SchedulerStatus(ctx, userID(ctx), time.Now())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.SchedulerStatus{PendingNotifications: int64(status.PendingNotifications)}
+	if !status.OldestDueAt.IsZero() {
+		resp.OldestDueAt = timestamppb.New(status.OldestDueAt)
+	}
+	return resp, nil
+}
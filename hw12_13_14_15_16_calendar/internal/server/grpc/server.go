@@ -0,0 +1,98 @@
+package internalgrpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+type Server struct {
+	logger   Logger
+	addr     string
+	server   *grpc.Server
+	shutdown chan struct{}
+	once     sync.Once
+}
+
+type Logger interface {
+	Info(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+}
+
+// Authenticator verifies a bearer token and returns the ID of its user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type RateLimiter interface {
+	Allow(route string, keys ...string) bool
+}
+
+// NewServer creates a gRPC server. Callers authenticate with an "authorization: Bearer <token>"
+// metadata. A nil authenticator trusts the "x-user-id" metadata instead and is meant for local runs only.
+func NewServer(
+	logger Logger,
+	service eventpb.EventServiceServer,
+	authenticator Authenticator,
+	limiter RateLimiter,
+	host string,
+	port int,
+) *Server {
+	shutdown := make(chan struct{})
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			loggingInterceptor(logger),
+			authInterceptor(authenticator),
+			rateLimitInterceptor(limiter),
+		),
+		grpc.ChainStreamInterceptor(
+			loggingStreamInterceptor(logger),
+			shutdownStreamInterceptor(shutdown),
+			authStreamInterceptor(authenticator),
+			rateLimitStreamInterceptor(limiter),
+		),
+	)
+	eventpb.RegisterEventServiceServer(server, service)
+
+	return &Server{
+		logger:   logger,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		server:   server,
+		shutdown: shutdown,
+	}
+}
+
+func (s *Server) Start(_ context.Context) error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("grpc server is listening on " + s.addr)
+	return s.server.Serve(lis)
+}
+
+// Stop ends the watch streams first, as they never finish on their own and would
+// keep the graceful stop waiting until ctx is done.
+func (s *Server) Stop(ctx context.Context) error {
+	s.once.Do(func() { close(s.shutdown) })
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,328 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metadata keys understood by the service. The HTTP server maps request
+// and response headers of the same name onto them.
+const (
+	AuthorizationKey  = "authorization"
+	UserIDKey         = "x-user-id"
+	IdempotencyKeyKey = "idempotency-key"
+	IfMatchKey        = "if-match"
+	ETagKey           = "etag"
+)
+
+type Application interface {
+	CreateEvent(ctx context.Context, event storage.Event, idempotencyKey string) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+	ApplyBatch(ctx context.Context, userID string, ops []storage.BatchOp) ([]app.BatchResult, error)
+	ListDayEvents(ctx context.Context, userID string, calendarIDs []string, filter app.EventFilter, date time.Time) (
+		[]storage.Event, error)
+	ListWeekEvents(ctx context.Context, userID string, calendarIDs []string, filter app.EventFilter, date time.Time) (
+		[]storage.Event, error)
+	ListMonthEvents(ctx context.Context, userID string, calendarIDs []string, filter app.EventFilter, date time.Time) (
+		[]storage.Event, error)
+	QuickAdd(ctx context.Context, userID, calendarID, text string, occurrences int, create bool) (
+		app.QuickAddDraft, []storage.Event, error)
+
+	CreateCalendar(ctx context.Context, userID, name string) (storage.UserCalendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.UserCalendar, error)
+	DeleteCalendar(ctx context.Context, userID, id string) error
+	ShareCalendar(ctx context.Context, userID string, member storage.Member) error
+	UnshareCalendar(ctx context.Context, userID, calendarID, memberID string) error
+	ListMembers(ctx context.Context, userID, calendarID string) ([]storage.Member, error)
+
+	CreateCategory(ctx context.Context, userID string, category storage.Category) (storage.Category, error)
+	ListCategories(ctx context.Context, userID string) ([]storage.Category, error)
+	UpdateCategory(ctx context.Context, userID string, category storage.Category) (storage.Category, error)
+	DeleteCategory(ctx context.Context, userID, id string) error
+
+	WatchEvents(ctx context.Context, userID string, calendarIDs []string, since int64) (<-chan storage.Change, error)
+	Sync(ctx context.Context, userID string, calendarIDs []string, since int64) (app.SyncPage, error)
+
+	SchedulerStatus(ctx context.Context, userID string, now time.Time) (app.SchedulerStatus, error)
+
+	AddAttachment(ctx context.Context, userID string, attachment storage.Attachment, content io.Reader) (
+		storage.Attachment, error)
+	OpenAttachment(ctx context.Context, userID, id string) (storage.Attachment, io.ReadCloser, error)
+	ListAttachments(ctx context.Context, userID, eventID string) ([]storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, userID, id string) error
+	ExportEvents(ctx context.Context, userID string, calendarIDs []string, filter app.EventFilter, from, to time.Time) (
+		[]app.ExportedEvent, error)
+
+	GetAvailability(ctx context.Context, userID string) (storage.Availability, error)
+	SetAvailability(ctx context.Context, userID string, availability storage.Availability) (storage.Availability, error)
+	QueryAvailability(ctx context.Context, userID, targetUserID string, from, to time.Time) ([]app.Slot, error)
+
+	Report(ctx context.Context, userID string, calendarIDs []string, period app.ReportPeriod, from, to time.Time) (
+		[]storage.Usage, error)
+
+	ExportUserData(ctx context.Context, actorID, userID string) (storage.UserData, error)
+	EraseUserData(ctx context.Context, actorID, userID string) (storage.AuditRecord, error)
+	ListAuditRecords(ctx context.Context, userID string) ([]storage.AuditRecord, error)
+}
+
+// Service implements eventpb.EventServiceServer on top of the application.
+// It is served both by the gRPC server and, through the gateway, by the HTTP server,
+// so request validation and error codes are the same on both transports.
+type Service struct {
+	eventpb.UnimplementedEventServiceServer
+
+	logger    Logger
+	app       Application
+	publicURL string
+	admins    map[string]struct{}
+}
+
+// NewService creates the service. publicURL is the base URL clients reach the HTTP API at,
+// e.g. "https://calendar.example.com"; exported events link their attachments under it.
+// Admin methods are allowed only to the users listed in admins.
+func NewService(logger Logger, app Application, publicURL string, admins []string) *Service {
+	s := &Service{
+		logger:    logger,
+		app:       app,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		admins:    make(map[string]struct{}, len(admins)),
+	}
+	for _, id := range admins {
+		s.admins[id] = struct{}{}
+	}
+	return s
+}
+
+func (s *Service) CreateEvent(ctx context.Context, req *eventpb.CreateEventRequest) (*eventpb.EventResponse, error) {
+	if req.GetEvent() == nil {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+	event := fromProto(req.GetEvent())
+	event.UserID = userID(ctx)
+
+	created, err := s.app.CreateEvent(ctx, event, metadataValue(ctx, IdempotencyKeyKey))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	setETag(ctx, created.Version)
+	return &eventpb.EventResponse{Event: toProto(created)}, nil
+}
+
+func (s *Service) UpdateEvent(ctx context.Context, req *eventpb.UpdateEventRequest) (*eventpb.EventResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if req.GetEvent() == nil {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+	event := fromProto(req.GetEvent())
+	event.UserID = userID(ctx)
+	if ifMatch := metadataValue(ctx, IfMatchKey); ifMatch != "" {
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid if-match: "+ifMatch)
+		}
+		event.Version = version
+	}
+
+	updated, err := s.app.UpdateEvent(ctx, req.GetId(), event)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	setETag(ctx, updated.Version)
+	return &eventpb.EventResponse{Event: toProto(updated)}, nil
+}
+
+func (s *Service) DeleteEvent(ctx context.Context, req *eventpb.DeleteEventRequest) (*emptypb.Empty, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.app.DeleteEvent(ctx, userID(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Service) ListDayEvents(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListDayEvents)
+}
+
+func (s *Service) ListWeekEvents(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListWeekEvents)
+}
+
+func (s *Service) ListMonthEvents(
+	ctx context.Context, req *eventpb.ListEventsRequest,
+) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListMonthEvents)
+}
+
+type listFunc func(
+	ctx context.Context, userID string, calendarIDs []string, filter app.EventFilter, date time.Time,
+) ([]storage.Event, error)
+
+func (s *Service) listEvents(
+	ctx context.Context, req *eventpb.ListEventsRequest, list listFunc,
+) (*eventpb.ListEventsResponse, error) {
+	if req.GetDate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "date is required")
+	}
+	filter := app.EventFilter{CategoryIDs: req.GetCategoryIds(), Tags: req.GetTags()}
+	events, err := list(ctx, userID(ctx), req.GetCalendarIds(), filter, req.GetDate().AsTime())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListEventsResponse{Events: make([]*eventpb.Event, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toProto(event))
+	}
+	return resp, nil
+}
+
+func (s *Service) toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, app.ErrNoUser):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, app.ErrInvalidEvent),
+		errors.Is(err, app.ErrInvalidCalendar),
+		errors.Is(err, app.ErrInvalidCategory),
+		errors.Is(err, app.ErrInvalidBatch),
+		errors.Is(err, app.ErrInvalidAttachment),
+		errors.Is(err, app.ErrAttachmentTooLarge),
+		errors.Is(err, app.ErrInvalidRange),
+		errors.Is(err, app.ErrInvalidAvailability),
+		errors.Is(err, app.ErrInvalidQuickAdd),
+		errors.Is(err, app.ErrInvalidReport):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, app.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrEventNotFound),
+		errors.Is(err, storage.ErrCalendarNotFound),
+		errors.Is(err, storage.ErrCategoryNotFound),
+		errors.Is(err, storage.ErrMemberNotFound),
+		errors.Is(err, storage.ErrAttachmentNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists), errors.Is(err, app.ErrOutOfOffice),
+		errors.Is(err, storage.ErrCategoryExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, storage.ErrCursorExpired), errors.Is(err, storage.ErrSyncTokenExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, app.ErrQuotaExceeded), errors.Is(err, app.ErrTooManyAttachments):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	s.logger.ErrorContext(ctx, "internal error: "+err.Error())
+	return status.Error(codes.Internal, "internal error")
+}
+
+func userID(ctx context.Context) string {
+	return auth.UserID(ctx)
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// setETag reports the event version as a strong entity tag, e.g. "3".
+func setETag(ctx context.Context, version int64) {
+	// The only possible error is a missing transport stream, e.g. in unit tests.
+	_ = grpc.SetHeader(ctx, metadata.Pairs(ETagKey, strconv.Quote(strconv.FormatInt(version, 10))))
+}
+
+func parseETag(etag string) (int64, error) {
+	return strconv.ParseInt(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), 10, 64)
+}
+
+func fromProto(event *eventpb.Event) storage.Event {
+	result := storage.Event{
+		ID:          event.GetId(),
+		Title:       event.GetTitle(),
+		Description: event.GetDescription(),
+		UserID:      event.GetUserId(),
+		CalendarID:  event.GetCalendarId(),
+		Kind:        kindFromProto(event.GetKind()),
+		Version:     event.GetVersion(),
+		CategoryID:  event.GetCategoryId(),
+		Tags:        event.GetTags(),
+		Location: storage.Location{
+			Address:       event.GetLocation().GetAddress(),
+			ConferenceURL: event.GetLocation().GetConferenceUrl(),
+		},
+	}
+	if event.GetStartAt() != nil {
+		result.StartAt = event.GetStartAt().AsTime()
+	}
+	if event.GetEndAt() != nil {
+		result.EndAt = event.GetEndAt().AsTime()
+	}
+	if event.GetNotifyBefore() != nil {
+		result.NotifyBefore = event.GetNotifyBefore().AsDuration()
+	}
+	return result
+}
+
+var kinds = map[storage.EventKind]eventpb.EventKind{
+	storage.KindRegular:     eventpb.EventKind_EVENT_KIND_UNSPECIFIED,
+	storage.KindOutOfOffice: eventpb.EventKind_EVENT_KIND_OUT_OF_OFFICE,
+}
+
+// kindFromProto keeps unknown kinds unknown, so that the application rejects them.
+func kindFromProto(kind eventpb.EventKind) storage.EventKind {
+	for k, pb := range kinds {
+		if pb == kind {
+			return k
+		}
+	}
+	return storage.EventKind(kind.String())
+}
+
+func toProto(event storage.Event) *eventpb.Event {
+	result := &eventpb.Event{
+		Id:           event.ID,
+		Title:        event.Title,
+		StartAt:      timestamppb.New(event.StartAt),
+		EndAt:        timestamppb.New(event.EndAt),
+		Description:  event.Description,
+		UserId:       event.UserID,
+		CalendarId:   event.CalendarID,
+		Kind:         kinds[event.Kind],
+		NotifyBefore: durationpb.New(event.NotifyBefore),
+		Version:      event.Version,
+		CategoryId:   event.CategoryID,
+		Tags:         event.Tags,
+	}
+	if event.Location != (storage.Location{}) {
+		result.Location = &eventpb.Location{
+			Address:       event.Location.Address,
+			ConferenceUrl: event.Location.ConferenceURL,
+		}
+	}
+	return result
+}
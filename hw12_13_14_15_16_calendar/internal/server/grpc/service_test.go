@@ -0,0 +1,548 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTestClient(t *testing.T, authenticator Authenticator) eventpb.EventServiceClient {
+	t.Helper()
+
+	client, _ := newTestServer(t, authenticator)
+	return client
+}
+
+func newTestServer(t *testing.T, authenticator Authenticator) (eventpb.EventServiceClient, *Server) {
+	t.Helper()
+
+	logg := logger.NewWithWriter("error", io.Discard)
+	calendar := app.New(logg, memorystorage.New(), changefeed.New(100), blob.NewFS(t.TempDir()),
+		time.Hour, 0, app.AttachmentLimits{MaxSize: 1024})
+	limiter := ratelimit.New(ratelimit.Rule{}, []ratelimit.Route{
+		{GRPC: "/event.EventService/ListDayEvents", Rule: ratelimit.Rule{Rate: 0.01, Burst: 1}},
+	})
+	server := NewServer(logg, NewService(logg, calendar, "", []string{"admin"}), authenticator, limiter, "localhost", 0)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.server.Serve(lis) }()
+	t.Cleanup(server.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return eventpb.NewEventServiceClient(conn), server
+}
+
+func TestService(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	event := &eventpb.Event{
+		Title:   "standup",
+		StartAt: timestamppb.New(start),
+		EndAt:   timestamppb.New(start.Add(15 * time.Minute)),
+	}
+	resp, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: event})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetEvent().GetId())
+	require.Equal(t, "alice", resp.GetEvent().GetUserId())
+
+	t.Run("error codes", func(t *testing.T) {
+		_, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: event})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		_, err = client.CreateEvent(context.Background(), &eventpb.CreateEventRequest{Event: event})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{}})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: "unknown"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		list, err := client.ListMonthEvents(ctx, &eventpb.ListEventsRequest{
+			Date: timestamppb.New(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+		})
+		require.NoError(t, err)
+		require.Len(t, list.GetEvents(), 1)
+		require.Equal(t, resp.GetEvent().GetId(), list.GetEvents()[0].GetId())
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		sync, err := client.Sync(ctx, &eventpb.SyncRequest{})
+		require.NoError(t, err)
+		require.Len(t, sync.GetChanges(), 1)
+		require.Equal(t, eventpb.ChangeKind_CHANGE_KIND_CREATED, sync.GetChanges()[0].GetKind())
+		require.Equal(t, resp.GetEvent().GetId(), sync.GetChanges()[0].GetEvent().GetId())
+
+		sync, err = client.Sync(ctx, &eventpb.SyncRequest{Token: sync.GetToken()})
+		require.NoError(t, err)
+		require.Empty(t, sync.GetChanges())
+
+		_, err = client.Sync(ctx, &eventpb.SyncRequest{Token: "100"})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		_, err = client.Sync(ctx, &eventpb.SyncRequest{Token: "abc"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := client.WatchEvents(ctx, &eventpb.WatchEventsRequest{})
+		require.NoError(t, err)
+		_, err = stream.Header()
+		require.NoError(t, err)
+
+		_, err = client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: resp.GetEvent().GetId()})
+		require.NoError(t, err)
+		change, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, eventpb.ChangeKind_CHANGE_KIND_DELETED, change.GetKind())
+		require.Equal(t, resp.GetEvent().GetId(), change.GetEvent().GetId())
+
+		// Resuming from the previous cursor replays the change.
+		resumed, err := client.WatchEvents(ctx, &eventpb.WatchEventsRequest{Cursor: change.GetCursor() - 1})
+		require.NoError(t, err)
+		replayed, err := resumed.Recv()
+		require.NoError(t, err)
+		require.Equal(t, change.GetCursor(), replayed.GetCursor())
+
+		expired, err := client.WatchEvents(ctx, &eventpb.WatchEventsRequest{Cursor: 100})
+		require.NoError(t, err)
+		_, err = expired.Recv()
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		req := &eventpb.ListEventsRequest{Date: timestamppb.New(start)}
+		_, err := client.ListDayEvents(ctx, req)
+		require.NoError(t, err)
+
+		_, err = client.ListDayEvents(ctx, req)
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		day := start.AddDate(0, 0, 1)
+		create := func(startAt time.Time) *eventpb.BatchOperation {
+			return &eventpb.BatchOperation{Operation: &eventpb.BatchOperation_Create{Create: &eventpb.Event{
+				Title:   "call",
+				StartAt: timestamppb.New(startAt),
+				EndAt:   timestamppb.New(startAt.Add(time.Hour)),
+			}}}
+		}
+		batch, err := client.BatchEvents(ctx, &eventpb.BatchEventsRequest{Operations: []*eventpb.BatchOperation{
+			create(day),
+			create(day.Add(30 * time.Minute)),
+			{Operation: &eventpb.BatchOperation_Delete{Delete: &eventpb.DeleteEventRequest{Id: "unknown"}}},
+		}})
+		require.NoError(t, err)
+		require.False(t, batch.GetApplied())
+		require.Len(t, batch.GetResults(), 3)
+		require.Equal(t, int32(codes.OK), batch.GetResults()[0].GetCode())
+		require.Nil(t, batch.GetResults()[0].GetEvent())
+		require.Equal(t, int32(codes.AlreadyExists), batch.GetResults()[1].GetCode())
+		require.Equal(t, int32(codes.NotFound), batch.GetResults()[2].GetCode())
+
+		batch, err = client.BatchEvents(ctx, &eventpb.BatchEventsRequest{Operations: []*eventpb.BatchOperation{
+			create(day),
+			create(day.Add(time.Hour)),
+		}})
+		require.NoError(t, err)
+		require.True(t, batch.GetApplied())
+		require.Equal(t, "alice", batch.GetResults()[1].GetEvent().GetUserId())
+
+		_, err = client.BatchEvents(ctx, &eventpb.BatchEventsRequest{})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.BatchEvents(ctx, &eventpb.BatchEventsRequest{Operations: []*eventpb.BatchOperation{{}}})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestServiceAuthentication(t *testing.T) {
+	client := newTestClient(t, auth.NewStaticTokens(map[string]string{"alice-token": "alice"}))
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	req := &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:   "standup",
+		StartAt: timestamppb.New(start),
+		EndAt:   timestamppb.New(start.Add(15 * time.Minute)),
+	}}
+
+	// The user ID metadata is ignored once tokens are verified.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	_, err := client.CreateEvent(ctx, req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), AuthorizationKey, "Bearer bob-token")
+	_, err = client.CreateEvent(ctx, req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(),
+		AuthorizationKey, "Bearer alice-token", UserIDKey, "bob")
+	resp, err := client.CreateEvent(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "alice", resp.GetEvent().GetUserId())
+}
+
+func TestServiceAttachments(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	resp, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:    "standup",
+		StartAt:  timestamppb.New(start),
+		EndAt:    timestamppb.New(start.Add(15 * time.Minute)),
+		Location: &eventpb.Location{Address: "Room 1", ConferenceUrl: "https://meet.example.com/standup"},
+	}})
+	require.NoError(t, err)
+	event := resp.GetEvent()
+	require.Equal(t, "Room 1", event.GetLocation().GetAddress())
+
+	upload := func(t *testing.T, reqs ...*eventpb.UploadAttachmentRequest) (*eventpb.Attachment, error) {
+		t.Helper()
+		stream, err := client.UploadAttachment(ctx)
+		require.NoError(t, err)
+		for _, req := range reqs {
+			require.NoError(t, stream.Send(req))
+		}
+		return stream.CloseAndRecv()
+	}
+	metadataReq := func(name string) *eventpb.UploadAttachmentRequest {
+		return &eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Metadata{
+			Metadata: &eventpb.AttachmentMetadata{EventId: event.GetId(), Name: name, ContentType: "text/plain"},
+		}}
+	}
+	chunk := func(data string) *eventpb.UploadAttachmentRequest {
+		return &eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Chunk{Chunk: []byte(data)}}
+	}
+
+	attachment, err := upload(t, metadataReq("agenda.txt"), chunk("hello, "), chunk("world"))
+	require.NoError(t, err)
+	require.Equal(t, int64(12), attachment.GetSize())
+
+	t.Run("download", func(t *testing.T) {
+		stream, err := client.DownloadAttachment(ctx, &eventpb.DownloadAttachmentRequest{Id: attachment.GetId()})
+		require.NoError(t, err)
+		first, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "agenda.txt", first.GetAttachment().GetName())
+		var content []byte
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			content = append(content, chunk.GetData()...)
+		}
+		require.Equal(t, "hello, world", string(content))
+
+		list, err := client.ListAttachments(ctx, &eventpb.ListAttachmentsRequest{EventId: event.GetId()})
+		require.NoError(t, err)
+		require.Len(t, list.GetAttachments(), 1)
+	})
+
+	t.Run("error codes", func(t *testing.T) {
+		_, err := upload(t, chunk("no metadata"))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = upload(t, metadataReq("a"), metadataReq("b"))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = upload(t, metadataReq("big.txt"), chunk(strings.Repeat("x", 1025)))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		stream, err := client.DownloadAttachment(ctx, &eventpb.DownloadAttachmentRequest{Id: "unknown"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("export", func(t *testing.T) {
+		body, err := client.ExportEvents(ctx, &eventpb.ExportEventsRequest{
+			From: timestamppb.New(start.Truncate(24 * time.Hour)),
+			To:   timestamppb.New(start.Truncate(24*time.Hour).AddDate(0, 0, 1)),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "text/calendar; charset=utf-8", body.GetContentType())
+		require.Contains(t, string(body.GetData()), "UID:"+event.GetId()+"\r\n")
+		require.Contains(t, string(body.GetData()), "LOCATION:Room 1\r\n")
+		// The service does not know its public URL, attachments are not linked.
+		require.NotContains(t, string(body.GetData()), "ATTACH")
+
+		_, err = client.ExportEvents(ctx, &eventpb.ExportEventsRequest{From: timestamppb.New(start)})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := client.DeleteAttachment(ctx, &eventpb.DeleteAttachmentRequest{Id: attachment.GetId()})
+		require.NoError(t, err)
+		_, err = client.DeleteAttachment(ctx, &eventpb.DeleteAttachmentRequest{Id: attachment.GetId()})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestServiceAvailability(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	settings, err := client.SetAvailabilitySettings(ctx, &eventpb.AvailabilitySettings{
+		TimeZone:     "UTC",
+		WorkingHours: []*eventpb.WorkingHours{{Weekday: 1, Start: "09:00", End: "17:30"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "17:30", settings.GetWorkingHours()[0].GetEnd())
+	_, err = client.SetAvailabilitySettings(ctx, &eventpb.AvailabilitySettings{
+		WorkingHours: []*eventpb.WorkingHours{{Weekday: 1, Start: "9am", End: "17:30"}},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.SetAvailabilitySettings(ctx, &eventpb.AvailabilitySettings{TimeZone: "Mars/Olympus"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:   "vacation",
+		StartAt: timestamppb.New(monday.Add(12 * time.Hour)),
+		EndAt:   timestamppb.New(monday.AddDate(0, 0, 7)),
+		Kind:    eventpb.EventKind_EVENT_KIND_OUT_OF_OFFICE,
+	}})
+	require.NoError(t, err)
+	require.Equal(t, eventpb.EventKind_EVENT_KIND_OUT_OF_OFFICE, resp.GetEvent().GetKind())
+	_, err = client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:   "standup",
+		StartAt: timestamppb.New(monday.Add(13 * time.Hour)),
+		EndAt:   timestamppb.New(monday.Add(14 * time.Hour)),
+	}})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	bob := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "bob")
+	slots, err := client.QueryAvailability(bob, &eventpb.QueryAvailabilityRequest{
+		UserId: "alice",
+		From:   timestamppb.New(monday),
+		To:     timestamppb.New(monday.AddDate(0, 0, 1)),
+	})
+	require.NoError(t, err)
+	statuses := make([]eventpb.AvailabilityStatus, 0, len(slots.GetSlots()))
+	for _, slot := range slots.GetSlots() {
+		statuses = append(statuses, slot.GetStatus())
+	}
+	require.Equal(t, []eventpb.AvailabilityStatus{
+		eventpb.AvailabilityStatus_AVAILABILITY_STATUS_OFF_HOURS,
+		eventpb.AvailabilityStatus_AVAILABILITY_STATUS_FREE,
+		eventpb.AvailabilityStatus_AVAILABILITY_STATUS_OUT_OF_OFFICE,
+	}, statuses)
+	require.Equal(t, monday.Add(9*time.Hour), slots.GetSlots()[1].GetStartAt().AsTime())
+
+	_, err = client.QueryAvailability(bob, &eventpb.QueryAvailabilityRequest{From: timestamppb.New(monday)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServiceCategories(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	category, err := client.CreateCategory(ctx, &eventpb.Category{Name: "Travel, abroad", Color: "#00aa00"})
+	require.NoError(t, err)
+	_, err = client.CreateCategory(ctx, &eventpb.Category{Name: "Oncall", Color: "green"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.UpdateCategory(ctx, &eventpb.Category{Name: "Trips"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:      "flight",
+		StartAt:    timestamppb.New(start),
+		EndAt:      timestamppb.New(start.Add(3 * time.Hour)),
+		CategoryId: category.GetId(),
+		Tags:       []string{"travel", "Travel", "1:1"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, category.GetId(), resp.GetEvent().GetCategoryId())
+	require.Equal(t, []string{"travel", "1:1"}, resp.GetEvent().GetTags())
+
+	list, err := client.ListDayEvents(ctx, &eventpb.ListEventsRequest{
+		Date: timestamppb.New(start.Truncate(24 * time.Hour)), Tags: []string{"oncall"},
+	})
+	require.NoError(t, err)
+	require.Empty(t, list.GetEvents())
+
+	body, err := client.ExportEvents(ctx, &eventpb.ExportEventsRequest{
+		From:        timestamppb.New(start.Truncate(24 * time.Hour)),
+		To:          timestamppb.New(start.Truncate(24*time.Hour).AddDate(0, 0, 1)),
+		CategoryIds: []string{category.GetId()},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(body.GetData()), "CATEGORIES:Travel\\, abroad,travel,1:1\r\n")
+
+	_, err = client.DeleteCategory(ctx, &eventpb.DeleteCategoryRequest{Id: category.GetId()})
+	require.NoError(t, err)
+	_, err = client.DeleteCategory(ctx, &eventpb.DeleteCategoryRequest{Id: category.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServiceReports(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	for i, tags := range [][]string{{"oncall"}, {"review", "Oncall"}} {
+		_, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+			Title:   "meeting",
+			StartAt: timestamppb.New(start.AddDate(0, 0, 7*i)),
+			EndAt:   timestamppb.New(start.AddDate(0, 0, 7*i).Add(90 * time.Minute)),
+			Tags:    tags,
+		}})
+		require.NoError(t, err)
+	}
+	req := &eventpb.ReportRequest{
+		From:   timestamppb.New(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+		To:     timestamppb.New(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)),
+		Period: eventpb.ReportPeriod_REPORT_PERIOD_MONTH,
+	}
+
+	report, err := client.GetReport(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, report.GetRows(), 3)
+	require.Equal(t, "oncall", report.GetRows()[1].GetTag())
+	require.Equal(t, int32(2), report.GetRows()[1].GetEvents())
+	require.Equal(t, 3*time.Hour, report.GetRows()[1].GetDuration().AsDuration())
+
+	body, err := client.ExportReport(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "text/csv; charset=utf-8", body.GetContentType())
+	require.Equal(t, "period_start,period_end,user_id,tag,events,hours\n"+
+		"2025-03-01T00:00:00Z,2025-04-01T00:00:00Z,alice,,2,3.00\n"+
+		"2025-03-01T00:00:00Z,2025-04-01T00:00:00Z,alice,oncall,2,3.00\n"+
+		"2025-03-01T00:00:00Z,2025-04-01T00:00:00Z,alice,review,1,1.50\n", string(body.GetData()))
+
+	req.Period = 42
+	_, err = client.GetReport(ctx, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ExportReport(ctx, &eventpb.ReportRequest{From: req.GetFrom()})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServiceAdmin(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+	admin := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "admin")
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	_, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title: "meeting", StartAt: timestamppb.New(start), EndAt: timestamppb.New(start.Add(time.Hour)),
+	}})
+	require.NoError(t, err)
+
+	req := &eventpb.UserDataRequest{UserId: "alice"}
+	_, err = client.ExportUserData(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.EraseUserData(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.ListAuditRecords(ctx, &eventpb.ListAuditRecordsRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.ExportUserData(context.Background(), req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.ExportUserData(admin, &eventpb.UserDataRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	archive, err := client.ExportUserData(admin, req)
+	require.NoError(t, err)
+	require.Equal(t, "alice", archive.GetUserId())
+	require.Len(t, archive.GetEvents(), 1)
+	require.Equal(t, "meeting", archive.GetEvents()[0].GetTitle())
+	require.Len(t, archive.GetHistory(), 1)
+
+	record, err := client.EraseUserData(admin, req)
+	require.NoError(t, err)
+	require.Equal(t, "admin", record.GetActorId())
+	require.Equal(t, int32(1), record.GetCounts()["events"])
+	archive, err = client.ExportUserData(admin, req)
+	require.NoError(t, err)
+	require.Empty(t, archive.GetEvents())
+
+	records, err := client.ListAuditRecords(admin, &eventpb.ListAuditRecordsRequest{UserId: "alice"})
+	require.NoError(t, err)
+	require.Len(t, records.GetRecords(), 3)
+	require.Equal(t, "erase_user_data", records.GetRecords()[1].GetAction())
+}
+
+func TestServiceQuickAdd(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+
+	draft, err := client.QuickAdd(ctx, &eventpb.QuickAddRequest{Text: "lunch with Ana tomorrow 1pm for 45m"})
+	require.NoError(t, err)
+	require.False(t, draft.GetCreated())
+	require.Len(t, draft.GetEvents(), 1)
+	event := draft.GetEvents()[0]
+	require.Empty(t, event.GetId())
+	require.Equal(t, "lunch with Ana", event.GetTitle())
+	require.Equal(t, 45*time.Minute, event.GetEndAt().AsTime().Sub(event.GetStartAt().AsTime()))
+
+	created, err := client.QuickAdd(ctx, &eventpb.QuickAddRequest{
+		Text: "standup every monday 9am", Occurrences: 4, Create: true,
+	})
+	require.NoError(t, err)
+	require.True(t, created.GetCreated())
+	require.True(t, created.GetWeekly())
+	require.Equal(t, int32(4), created.GetOccurrences())
+	require.Len(t, created.GetEvents(), 4)
+	require.NotEmpty(t, created.GetEvents()[0].GetId())
+
+	_, err = client.QuickAdd(ctx, &eventpb.QuickAddRequest{Text: "lunch"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.QuickAdd(ctx, &eventpb.QuickAddRequest{Text: "standup every monday 9am", Occurrences: 1000})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.QuickAdd(ctx, &eventpb.QuickAddRequest{Text: "standup every monday 9am", Create: true})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestStopEndsWatchStreams(t *testing.T) {
+	client, server := newTestServer(t, nil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), UserIDKey, "alice")
+
+	stream, err := client.WatchEvents(ctx, &eventpb.WatchEventsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, server.Stop(stopCtx))
+	require.Less(t, time.Since(start), time.Second)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
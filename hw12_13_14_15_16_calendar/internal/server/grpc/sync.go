@@ -0,0 +1,43 @@
+package internalgrpc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sync tokens are change sequence numbers, clients treat them as opaque strings.
+func (s *Service) Sync(ctx context.Context, req *eventpb.SyncRequest) (*eventpb.SyncResponse, error) {
+	var since int64
+	if token := req.GetToken(); token != "" {
+		var err error
+		if since, err = strconv.ParseInt(token, 10, 64); err != nil || since < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid sync token: "+token)
+		}
+	}
+
+	page, err := s.app.Sync(ctx, userID(ctx), req.GetCalendarIds(), since)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return syncToProto(page), nil
+}
+
+func syncToProto(page app.SyncPage) *eventpb.SyncResponse {
+	resp := &eventpb.SyncResponse{
+		Changes: make([]*eventpb.SyncChange, 0, len(page.Changes)),
+		Token:   strconv.FormatInt(page.Token, 10),
+		HasMore: page.More,
+	}
+	for _, change := range page.Changes {
+		resp.Changes = append(resp.Changes, &eventpb.SyncChange{
+			Kind:  changeKinds[change.Kind],
+			Event: toProto(change.Event),
+		})
+	}
+	return resp
+}
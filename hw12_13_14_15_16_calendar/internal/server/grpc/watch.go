@@ -0,0 +1,55 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var changeKinds = map[storage.ChangeKind]eventpb.ChangeKind{
+	storage.ChangeCreated: eventpb.ChangeKind_CHANGE_KIND_CREATED,
+	storage.ChangeUpdated: eventpb.ChangeKind_CHANGE_KIND_UPDATED,
+	storage.ChangeDeleted: eventpb.ChangeKind_CHANGE_KIND_DELETED,
+}
+
+func (s *Service) WatchEvents(req *eventpb.WatchEventsRequest, stream grpc.ServerStreamingServer[eventpb.EventChange]) error {
+	ctx := stream.Context()
+	if req.GetCursor() < 0 {
+		return status.Error(codes.InvalidArgument, "cursor must not be negative")
+	}
+	changes, err := s.app.WatchEvents(ctx, userID(ctx), req.GetCalendarIds(), req.GetCursor())
+	if err != nil {
+		return s.toStatus(ctx, err)
+	}
+	// Headers tell the client that watching has started before the first change arrives.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for change := range changes {
+		if err := stream.Send(changeToProto(change)); err != nil {
+			return err
+		}
+	}
+	if errors.Is(context.Cause(ctx), errShuttingDown) {
+		return status.Error(codes.Unavailable, "server is shutting down, resume from the last cursor")
+	}
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Unavailable, "watcher fell behind, resume from the last cursor")
+}
+
+func changeToProto(change storage.Change) *eventpb.EventChange {
+	return &eventpb.EventChange{
+		Cursor: change.Seq,
+		Kind:   changeKinds[change.Kind],
+		Event:  toProto(change.Event),
+	}
+}
@@ -0,0 +1,124 @@
+package internalhttp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// uploadChunkSize is the size of the chunks a request body is split into.
+const uploadChunkSize = 64 * 1024
+
+// attachmentHandler serves files of attachments as raw request and response bodies,
+// which the gateway cannot transcode. Requests are adapted to the UploadAttachment and
+// DownloadAttachment streams, so the service validates them and maps errors as for gRPC.
+type attachmentHandler struct {
+	service eventpb.EventServiceServer
+	mux     *runtime.ServeMux
+}
+
+// upload attaches the request body to the event, the name is taken from the name
+// query parameter and the content type from the Content-Type header.
+func (h *attachmentHandler) upload(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	stream := &uploadStream{
+		baseStream: baseStream{ctx: r.Context()},
+		metadata: &eventpb.AttachmentMetadata{
+			EventId:     params["event_id"],
+			Name:        r.URL.Query().Get("name"),
+			ContentType: r.Header.Get("Content-Type"),
+		},
+		body: r.Body,
+	}
+	if err := h.service.UploadAttachment(stream); err != nil {
+		writeStatus(w, status.Convert(err))
+		return
+	}
+
+	_, marshaler := runtime.MarshalerForRequest(h.mux, r)
+	data, err := marshaler.Marshal(stream.attachment)
+	if err != nil {
+		writeStatus(w, status.Convert(err))
+		return
+	}
+	w.Header().Set("Content-Type", marshaler.ContentType(stream.attachment))
+	_, _ = w.Write(data)
+}
+
+// download serves the file as an attachment, so that browsers never render
+// uploaded content within the API origin.
+func (h *attachmentHandler) download(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	stream := &downloadStream{baseStream: baseStream{ctx: r.Context()}, w: w}
+	err := h.service.DownloadAttachment(&eventpb.DownloadAttachmentRequest{Id: params["id"]}, stream)
+	// A response that has been started cannot report the error, the client
+	// sees a body shorter than its Content-Length.
+	if err != nil && !stream.responded() {
+		writeStatus(w, status.Convert(err))
+	}
+}
+
+// uploadStream is an UploadAttachment stream over an HTTP request.
+type uploadStream struct {
+	baseStream
+
+	metadata   *eventpb.AttachmentMetadata
+	body       io.Reader
+	attachment *eventpb.Attachment
+}
+
+func (s *uploadStream) Recv() (*eventpb.UploadAttachmentRequest, error) {
+	if s.metadata != nil {
+		req := &eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Metadata{Metadata: s.metadata}}
+		s.metadata = nil
+		return req, nil
+	}
+	chunk := make([]byte, uploadChunkSize)
+	n, err := io.ReadFull(s.body, chunk)
+	if n > 0 {
+		return &eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Chunk{Chunk: chunk[:n]}}, nil
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return nil, err
+}
+
+func (s *uploadStream) SendAndClose(attachment *eventpb.Attachment) error {
+	s.attachment = attachment
+	return nil
+}
+
+// downloadStream is a DownloadAttachment stream over an HTTP response.
+type downloadStream struct {
+	baseStream
+
+	w http.ResponseWriter
+}
+
+func (s *downloadStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *downloadStream) Send(chunk *eventpb.AttachmentChunk) error {
+	if attachment := chunk.GetAttachment(); attachment != nil {
+		header := s.w.Header()
+		header.Set("Content-Type", attachment.GetContentType())
+		header.Set("Content-Length", strconv.FormatInt(attachment.GetSize(), 10))
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": attachment.GetName()}))
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", "sandbox")
+		s.mu.Lock()
+		s.started = true
+		s.mu.Unlock()
+		s.w.WriteHeader(http.StatusOK)
+	}
+	_, err := s.w.Write(chunk.GetData())
+	return err
+}
@@ -1,11 +1,134 @@
 package internalhttp
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler { //nolint:unused
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush and Hijack let event streams see through the recorder.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func loggingMiddleware(logger Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		logger.InfoContext(r.Context(), fmt.Sprintf("%s [%s] %s %s %s %d %d %q",
+			clientIP(r),
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method,
+			r.URL.RequestURI(),
+			r.Proto,
+			rec.status,
+			time.Since(start).Milliseconds(),
+			r.UserAgent(),
+		))
 	})
 }
+
+// tracingMiddleware names the request span after the matched route.
+func tracingMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		trace.SpanFromContext(r.Context()).SetName(routeName(r))
+		next(w, r, pathParams)
+	}
+}
+
+// authMiddleware stores the authenticated user ID in the request context,
+// where the service reads it from.
+func authMiddleware(authenticator Authenticator) runtime.Middleware {
+	return func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			if authenticator == nil {
+				next(w, r.WithContext(auth.WithUserID(r.Context(), r.Header.Get("X-User-Id"))), pathParams)
+				return
+			}
+			userID, err := authenticator.Authenticate(r.Context(), auth.BearerToken(r.Header.Get("Authorization")))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="calendar"`)
+				writeStatus(w, status.New(codes.Unauthenticated, err.Error()))
+				return
+			}
+			next(w, r.WithContext(auth.WithUserID(r.Context(), userID)), pathParams)
+		}
+	}
+}
+
+func rateLimitMiddleware(limiter RateLimiter) runtime.Middleware {
+	return func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			if !limiter.Allow(routeName(r), auth.UserID(r.Context()), clientIP(r)) {
+				w.Header().Set("Retry-After", "1")
+				writeStatus(w, status.New(codes.ResourceExhausted, "rate limit exceeded"))
+				return
+			}
+			next(w, r, pathParams)
+		}
+	}
+}
+
+// routeName returns the matched route pattern, e.g. "PUT /v1/events/{id}".
+// It is known only inside the gateway mux.
+func routeName(r *http.Request) string {
+	route := r.Method
+	if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+		route += " " + strings.ReplaceAll(pattern.String(), "=*}", "}")
+	}
+	return route
+}
+
+// writeStatus writes an error in the same format as the gateway does.
+func writeStatus(w http.ResponseWriter, st *status.Status) {
+	body, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		body = []byte(`{"code":13,"message":"failed to marshal error"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(runtime.HTTPStatusFromCode(st.Code()))
+	_, _ = w.Write(body)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
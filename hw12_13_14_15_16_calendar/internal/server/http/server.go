@@ -2,30 +2,109 @@ package internalhttp
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-type Server struct { // TODO
+// Request headers forwarded to the service as metadata.
+var incomingHeaders = map[string]string{
+	"Idempotency-Key": internalgrpc.IdempotencyKeyKey,
+	"If-Match":        internalgrpc.IfMatchKey,
+}
+
+type Server struct {
+	logger Logger
+	server *http.Server
 }
 
-type Logger interface { // TODO
+type Logger interface {
+	Info(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
 }
 
-type Application interface { // TODO
+// Authenticator verifies a bearer token and returns the ID of its user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
 }
 
-func NewServer(logger Logger, app Application) *Server {
-	return &Server{}
+type RateLimiter interface {
+	Allow(route string, keys ...string) bool
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	// TODO
-	<-ctx.Done()
+// NewServer creates an HTTP server whose JSON API is transcoded from the gRPC
+// service definition according to the google.api.http annotations in api/EventService.proto.
+// Clients authenticate with an "Authorization: Bearer <token>" header. A nil authenticator
+// trusts the X-User-Id header instead and is meant for local runs only.
+func NewServer(
+	logger Logger,
+	service eventpb.EventServiceServer,
+	authenticator Authenticator,
+	limiter RateLimiter,
+	host string,
+	port int,
+) *Server {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMiddlewares(tracingMiddleware, authMiddleware(authenticator), rateLimitMiddleware(limiter)),
+	)
+	// The handler server never returns an error on registration.
+	_ = eventpb.RegisterEventServiceHandlerServer(context.Background(), mux, service)
+
+	shutdown := make(chan struct{})
+	streams := &streamHandler{service: service, mux: mux, shutdown: shutdown}
+	// The patterns are constant and valid.
+	_ = mux.HandlePath(http.MethodGet, "/v1/events/stream", streams.serveSSE)
+	_ = mux.HandlePath(http.MethodGet, "/v1/events/ws", streams.serveWebSocket)
+	attachments := &attachmentHandler{service: service, mux: mux}
+	_ = mux.HandlePath(http.MethodPost, "/v1/events/{event_id}/attachments", attachments.upload)
+	_ = mux.HandlePath(http.MethodGet, "/v1/attachments/{id}/content", attachments.download)
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(host, strconv.Itoa(port)),
+		Handler:           otelhttp.NewHandler(loggingMiddleware(logger, mux), "http.server"),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	server.RegisterOnShutdown(func() { close(shutdown) })
+	return &Server{
+		logger: logger,
+		server: server,
+	}
+}
+
+func (s *Server) Start(_ context.Context) error {
+	s.logger.Info("http server is listening on " + s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
 
 func (s *Server) Stop(ctx context.Context) error {
-	// TODO
-	return nil
+	return s.server.Shutdown(ctx)
 }
 
-// TODO
+func incomingHeaderMatcher(key string) (string, bool) {
+	if mdKey, ok := incomingHeaders[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+		return mdKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == internalgrpc.ETagKey {
+		return "ETag", true
+	}
+	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+}
@@ -0,0 +1,558 @@
+package internalhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/changefeed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(
+	t *testing.T, authenticator Authenticator, limiter RateLimiter, maxEventsPerUser int,
+) *httptest.Server {
+	t.Helper()
+
+	logg := logger.NewWithWriter("error", io.Discard)
+	calendar := app.New(logg, memorystorage.New(), changefeed.New(100), blob.NewFS(t.TempDir()),
+		time.Hour, maxEventsPerUser, app.AttachmentLimits{MaxSize: 1024})
+	service := internalgrpc.NewService(logg, calendar, "https://calendar.example.com", []string{"admin"})
+	server := NewServer(logg, service, authenticator, limiter, "localhost", 0)
+
+	ts := httptest.NewServer(server.server.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doRequest(t *testing.T, method, url, userID, body string) (int, map[string]any) {
+	t.Helper()
+
+	status, _, result := doRequestWithHeaders(t, method, url, body, http.Header{"X-User-Id": {userID}})
+	return status, result
+}
+
+func doRequestWithHeaders(t *testing.T, method, url, body string, headers http.Header) (int, http.Header, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body)) //nolint:noctx
+	require.NoError(t, err)
+	for key, values := range headers {
+		if values[0] != "" {
+			req.Header[key] = values
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	result := make(map[string]any)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, resp.Header, result
+}
+
+func TestServer(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	event := `{"title":"standup","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T10:15:00Z"}`
+
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+	require.Equal(t, http.StatusOK, status)
+	created := body["event"].(map[string]any)
+	require.Equal(t, "alice", created["userId"])
+	id := created["id"].(string)
+
+	t.Run("errors are transcoded from grpc codes", func(t *testing.T) {
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+		require.Equal(t, http.StatusConflict, status)
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "", event)
+		require.Equal(t, http.StatusUnauthorized, status)
+
+		status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", `{"title":""}`)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, body["message"], "title is required")
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/v1/events/"+id, "bob", "")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/events/week?date=2025-03-10T00:00:00Z", "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, body["events"], 1)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/v1/events/day", "alice", "")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		event := `{"title":"lunch","startAt":"2025-03-11T12:00:00Z","endAt":"2025-03-11T13:00:00Z"}`
+		headers := http.Header{"X-User-Id": {"alice"}, "Idempotency-Key": {"lunch-1"}}
+
+		status, _, first := doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event, headers)
+		require.Equal(t, http.StatusOK, status)
+		status, _, retry := doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event, headers)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, first["event"].(map[string]any)["id"], retry["event"].(map[string]any)["id"])
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		event := `{"title":"review","startAt":"2025-03-12T12:00:00Z","endAt":"2025-03-12T13:00:00Z"}`
+		status, headers, body := doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event,
+			http.Header{"X-User-Id": {"alice"}})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, `"1"`, headers.Get("ETag"))
+		url := ts.URL + "/v1/events/" + body["event"].(map[string]any)["id"].(string)
+
+		status, headers, _ = doRequestWithHeaders(t, http.MethodPut, url, event,
+			http.Header{"X-User-Id": {"alice"}, "If-Match": {`"1"`}})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, `"2"`, headers.Get("ETag"))
+
+		status, _, _ = doRequestWithHeaders(t, http.MethodPut, url, event,
+			http.Header{"X-User-Id": {"alice"}, "If-Match": {`"1"`}})
+		require.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/events/sync", "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.NotEmpty(t, body["changes"])
+		require.Equal(t, "CHANGE_KIND_CREATED", body["changes"].([]any)[0].(map[string]any)["kind"])
+
+		status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/events/sync?token="+body["token"].(string), "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Empty(t, body["changes"])
+
+		status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/events/sync?token=1000", "alice", "")
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, body["message"], "full resync")
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		updated := `{"title":"retro","startAt":"2025-03-10T11:00:00Z","endAt":"2025-03-10T12:00:00Z"}`
+		status, body := doRequest(t, http.MethodPut, ts.URL+"/v1/events/"+id, "alice", updated)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "retro", body["event"].(map[string]any)["title"])
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/v1/events/"+id, "alice", "")
+		require.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		batch := `{"operations":[
+			{"create":{"title":"a","startAt":"2025-03-11T10:00:00Z","endAt":"2025-03-11T11:00:00Z"}},
+			{"create":{"title":"b","startAt":"2025-03-11T10:30:00Z","endAt":"2025-03-11T11:30:00Z"}}]}`
+		status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/events/batch", "alice", batch)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, false, body["applied"])
+		results := body["results"].([]any)
+		require.Len(t, results, 2)
+		require.InDelta(t, 0, results[0].(map[string]any)["code"], 0)
+		require.InDelta(t, 6, results[1].(map[string]any)["code"], 0) // ALREADY_EXISTS
+
+		batch = `{"operations":[
+			{"create":{"title":"a","startAt":"2025-03-11T10:00:00Z","endAt":"2025-03-11T11:00:00Z"}},
+			{"create":{"title":"b","startAt":"2025-03-11T11:00:00Z","endAt":"2025-03-11T12:00:00Z"}}]}`
+		status, body = doRequest(t, http.MethodPost, ts.URL+"/v1/events/batch", "alice", batch)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, true, body["applied"])
+		require.Equal(t, "b", body["results"].([]any)[1].(map[string]any)["event"].(map[string]any)["title"])
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events/batch", "alice", `{"operations":[{}]}`)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestServerCalendars(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/calendars", "alice", `{"name":"Team Room"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ROLE_OWNER", body["role"])
+	id := body["id"].(string)
+
+	status, _ = doRequest(t, http.MethodPut, ts.URL+"/v1/calendars/"+id+"/members/bob", "alice",
+		`{"role":"ROLE_VIEWER"}`)
+	require.Equal(t, http.StatusOK, status)
+
+	event := `{"title":"planning","calendarId":"` + id + `",` +
+		`"startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T11:00:00Z"}`
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "bob", event)
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+	require.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, http.MethodGet,
+		ts.URL+"/v1/events/day?date=2025-03-10T00:00:00Z&calendarIds="+id, "bob", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["events"], 1)
+
+	status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/calendars/"+id+"/members", "bob", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["members"], 2)
+
+	status, _ = doRequest(t, http.MethodDelete, ts.URL+"/v1/calendars/"+id, "bob", "")
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = doRequest(t, http.MethodDelete, ts.URL+"/v1/calendars/"+id, "carol", "")
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestServerCategories(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/categories", "alice", `{"name":"Travel","color":"#00AA00"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "#00aa00", body["color"])
+	id := body["id"].(string)
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/categories", "alice", `{"name":"travel"}`)
+	require.Equal(t, http.StatusConflict, status)
+	status, _ = doRequest(t, http.MethodPut, ts.URL+"/v1/categories/"+id, "bob", `{"name":"Trips"}`)
+	require.Equal(t, http.StatusNotFound, status)
+
+	trip := `{"title":"trip","categoryId":"` + id + `","tags":["travel","oncall"],` +
+		`"startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T11:00:00Z"}`
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", trip)
+	require.Equal(t, http.StatusOK, status)
+	standup := `{"title":"standup","tags":["oncall"],"startAt":"2025-03-10T12:00:00Z","endAt":"2025-03-10T12:15:00Z"}`
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", standup)
+	require.Equal(t, http.StatusOK, status)
+
+	tests := []struct {
+		query  string
+		titles []any
+	}{
+		{query: "", titles: []any{"trip", "standup"}},
+		{query: "&categoryIds=" + id, titles: []any{"trip"}},
+		{query: "&tags=oncall", titles: []any{"trip", "standup"}},
+		{query: "&tags=oncall&tags=Travel", titles: []any{"trip"}},
+	}
+	for _, tc := range tests {
+		t.Run("filter "+tc.query, func(t *testing.T) {
+			status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/events/day?date=2025-03-10T00:00:00Z"+tc.query, "alice", "")
+			require.Equal(t, http.StatusOK, status)
+			titles := make([]any, 0)
+			for _, event := range body["events"].([]any) {
+				titles = append(titles, event.(map[string]any)["title"])
+			}
+			require.Equal(t, tc.titles, titles)
+		})
+	}
+
+	status, _ = doRequest(t, http.MethodDelete, ts.URL+"/v1/categories/"+id, "alice", "")
+	require.Equal(t, http.StatusOK, status)
+	status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/categories", "alice", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, body["categories"])
+}
+
+func TestServerReports(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice",
+		`{"title":"review","tags":["review"],"startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T11:00:00Z"}`)
+	require.Equal(t, http.StatusOK, status)
+	query := "?from=2025-03-10T00:00:00Z&to=2025-03-24T00:00:00Z&period=REPORT_PERIOD_WEEK"
+
+	status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/reports"+query, "alice", "")
+	require.Equal(t, http.StatusOK, status)
+	rows := body["rows"].([]any)
+	require.Len(t, rows, 2)
+	require.Equal(t, map[string]any{
+		"periodStart": "2025-03-10T00:00:00Z", "periodEnd": "2025-03-17T00:00:00Z",
+		"userId": "alice", "tag": "review", "events": float64(1), "duration": "3600s",
+	}, rows[1])
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/reports/export"+query, nil) //nolint:noctx
+	require.NoError(t, err)
+	req.Header.Set("X-User-Id", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Contains(t, string(data), "2025-03-10T00:00:00Z,2025-03-17T00:00:00Z,alice,review,1,1.00\n")
+
+	status, _ = doRequest(t, http.MethodGet, ts.URL+"/v1/reports?from=2025-03-10T00:00:00Z&to=2025-03-01T00:00:00Z",
+		"alice", "")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestServerAdmin(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice",
+		`{"title":"review","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T11:00:00Z"}`)
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(t, http.MethodGet, ts.URL+"/v1/admin/users/alice/export", "alice", "")
+	require.Equal(t, http.StatusForbidden, status)
+
+	status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/admin/users/alice/export", "admin", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "alice", body["userId"])
+	require.Len(t, body["events"], 1)
+
+	status, body = doRequest(t, http.MethodDelete, ts.URL+"/v1/admin/users/alice", "admin", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "erase_user_data", body["action"])
+	require.Equal(t, float64(1), body["counts"].(map[string]any)["events"])
+
+	status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/admin/audit?userId=alice", "admin", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["records"], 2)
+}
+
+func TestServerLimits(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Rule{Rate: 100, Burst: 100}, []ratelimit.Route{
+		{HTTP: "DELETE /v1/events/{id}", Rule: ratelimit.Rule{Rate: 0.01, Burst: 1}},
+	})
+	ts := newTestServer(t, nil, limiter, 1)
+
+	t.Run("rate limit", func(t *testing.T) {
+		status, _ := doRequest(t, http.MethodDelete, ts.URL+"/v1/events/1", "alice", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, headers, body := doRequestWithHeaders(t, http.MethodDelete, ts.URL+"/v1/events/2", "",
+			http.Header{"X-User-Id": {"alice"}})
+		require.Equal(t, http.StatusTooManyRequests, status)
+		require.Equal(t, "1", headers.Get("Retry-After"))
+		require.Equal(t, "rate limit exceeded", body["message"])
+	})
+
+	t.Run("events quota", func(t *testing.T) {
+		event := `{"title":"standup","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T10:15:00Z"}`
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+		require.Equal(t, http.StatusOK, status)
+
+		event = `{"title":"standup","startAt":"2025-03-11T10:00:00Z","endAt":"2025-03-11T10:15:00Z"}`
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+		require.Equal(t, http.StatusTooManyRequests, status)
+	})
+}
+
+func TestServerAuthentication(t *testing.T) {
+	authenticator := auth.NewStaticTokens(map[string]string{"alice-token": "alice"})
+	ts := newTestServer(t, authenticator, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	event := `{"title":"standup","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T10:15:00Z"}`
+
+	status, headers, _ := doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event,
+		http.Header{"X-User-Id": {"alice"}})
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, `Bearer realm="calendar"`, headers.Get("WWW-Authenticate"))
+
+	status, _, _ = doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event,
+		http.Header{"Authorization": {"Bearer bob-token"}})
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	status, _, body := doRequestWithHeaders(t, http.MethodPost, ts.URL+"/v1/events", event,
+		http.Header{"Authorization": {"Bearer alice-token"}, "X-User-Id": {"bob"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "alice", body["event"].(map[string]any)["userId"])
+}
+
+func TestServerStreams(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	event := `{"title":"standup","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T10:15:00Z"}`
+
+	t.Run("sse", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/v1/events/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-User-Id", "alice")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+		require.Equal(t, http.StatusOK, status)
+
+		reader := bufio.NewReader(resp.Body)
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				break
+			}
+			lines = append(lines, line)
+		}
+		require.Len(t, lines, 3)
+		require.Equal(t, "id: 1\n", lines[0])
+		require.Equal(t, "event: created\n", lines[1])
+		require.Contains(t, lines[2], `"title":"standup"`)
+
+		status, _, body := doRequestWithHeaders(t, http.MethodGet, ts.URL+"/v1/events/stream", "",
+			http.Header{"X-User-Id": {"alice"}, "Last-Event-Id": {"100"}})
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, body["message"], "full resync")
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/events/ws?cursor=1"
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-User-Id": {"bob"}})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		defer conn.Close()
+
+		_, _, err = websocket.DefaultDialer.Dial(url, nil)
+		require.ErrorIs(t, err, websocket.ErrBadHandshake)
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "bob", event)
+		require.Equal(t, http.StatusOK, status)
+
+		change := make(map[string]any)
+		require.NoError(t, conn.ReadJSON(&change))
+		require.Equal(t, "2", change["cursor"])
+		require.Equal(t, "CHANGE_KIND_CREATED", change["kind"])
+		require.Equal(t, "bob", change["event"].(map[string]any)["userId"])
+	})
+}
+
+func TestServerAttachments(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+	event := `{"title":"standup","startAt":"2025-03-10T10:00:00Z","endAt":"2025-03-10T10:15:00Z",
+		"location":{"address":"Room 1","conferenceUrl":"https://meet.example.com/standup"}}`
+
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice", event)
+	require.Equal(t, http.StatusOK, status)
+	created := body["event"].(map[string]any)
+	require.Equal(t, "Room 1", created["location"].(map[string]any)["address"])
+	eventID := created["id"].(string)
+
+	status, _, body = doRequestWithHeaders(t, http.MethodPost,
+		ts.URL+"/v1/events/"+eventID+"/attachments?name=agenda.txt", "hello, world",
+		http.Header{"X-User-Id": {"alice"}, "Content-Type": {"text/plain"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "12", body["size"])
+	id := body["id"].(string)
+
+	get := func(t *testing.T, url string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx
+		require.NoError(t, err)
+		req.Header.Set("X-User-Id", "alice")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(data)
+	}
+
+	t.Run("download", func(t *testing.T) {
+		resp, content := get(t, ts.URL+"/v1/attachments/"+id+"/content")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "hello, world", content)
+		require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+		require.Equal(t, "12", resp.Header.Get("Content-Length"))
+		require.Equal(t, "attachment; filename=agenda.txt", resp.Header.Get("Content-Disposition"))
+
+		resp, _ = get(t, ts.URL+"/v1/attachments/unknown/content")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		status, body := doRequest(t, http.MethodGet, ts.URL+"/v1/events/"+eventID+"/attachments", "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, body["attachments"], 1)
+	})
+
+	t.Run("upload errors", func(t *testing.T) {
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/v1/events/"+eventID+"/attachments", "alice", "no name")
+		require.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events/"+eventID+"/attachments?name=big.txt",
+			"alice", strings.Repeat("x", 1025))
+		require.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events/"+eventID+"/attachments?name=a.txt", "bob", "x")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		resp, calendar := get(t, ts.URL+"/v1/events/export?from=2025-03-01T00:00:00Z&to=2025-04-01T00:00:00Z")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/calendar; charset=utf-8", resp.Header.Get("Content-Type"))
+		require.Contains(t, calendar, "LOCATION:Room 1\r\n")
+		require.Contains(t, calendar, "CONFERENCE;VALUE=URI:https://meet.example.com/standup\r\n")
+		unfolded := strings.ReplaceAll(calendar, "\r\n ", "")
+		require.Contains(t, unfolded,
+			"ATTACH;FMTTYPE=text/plain:https://calendar.example.com/v1/attachments/"+id+"/content\r\n")
+
+		resp, _ = get(t, ts.URL+"/v1/events/export?from=2025-03-01T00:00:00Z&to=2027-03-01T00:00:00Z")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		status, _ := doRequest(t, http.MethodDelete, ts.URL+"/v1/attachments/"+id, "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		resp, _ := get(t, ts.URL+"/v1/attachments/"+id+"/content")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestServerAvailability(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+
+	status, body := doRequest(t, http.MethodPut, ts.URL+"/v1/availability/settings", "alice",
+		`{"timeZone":"Europe/Moscow","workingHours":[{"weekday":1,"start":"09:00","end":"18:00"}]}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "Europe/Moscow", body["timeZone"])
+	status, body = doRequest(t, http.MethodGet, ts.URL+"/v1/availability/settings", "alice", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["workingHours"], 1)
+
+	status, body = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice",
+		`{"title":"sick leave","startAt":"2025-03-10T12:00:00Z","endAt":"2025-03-11T00:00:00Z",
+			"kind":"EVENT_KIND_OUT_OF_OFFICE"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "EVENT_KIND_OUT_OF_OFFICE", body["event"].(map[string]any)["kind"])
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events", "alice",
+		`{"title":"standup","startAt":"2025-03-10T13:00:00Z","endAt":"2025-03-10T13:15:00Z"}`)
+	require.Equal(t, http.StatusConflict, status)
+
+	status, body = doRequest(t, http.MethodGet,
+		ts.URL+"/v1/availability?userId=alice&from=2025-03-10T00:00:00Z&to=2025-03-11T00:00:00Z", "bob", "")
+	require.Equal(t, http.StatusOK, status)
+	slots := body["slots"].([]any)
+	require.Len(t, slots, 3)
+	require.Equal(t, map[string]any{
+		"startAt": "2025-03-10T06:00:00Z", "endAt": "2025-03-10T12:00:00Z", "status": "AVAILABILITY_STATUS_FREE",
+	}, slots[1])
+
+	status, _ = doRequest(t, http.MethodGet,
+		ts.URL+"/v1/availability?from=2025-03-10T00:00:00Z&to=2025-06-11T00:00:00Z", "bob", "")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestServerQuickAdd(t *testing.T) {
+	ts := newTestServer(t, nil, ratelimit.New(ratelimit.Rule{}, nil), 0)
+
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/v1/events/quick-add", "alice",
+		`{"text":"conference 2030-05-20 for 3 days","create":true}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, true, body["created"])
+	require.Equal(t, true, body["allDay"])
+	event := body["events"].([]any)[0].(map[string]any)
+	require.Equal(t, "conference", event["title"])
+	require.Equal(t, "2030-05-20T00:00:00Z", event["startAt"])
+	require.Equal(t, "2030-05-23T00:00:00Z", event["endAt"])
+
+	status, _ = doRequest(t, http.MethodPost, ts.URL+"/v1/events/quick-add", "alice", `{"text":"conference"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+}
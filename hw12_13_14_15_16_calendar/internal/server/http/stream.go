@@ -0,0 +1,316 @@
+package internalhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies.
+const heartbeatInterval = 15 * time.Second
+
+// upgrader accepts same-origin browser connections and any non-browser client.
+var upgrader = websocket.Upgrader{}
+
+// streamHandler serves WatchEvents over server-sent events and WebSocket, which
+// the gateway cannot transcode. The connection is adapted to the server side of
+// the gRPC stream, so the service validates requests and maps errors the same way
+// for all transports.
+type streamHandler struct {
+	service  eventpb.EventServiceServer
+	mux      *runtime.ServeMux
+	shutdown <-chan struct{}
+}
+
+// changeStream is a WatchEvents stream over an HTTP connection.
+type changeStream interface {
+	grpc.ServerStreamingServer[eventpb.EventChange]
+	// responded reports whether the response has been started.
+	responded() bool
+	heartbeat() error
+	// finish ends a started response with the final status of the stream.
+	finish(st *status.Status)
+}
+
+// serveSSE streams changes as server-sent events. Each event carries the change
+// cursor as its id, so a reconnecting EventSource resumes with the Last-Event-ID header.
+func (h *streamHandler) serveSSE(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req, err := watchRequest(r, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		writeStatus(w, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	_, marshaler := runtime.MarshalerForRequest(h.mux, r)
+	h.watch(w, req, &sseStream{
+		baseStream: baseStream{ctx: ctx},
+		w:          w,
+		rc:         http.NewResponseController(w),
+		marshaler:  marshaler,
+	})
+}
+
+// serveWebSocket streams changes as JSON text messages. Clients resume with
+// the cursor query parameter set to the cursor of the last change received.
+func (h *streamHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	req, err := watchRequest(r, "")
+	if err != nil {
+		writeStatus(w, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	_, marshaler := runtime.MarshalerForRequest(h.mux, r)
+	h.watch(w, req, &wsStream{
+		baseStream: baseStream{ctx: ctx},
+		w:          w,
+		r:          r,
+		cancel:     cancel,
+		marshaler:  marshaler,
+	})
+}
+
+func (h *streamHandler) watch(w http.ResponseWriter, req *eventpb.WatchEventsRequest, stream changeStream) {
+	ctx, stop := context.WithCancel(stream.Context())
+	heartbeats := make(chan struct{})
+	go func() {
+		defer close(heartbeats)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if stream.responded() {
+					_ = stream.heartbeat()
+				}
+			}
+		}
+	}()
+
+	err := h.service.WatchEvents(req, stream)
+	stop()
+	<-heartbeats
+
+	if !stream.responded() {
+		writeStatus(w, status.Convert(err))
+		return
+	}
+	stream.finish(status.Convert(err))
+}
+
+// context returns the request context, which is also canceled when the server
+// shuts down, as streams never become idle on their own.
+func (h *streamHandler) context(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	go func() {
+		select {
+		case <-h.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func watchRequest(r *http.Request, cursor string) (*eventpb.WatchEventsRequest, error) {
+	query := r.URL.Query()
+	req := &eventpb.WatchEventsRequest{
+		CalendarIds: append(query["calendarIds"], query["calendar_ids"]...),
+	}
+	if cursor == "" {
+		cursor = query.Get("cursor")
+	}
+	if cursor != "" {
+		var err error
+		if req.Cursor, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	return req, nil
+}
+
+// baseStream implements the parts of grpc.ServerStream the service uses.
+// The embedded interface is nil, other methods are not expected to be called.
+type baseStream struct {
+	grpc.ServerStream
+
+	ctx     context.Context
+	mu      sync.Mutex
+	started bool
+}
+
+func (s *baseStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *baseStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *baseStream) SetTrailer(metadata.MD) {}
+
+func (s *baseStream) responded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+type sseStream struct {
+	baseStream
+
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	marshaler runtime.Marshaler
+}
+
+func (s *sseStream) SendHeader(metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+	s.started = true
+	return s.rc.Flush()
+}
+
+func (s *sseStream) Send(change *eventpb.EventChange) error {
+	data, err := s.marshaler.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", change.GetCursor(), changeKindName(change.GetKind()), data))
+}
+
+func (s *sseStream) heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+// finish reports why the stream ended in an "error" event. Clients that fell
+// behind reconnect with their last cursor, an expired cursor requires a full resync.
+func (s *sseStream) finish(st *status.Status) {
+	if st.Code() == codes.OK || st.Code() == codes.Canceled {
+		return
+	}
+	data, err := s.marshaler.Marshal(st.Proto())
+	if err != nil {
+		return
+	}
+	_ = s.write(fmt.Sprintf("event: error\ndata: %s\n\n", data))
+}
+
+func (s *sseStream) write(frame string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := io.WriteString(s.w, frame); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+type wsStream struct {
+	baseStream
+
+	w         http.ResponseWriter
+	r         *http.Request
+	cancel    context.CancelFunc
+	marshaler runtime.Marshaler
+	conn      *websocket.Conn
+}
+
+// SendHeader upgrades the connection. If the upgrade fails, the upgrader
+// has already replied with an HTTP error.
+func (s *wsStream) SendHeader(metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+	conn, err := upgrader.Upgrade(s.w, s.r, nil)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	go s.read()
+	return nil
+}
+
+// read handles control frames and cancels the stream once the client goes away.
+func (s *wsStream) read() {
+	defer s.cancel()
+	for {
+		if _, _, err := s.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *wsStream) Send(change *eventpb.EventChange) error {
+	data, err := s.marshaler.Marshal(change)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (s *wsStream) heartbeat() error {
+	if s.connection() == nil {
+		return nil
+	}
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeatInterval))
+}
+
+// finish closes the connection. Errors are reported with close codes
+// 4000 + gRPC code, e.g. 4014 (unavailable) for a client that fell behind.
+func (s *wsStream) finish(st *status.Status) {
+	conn := s.connection()
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+
+	code := 4000 + int(st.Code())
+	if st.Code() == codes.OK {
+		code = websocket.CloseNormalClosure
+	} else if st.Code() == codes.Canceled {
+		code = websocket.CloseGoingAway
+	}
+	// A close reason is limited to 123 bytes.
+	reason := st.Message()
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+func (s *wsStream) connection() *websocket.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// changeKindName returns the event name of a change kind, e.g. "created".
+func changeKindName(kind eventpb.ChangeKind) string {
+	return strings.ToLower(strings.TrimPrefix(kind.String(), "CHANGE_KIND_"))
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Attachment describes a file attached to an event. The content is kept in a blob store
+// under the attachment ID.
+type Attachment struct {
+	ID          string
+	EventID     string
+	Name        string
+	ContentType string
+	Size        int64
+	// UserID is the user who attached the file.
+	UserID    string
+	CreatedAt time.Time
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAvailabilityNotFound = errors.New("availability settings not found")
+
+// Availability tells when a user works.
+type Availability struct {
+	UserID string
+	// TimeZone is the IANA name of the zone working hours are in, e.g. "Europe/Moscow".
+	TimeZone string
+	// WorkingHours are the working intervals of the week, days without intervals are days off.
+	WorkingHours []WorkingHours
+}
+
+// WorkingHours is a working interval of a weekday.
+type WorkingHours struct {
+	Weekday time.Weekday
+	// Start and End are times of the day as offsets from midnight.
+	Start time.Duration
+	End   time.Duration
+}
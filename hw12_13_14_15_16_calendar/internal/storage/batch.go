@@ -0,0 +1,8 @@
+package storage
+
+// BatchOp is one operation of a batch. Creates carry the complete event, updates
+// carry the version they are based on, deletes need only Event.ID.
+type BatchOp struct {
+	Kind  ChangeKind
+	Event Event
+}
@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	meter = otel.Meter("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/cache")
+
+	hits, _ = meter.Int64Counter("calendar.cache.hits",
+		metric.WithDescription("Event listings served from the cache."), metric.WithUnit("{listing}"))
+	misses, _ = meter.Int64Counter("calendar.cache.misses",
+		metric.WithDescription("Event listings read from the storage."), metric.WithUnit("{listing}"))
+	evictions, _ = meter.Int64Counter("calendar.cache.evictions",
+		metric.WithDescription("Listings evicted to keep the cache within its size."), metric.WithUnit("{listing}"))
+
+	personalListing = metric.WithAttributes(attribute.String("listing", "personal"))
+	calendarListing = metric.WithAttributes(attribute.String("listing", "calendar"))
+)
+
+// Storage caches listings of personal events per user and of calendar events per calendar.
+// Writes through the cache drop the listings whose range holds the changed events.
+// Writes made by other processes are seen when the listings expire.
+type Storage struct {
+	app.Storage
+
+	maxEvents int
+	ttl       time.Duration
+
+	mu sync.Mutex
+	// lru holds entries, the most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+	// owners indexes entries by the user or calendar whose events they list.
+	owners map[string]map[*entry]struct{}
+	size   int
+	// gen is incremented by every write, listings read from the storage while
+	// it changed may be outdated and are not cached.
+	gen uint64
+}
+
+type entry struct {
+	key      string
+	owner    string
+	from, to time.Time
+	events   []storage.Event
+	expires  time.Time
+}
+
+// New wraps the storage with a cache holding listings of at most maxEvents events
+// in total, each for at most ttl.
+func New(s app.Storage, maxEvents int, ttl time.Duration) *Storage {
+	return &Storage{
+		Storage:   s,
+		maxEvents: maxEvents,
+		ttl:       ttl,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+		owners:    make(map[string]map[*entry]struct{}),
+	}
+}
+
+func (s *Storage) ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	owner := userOwner(userID)
+	s.mu.Lock()
+	events, ok := s.get(owner, from, to)
+	gen := s.gen
+	s.mu.Unlock()
+	if ok {
+		hits.Add(ctx, 1, personalListing)
+		return events, nil
+	}
+
+	misses.Add(ctx, 1, personalListing)
+	events, err := s.Storage.ListEvents(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.put(gen, owner, from, to, events)
+	s.mu.Unlock()
+	return clone(events), nil
+}
+
+// ListCalendarEvents lists the events of every calendar from the cache
+// and reads the calendars that are not cached in one go.
+func (s *Storage) ListCalendarEvents(
+	ctx context.Context, calendarIDs []string, from, to time.Time,
+) ([]storage.Event, error) {
+	events := make([]storage.Event, 0)
+	missing := make([]string, 0)
+	s.mu.Lock()
+	for _, id := range calendarIDs {
+		if cached, ok := s.get(calendarOwner(id), from, to); ok {
+			events = append(events, cached...)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	gen := s.gen
+	s.mu.Unlock()
+	hits.Add(ctx, int64(len(calendarIDs)-len(missing)), calendarListing)
+	if len(missing) == 0 {
+		sortByStart(events)
+		return events, nil
+	}
+
+	misses.Add(ctx, int64(len(missing)), calendarListing)
+	read, err := s.Storage.ListCalendarEvents(ctx, missing, from, to)
+	if err != nil {
+		return nil, err
+	}
+	byCalendar := make(map[string][]storage.Event, len(missing))
+	for _, event := range read {
+		byCalendar[event.CalendarID] = append(byCalendar[event.CalendarID], event)
+	}
+	s.mu.Lock()
+	for _, id := range missing {
+		s.put(gen, calendarOwner(id), from, to, byCalendar[id])
+	}
+	s.mu.Unlock()
+
+	events = append(events, read...)
+	sortByStart(events)
+	return events, nil
+}
+
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event) error {
+	defer s.invalidate(event)
+	return s.Storage.CreateEvent(ctx, event)
+}
+
+func (s *Storage) CreateEventWithKey(
+	ctx context.Context, event storage.Event, key string, notBefore time.Time,
+) (storage.Event, error) {
+	defer s.invalidate(event)
+	return s.Storage.CreateEventWithKey(ctx, event, key, notBefore)
+}
+
+func (s *Storage) UpdateEvent(ctx context.Context, event storage.Event) error {
+	defer s.invalidate(s.affected(ctx, storage.ChangeUpdated, event)...)
+	return s.Storage.UpdateEvent(ctx, event)
+}
+
+func (s *Storage) DeleteEvent(ctx context.Context, id string) error {
+	defer s.invalidate(s.affected(ctx, storage.ChangeDeleted, storage.Event{ID: id})...)
+	return s.Storage.DeleteEvent(ctx, id)
+}
+
+func (s *Storage) ApplyBatch(ctx context.Context, ops []storage.BatchOp) ([]error, error) {
+	changed := make([]storage.Event, 0, len(ops))
+	for _, op := range ops {
+		changed = append(changed, s.affected(ctx, op.Kind, op.Event)...)
+	}
+	defer s.invalidate(changed...)
+	return s.Storage.ApplyBatch(ctx, ops)
+}
+
+func (s *Storage) DeleteCalendar(ctx context.Context, id string) error {
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.gen++
+		for e := range s.owners[calendarOwner(id)] {
+			s.remove(e)
+		}
+	}()
+	return s.Storage.DeleteCalendar(ctx, id)
+}
+
+// DeleteCategory drops every listing: the events of the category may be personal
+// events of its user or events of any calendar the user writes to.
+func (s *Storage) DeleteCategory(ctx context.Context, id string) error {
+	defer s.clear()
+	return s.Storage.DeleteCategory(ctx, id)
+}
+
+// DeleteUserData drops every listing: the user's events may be in any calendar.
+func (s *Storage) DeleteUserData(ctx context.Context, record storage.AuditRecord) (storage.ErasedUserData, error) {
+	defer s.clear()
+	return s.Storage.DeleteUserData(ctx, record)
+}
+
+// affected returns the events whose listings the change drops. An update drops
+// the listings of the stored event and of its new version, that stays in the calendar
+// of the stored one.
+func (s *Storage) affected(ctx context.Context, kind storage.ChangeKind, event storage.Event) []storage.Event {
+	if kind == storage.ChangeCreated {
+		return []storage.Event{event}
+	}
+	current, err := s.Storage.GetEvent(ctx, event.ID)
+	if err != nil {
+		return nil
+	}
+	if kind == storage.ChangeDeleted {
+		return []storage.Event{current}
+	}
+	event.CalendarID = current.CalendarID
+	return []storage.Event{current, event}
+}
+
+// clear drops every listing.
+func (s *Storage) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for s.lru.Len() > 0 {
+		s.remove(s.lru.Back().Value.(*entry))
+	}
+}
+
+// invalidate drops the listings that hold the events.
+func (s *Storage) invalidate(events ...storage.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for _, event := range events {
+		owner := userOwner(event.UserID)
+		if event.CalendarID != "" {
+			owner = calendarOwner(event.CalendarID)
+		}
+		for e := range s.owners[owner] {
+			if !event.StartAt.Before(e.from) && event.StartAt.Before(e.to) {
+				s.remove(e)
+			}
+		}
+	}
+}
+
+// get returns a copy of the cached listing.
+func (s *Storage) get(owner string, from, to time.Time) ([]storage.Event, bool) {
+	elem, ok := s.entries[key(owner, from, to)]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expires) {
+		s.remove(e)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return clone(e.events), true
+}
+
+// put caches the listing read at generation gen unless the storage has changed since.
+func (s *Storage) put(gen uint64, owner string, from, to time.Time, events []storage.Event) {
+	if gen != s.gen || len(events)+1 > s.maxEvents {
+		return
+	}
+	k := key(owner, from, to)
+	if elem, ok := s.entries[k]; ok {
+		s.remove(elem.Value.(*entry))
+	}
+	e := &entry{key: k, owner: owner, from: from, to: to, events: clone(events), expires: time.Now().Add(s.ttl)}
+	s.entries[k] = s.lru.PushFront(e)
+	if s.owners[owner] == nil {
+		s.owners[owner] = make(map[*entry]struct{})
+	}
+	s.owners[owner][e] = struct{}{}
+	s.size += cost(e)
+
+	for s.size > s.maxEvents {
+		s.remove(s.lru.Back().Value.(*entry))
+		evictions.Add(context.Background(), 1)
+	}
+}
+
+func (s *Storage) remove(e *entry) {
+	elem, ok := s.entries[e.key]
+	if !ok || elem.Value != e {
+		return
+	}
+	s.lru.Remove(elem)
+	delete(s.entries, e.key)
+	delete(s.owners[e.owner], e)
+	if len(s.owners[e.owner]) == 0 {
+		delete(s.owners, e.owner)
+	}
+	s.size -= cost(e)
+}
+
+// cost is the share of the cache size taken by the entry, empty listings count too.
+func cost(e *entry) int {
+	return len(e.events) + 1
+}
+
+func key(owner string, from, to time.Time) string {
+	return owner + "/" + strconv.FormatInt(from.UnixNano(), 10) + "/" + strconv.FormatInt(to.UnixNano(), 10)
+}
+
+func userOwner(id string) string {
+	return "user:" + id
+}
+
+func calendarOwner(id string) string {
+	return "calendar:" + id
+}
+
+// clone copies the listing so that callers may modify it.
+func clone(events []storage.Event) []storage.Event {
+	return append(make([]storage.Event, 0, len(events)), events...)
+}
+
+func sortByStart(events []storage.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// counting counts listings read from the storage.
+type counting struct {
+	*memorystorage.Storage
+	reads int
+}
+
+func (c *counting) ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	c.reads++
+	return c.Storage.ListEvents(ctx, userID, from, to)
+}
+
+func (c *counting) ListCalendarEvents(
+	ctx context.Context, calendarIDs []string, from, to time.Time,
+) ([]storage.Event, error) {
+	c.reads++
+	return c.Storage.ListCalendarEvents(ctx, calendarIDs, from, to)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	nextMonth := month.AddDate(0, 1, 0)
+
+	newEvent := func(id string, startAt time.Time) storage.Event {
+		return storage.Event{
+			ID: id, Title: "event " + id, StartAt: startAt, EndAt: startAt.Add(time.Hour), UserID: "user", Version: 1,
+		}
+	}
+	newCache := func(t *testing.T, maxEvents int, ttl time.Duration) (*Storage, *counting) {
+		t.Helper()
+		backend := &counting{Storage: memorystorage.New()}
+		require.NoError(t, backend.CreateCalendar(ctx, storage.Calendar{ID: "team", Name: "Team"}, "user"))
+		require.NoError(t, backend.CreateCalendar(ctx, storage.Calendar{ID: "room", Name: "Room"}, "user"))
+		require.NoError(t, backend.CreateEvent(ctx, newEvent("1", month.Add(10*time.Hour))))
+		shared := newEvent("2", month.AddDate(0, 0, 1))
+		shared.CalendarID = "team"
+		require.NoError(t, backend.CreateEvent(ctx, shared))
+		return New(backend, maxEvents, ttl), backend
+	}
+
+	t.Run("personal listings", func(t *testing.T) {
+		c, backend := newCache(t, 100, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			events, err := c.ListEvents(ctx, "user", month, nextMonth)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+		}
+		require.Equal(t, 1, backend.reads)
+
+		// Listings are copies.
+		events, err := c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		events[0].Title = "changed"
+		_ = append(events, newEvent("3", month))
+		events, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{newEvent("1", month.Add(10*time.Hour))}, events)
+
+		// Writes outside of the range and of other users keep the listing.
+		require.NoError(t, c.CreateEvent(ctx, newEvent("3", nextMonth)))
+		other := newEvent("4", month)
+		other.UserID = "other"
+		require.NoError(t, c.CreateEvent(ctx, other))
+		_, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, 1, backend.reads)
+
+		// Moving an event out of the range drops it.
+		moved := newEvent("1", nextMonth.Add(time.Hour))
+		require.NoError(t, c.UpdateEvent(ctx, moved))
+		events, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Empty(t, events)
+		require.Equal(t, 2, backend.reads)
+
+		require.NoError(t, c.DeleteEvent(ctx, "3"))
+		events, err = c.ListEvents(ctx, "user", nextMonth, nextMonth.AddDate(0, 1, 0))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("calendar listings", func(t *testing.T) {
+		c, backend := newCache(t, 100, time.Minute)
+
+		events, err := c.ListCalendarEvents(ctx, []string{"team", "room"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		_, err = c.ListCalendarEvents(ctx, []string{"room"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, 1, backend.reads)
+
+		moved := newEvent("2", month.AddDate(0, 0, 2))
+		moved.CalendarID = "team"
+		errs, err := c.ApplyBatch(ctx, []storage.BatchOp{{Kind: storage.ChangeUpdated, Event: moved}})
+		require.NoError(t, err)
+		require.Equal(t, []error{nil}, errs)
+		events, err = c.ListCalendarEvents(ctx, []string{"room", "team"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, month.AddDate(0, 0, 2), events[0].StartAt)
+		require.Equal(t, 2, backend.reads)
+
+		require.NoError(t, c.DeleteCalendar(ctx, "team"))
+		events, err = c.ListCalendarEvents(ctx, []string{"room", "team"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("deleted category", func(t *testing.T) {
+		c, _ := newCache(t, 100, time.Minute)
+
+		require.NoError(t, c.CreateCategory(ctx, storage.Category{ID: "travel", UserID: "user", Name: "Travel"}))
+		trip := newEvent("3", month.AddDate(0, 0, 3))
+		trip.CategoryID = "travel"
+		trip.CalendarID = "team"
+		require.NoError(t, c.CreateEvent(ctx, trip))
+		events, err := c.ListCalendarEvents(ctx, []string{"team"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, "travel", events[1].CategoryID)
+
+		require.NoError(t, c.DeleteCategory(ctx, "travel"))
+		events, err = c.ListCalendarEvents(ctx, []string{"team"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Empty(t, events[1].CategoryID)
+	})
+
+	t.Run("erased user", func(t *testing.T) {
+		c, _ := newCache(t, 100, time.Minute)
+
+		events, err := c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		_, err = c.ListCalendarEvents(ctx, []string{"team"}, month, nextMonth)
+		require.NoError(t, err)
+
+		_, err = c.DeleteUserData(ctx, storage.AuditRecord{ActorID: "admin", UserID: "user"})
+		require.NoError(t, err)
+		events, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Empty(t, events)
+		events, err = c.ListCalendarEvents(ctx, []string{"team"}, month, nextMonth)
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("bounded size", func(t *testing.T) {
+		c, backend := newCache(t, 3, time.Minute)
+
+		// Every listing takes its events and one more.
+		_, err := c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		_, err = c.ListEvents(ctx, "other", month, nextMonth)
+		require.NoError(t, err)
+		_, err = c.ListEvents(ctx, "third", month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, 2, c.size)
+
+		_, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, 4, backend.reads)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		c, backend := newCache(t, 100, time.Millisecond)
+
+		_, err := c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = c.ListEvents(ctx, "user", month, nextMonth)
+		require.NoError(t, err)
+		require.Equal(t, 2, backend.reads)
+	})
+}
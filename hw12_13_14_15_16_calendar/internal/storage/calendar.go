@@ -0,0 +1,61 @@
+package storage
+
+import "errors"
+
+var (
+	ErrCalendarNotFound = errors.New("calendar not found")
+	ErrCalendarExists   = errors.New("calendar already exists")
+	ErrMemberNotFound   = errors.New("calendar member not found")
+)
+
+// Role is the access level of a user in a calendar.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+	// RoleFreeBusy sees only when the events of the calendar take place.
+	RoleFreeBusy Role = "freebusy"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleOwner, RoleEditor, RoleViewer, RoleFreeBusy:
+		return true
+	}
+	return false
+}
+
+// CanRead reports whether the role sees event details.
+func (r Role) CanRead() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanWrite reports whether the role may create, update and delete events.
+func (r Role) CanWrite() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// CanManage reports whether the role may share and delete the calendar.
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+// Calendar owns events shared between its members.
+type Calendar struct {
+	ID   string
+	Name string
+}
+
+type Member struct {
+	CalendarID string
+	UserID     string
+	Role       Role
+}
+
+// UserCalendar is a calendar together with the role of a user in it.
+type UserCalendar struct {
+	Calendar
+	Role Role
+}
@@ -0,0 +1,17 @@
+package storage
+
+import "errors"
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("category already exists")
+)
+
+// Category groups events of a user, e.g. "1:1" or "travel". Names are unique per user.
+type Category struct {
+	ID     string
+	UserID string
+	Name   string
+	// Color is an RGB color as "#rrggbb", empty for the default color.
+	Color string
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "errors"
+
+var (
+	ErrCursorExpired    = errors.New("change cursor expired, full resync required")
+	ErrSyncTokenExpired = errors.New("sync token expired, full resync required")
+)
+
+type ChangeKind string
+
+const (
+	ChangeCreated ChangeKind = "created"
+	ChangeUpdated ChangeKind = "updated"
+	ChangeDeleted ChangeKind = "deleted"
+)
+
+// Change is a modification of an event. Seq increases with every change and serves
+// as a cursor to resume from. Changes of deleted events carry only the event ID,
+// user and calendar.
+type Change struct {
+	Seq   int64
+	Kind  ChangeKind
+	Event Event
+}
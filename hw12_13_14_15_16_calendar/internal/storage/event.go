@@ -1,7 +1,87 @@
 package storage
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrDateBusy        = errors.New("time is already taken by another event")
+	ErrEventNotFound   = errors.New("event not found")
+	ErrEventExists     = errors.New("event already exists")
+	ErrVersionConflict = errors.New("event was modified concurrently")
+	ErrOutOfOffice     = errors.New("user is out of office")
+)
+
 type Event struct {
-	ID    string
-	Title string
-	// TODO
+	ID          string
+	Title       string
+	StartAt     time.Time
+	EndAt       time.Time
+	Description string
+	// UserID is the user who created the event.
+	UserID string
+	// CalendarID is empty for personal events of UserID.
+	CalendarID   string
+	Kind         EventKind
+	Location     Location
+	NotifyBefore time.Duration
+	// CategoryID is a category of UserID, empty for uncategorized events.
+	CategoryID string
+	// Tags are free-form labels, e.g. "oncall", unique regardless of case.
+	Tags []string
+	// Version is incremented on every update and is used for optimistic concurrency:
+	// an update must carry the version it was based on.
+	Version int64
+}
+
+type EventKind string
+
+const (
+	// KindRegular is the kind of events that take time of their calendar.
+	KindRegular EventKind = ""
+	// KindOutOfOffice is a personal event telling that its user is away.
+	// Out-of-office periods may overlap events, but new events of the user must not overlap them.
+	KindOutOfOffice EventKind = "out_of_office"
+)
+
+// Location is where an event takes place, in person or online.
+type Location struct {
+	// Address is a room or street address.
+	Address string
+	// ConferenceURL joins the online meeting.
+	ConferenceURL string
+}
+
+// Overlaps reports whether two events of the same calendar intersect in time.
+// Personal events overlap only with personal events of the same user,
+// out-of-office periods do not take the time of other events.
+func (e Event) Overlaps(other Event) bool {
+	if e.CalendarID != other.CalendarID || e.CalendarID == "" && e.UserID != other.UserID {
+		return false
+	}
+	if e.Kind == KindOutOfOffice || other.Kind == KindOutOfOffice {
+		return false
+	}
+	return e.Intersects(other.StartAt, other.EndAt)
+}
+
+// BlockedBy reports whether the event is a regular personal event overlapping
+// the out-of-office period other of the same user.
+func (e Event) BlockedBy(other Event) bool {
+	return e.Kind != KindOutOfOffice && e.CalendarID == "" && other.Kind == KindOutOfOffice &&
+		e.UserID == other.UserID && e.Intersects(other.StartAt, other.EndAt)
+}
+
+// Intersects reports whether the event takes place in [from, to).
+func (e Event) Intersects(from, to time.Time) bool {
+	return e.StartAt.Before(to) && from.Before(e.EndAt)
+}
+
+// Notification is sent to the user when the notification time of an event comes.
+type Notification struct {
+	EventID string    `json:"eventId"`
+	Title   string    `json:"title"`
+	Date    time.Time `json:"date"`
+	UserID  string    `json:"userId"`
 }
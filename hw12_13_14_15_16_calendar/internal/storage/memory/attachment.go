@@ -0,0 +1,86 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) CreateAttachment(_ context.Context, attachment storage.Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[attachment.EventID]; !ok {
+		return storage.ErrEventNotFound
+	}
+	if _, ok := s.attachments[attachment.ID]; ok {
+		return storage.ErrEventExists
+	}
+	if attachment.CreatedAt.IsZero() {
+		attachment.CreatedAt = time.Now()
+	}
+	s.attachments[attachment.ID] = attachment
+	return nil
+}
+
+func (s *Storage) GetAttachment(_ context.Context, id string) (storage.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachment, ok := s.attachments[id]
+	if !ok {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// MissingAttachments returns those of the given attachment IDs that have no attachment.
+func (s *Storage) MissingAttachments(_ context.Context, ids []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if _, ok := s.attachments[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// ListAttachments returns attachments of the events in the order they were attached.
+func (s *Storage) ListAttachments(_ context.Context, eventIDs []string) ([]storage.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[string]struct{}, len(eventIDs))
+	for _, id := range eventIDs {
+		ids[id] = struct{}{}
+	}
+	attachments := make([]storage.Attachment, 0)
+	for _, attachment := range s.attachments {
+		if _, ok := ids[attachment.EventID]; ok {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+		return attachments[i].ID < attachments[j].ID
+	})
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attachments[id]; !ok {
+		return storage.ErrAttachmentNotFound
+	}
+	delete(s.attachments, id)
+	return nil
+}
@@ -0,0 +1,30 @@
+package memorystorage
+
+import (
+	"context"
+	"slices"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) GetAvailability(_ context.Context, userID string) (storage.Availability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	availability, ok := s.availability[userID]
+	if !ok {
+		return storage.Availability{}, storage.ErrAvailabilityNotFound
+	}
+	availability.WorkingHours = slices.Clone(availability.WorkingHours)
+	return availability, nil
+}
+
+// SetAvailability replaces the settings of availability.UserID.
+func (s *Storage) SetAvailability(_ context.Context, availability storage.Availability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	availability.WorkingHours = slices.Clone(availability.WorkingHours)
+	s.availability[availability.UserID] = availability
+	return nil
+}
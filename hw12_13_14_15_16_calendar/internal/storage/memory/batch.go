@@ -0,0 +1,144 @@
+package memorystorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ApplyBatch applies the operations in order if all of them succeed. It returns the error
+// of every operation, nil for those that succeed; if any operation fails, nothing is changed.
+// Operations see the effects of the preceding ones, so events created or moved by the batch
+// must not overlap each other either.
+func (s *Storage) ApplyBatch(_ context.Context, ops []storage.BatchOp) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs, failed := s.checkBatch(ops)
+	if failed {
+		return errs, nil
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case storage.ChangeCreated:
+			s.insertEvent(op.Event)
+		case storage.ChangeUpdated:
+			s.updateEvent(op.Event)
+		case storage.ChangeDeleted:
+			s.deleteEvent(op.Event.ID)
+		}
+	}
+	return errs, nil
+}
+
+// CheckBatch returns the errors ApplyBatch would return without changing anything.
+func (s *Storage) CheckBatch(_ context.Context, ops []storage.BatchOp) ([]error, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	errs, _ := s.checkBatch(ops)
+	return errs, nil
+}
+
+func (s *Storage) checkBatch(ops []storage.BatchOp) ([]error, bool) {
+	b := batch{s: s, staged: make(map[string]*storage.Event, len(ops))}
+	errs := make([]error, len(ops))
+	failed := false
+	for i, op := range ops {
+		if errs[i] = b.stage(op); errs[i] != nil {
+			failed = true
+		}
+	}
+	return errs, failed
+}
+
+// batch checks operations of a batch before any of them is applied. Events changed
+// by the staged operations shadow the stored ones, nil marks a deleted event.
+type batch struct {
+	s      *Storage
+	staged map[string]*storage.Event
+}
+
+func (b *batch) stage(op storage.BatchOp) error {
+	event := op.Event
+	var stored storage.Event
+	switch op.Kind {
+	case storage.ChangeCreated:
+		if _, ok := b.event(event.ID); ok {
+			return storage.ErrEventExists
+		}
+		if _, ok := b.s.calendars[event.CalendarID]; event.CalendarID != "" && !ok {
+			return storage.ErrCalendarNotFound
+		}
+	case storage.ChangeUpdated:
+		var ok bool
+		if stored, ok = b.event(event.ID); !ok {
+			return storage.ErrEventNotFound
+		}
+		if stored.Version != event.Version {
+			return storage.ErrVersionConflict
+		}
+		event.Version++
+	case storage.ChangeDeleted:
+		if _, ok := b.event(event.ID); !ok {
+			return storage.ErrEventNotFound
+		}
+		b.staged[event.ID] = nil
+		return nil
+	default:
+		return fmt.Errorf("unknown batch operation %q", op.Kind)
+	}
+
+	if err := b.s.checkCategory(event); err != nil {
+		return err
+	}
+	if b.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	if (op.Kind == storage.ChangeCreated || rescheduled(stored, event)) && b.isOutOfOffice(event) {
+		return storage.ErrOutOfOffice
+	}
+	b.staged[event.ID] = &event
+	return nil
+}
+
+func (b *batch) event(id string) (storage.Event, bool) {
+	if event, ok := b.staged[id]; ok {
+		if event == nil {
+			return storage.Event{}, false
+		}
+		return *event, true
+	}
+	event, ok := b.s.events[id]
+	return event, ok
+}
+
+func (b *batch) isBusy(event storage.Event) bool {
+	for id, other := range b.s.events {
+		if _, ok := b.staged[id]; !ok && id != event.ID && event.Overlaps(other) {
+			return true
+		}
+	}
+	for id, other := range b.staged {
+		if other != nil && id != event.ID && event.Overlaps(*other) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *batch) isOutOfOffice(event storage.Event) bool {
+	for id, other := range b.s.events {
+		if _, ok := b.staged[id]; !ok && id != event.ID && event.BlockedBy(other) {
+			return true
+		}
+	}
+	for id, other := range b.staged {
+		if other != nil && id != event.ID && event.BlockedBy(*other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// CreateCalendar creates the calendar with ownerID as its owner.
+func (s *Storage) CreateCalendar(_ context.Context, calendar storage.Calendar, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[calendar.ID]; ok {
+		return storage.ErrCalendarExists
+	}
+	s.calendars[calendar.ID] = calendar
+	s.members[calendar.ID] = map[string]storage.Role{ownerID: storage.RoleOwner}
+	return nil
+}
+
+func (s *Storage) GetCalendar(_ context.Context, id string) (storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendar, ok := s.calendars[id]
+	if !ok {
+		return storage.Calendar{}, storage.ErrCalendarNotFound
+	}
+	return calendar, nil
+}
+
+// DeleteCalendar deletes the calendar together with its events.
+func (s *Storage) DeleteCalendar(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[id]; !ok {
+		return storage.ErrCalendarNotFound
+	}
+	for eventID, event := range s.events {
+		if event.CalendarID == id {
+			s.deleteEvent(eventID)
+		}
+	}
+	delete(s.calendars, id)
+	delete(s.members, id)
+	return nil
+}
+
+// SetMember adds the user to the calendar or changes the user's role.
+func (s *Storage) SetMember(_ context.Context, member storage.Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, ok := s.members[member.CalendarID]
+	if !ok {
+		return storage.ErrCalendarNotFound
+	}
+	members[member.UserID] = member.Role
+	return nil
+}
+
+func (s *Storage) RemoveMember(_ context.Context, calendarID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[calendarID][userID]; !ok {
+		return storage.ErrMemberNotFound
+	}
+	delete(s.members[calendarID], userID)
+	return nil
+}
+
+func (s *Storage) GetMember(_ context.Context, calendarID, userID string) (storage.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role, ok := s.members[calendarID][userID]
+	if !ok {
+		return storage.Member{}, storage.ErrMemberNotFound
+	}
+	return storage.Member{CalendarID: calendarID, UserID: userID, Role: role}, nil
+}
+
+// ListMembers returns members of the calendar ordered by user ID.
+func (s *Storage) ListMembers(_ context.Context, calendarID string) ([]storage.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members, ok := s.members[calendarID]
+	if !ok {
+		return nil, storage.ErrCalendarNotFound
+	}
+	result := make([]storage.Member, 0, len(members))
+	for userID, role := range members {
+		result = append(result, storage.Member{CalendarID: calendarID, UserID: userID, Role: role})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+// ListUserCalendars returns calendars the user is a member of ordered by name.
+func (s *Storage) ListUserCalendars(_ context.Context, userID string) ([]storage.UserCalendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.UserCalendar, 0)
+	for id, members := range s.members {
+		if role, ok := members[userID]; ok {
+			result = append(result, storage.UserCalendar{Calendar: s.calendars[id], Role: role})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
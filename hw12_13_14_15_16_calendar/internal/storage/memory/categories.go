@@ -0,0 +1,105 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// CreateCategory creates the category unless its user has one of the same name regardless of case.
+func (s *Storage) CreateCategory(_ context.Context, category storage.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[category.ID]; ok || s.hasCategoryName(category) {
+		return storage.ErrCategoryExists
+	}
+	s.categories[category.ID] = category
+	return nil
+}
+
+func (s *Storage) GetCategory(_ context.Context, id string) (storage.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return storage.Category{}, storage.ErrCategoryNotFound
+	}
+	return category, nil
+}
+
+// UpdateCategory renames and recolors the category, its user cannot change.
+func (s *Storage) UpdateCategory(_ context.Context, category storage.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.categories[category.ID]
+	if !ok {
+		return storage.ErrCategoryNotFound
+	}
+	category.UserID = stored.UserID
+	if s.hasCategoryName(category) {
+		return storage.ErrCategoryExists
+	}
+	s.categories[category.ID] = category
+	return nil
+}
+
+// DeleteCategory deletes the category, its events are updated to have none.
+func (s *Storage) DeleteCategory(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[id]; !ok {
+		return storage.ErrCategoryNotFound
+	}
+	for _, event := range s.events {
+		if event.CategoryID == id {
+			event.CategoryID = ""
+			s.updateEvent(event)
+		}
+	}
+	delete(s.categories, id)
+	return nil
+}
+
+// ListCategories returns categories of the user ordered by name.
+func (s *Storage) ListCategories(_ context.Context, userID string) ([]storage.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := make([]storage.Category, 0)
+	for _, category := range s.categories {
+		if category.UserID == userID {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
+
+// hasCategoryName reports whether another category of the user has the same name.
+func (s *Storage) hasCategoryName(category storage.Category) bool {
+	for id, other := range s.categories {
+		if id != category.ID && other.UserID == category.UserID && strings.EqualFold(other.Name, category.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCategory fails if the event refers to a category that does not exist.
+func (s *Storage) checkCategory(event storage.Event) error {
+	if _, ok := s.categories[event.CategoryID]; event.CategoryID != "" && !ok {
+		return storage.ErrCategoryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ListChanges returns the latest changes after since of the user's personal events,
+// if userID is not empty, and of events of the calendars, ordered by sequence.
+// At most limit changes are returned, zero means no limit.
+// Changes of deleted events are kept until their tombstones are purged.
+func (s *Storage) ListChanges(
+	_ context.Context, userID string, calendarIDs []string, since int64, limit int,
+) ([]storage.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if since > s.seq || since > 0 && since < s.purgedSeq {
+		return nil, storage.ErrSyncTokenExpired
+	}
+
+	ids := make(map[string]struct{}, len(calendarIDs))
+	for _, id := range calendarIDs {
+		ids[id] = struct{}{}
+	}
+	match := func(event storage.Event) bool {
+		if event.CalendarID == "" {
+			return userID != "" && event.UserID == userID
+		}
+		_, ok := ids[event.CalendarID]
+		return ok
+	}
+
+	changes := make([]storage.Change, 0)
+	for id, seq := range s.changes {
+		event := s.events[id]
+		if seq.changed <= since || !match(event) {
+			continue
+		}
+		kind := storage.ChangeUpdated
+		if seq.created > since {
+			kind = storage.ChangeCreated
+		}
+		changes = append(changes, storage.Change{Seq: seq.changed, Kind: kind, Event: event})
+	}
+	for _, t := range s.tombstones {
+		if t.seq > since && match(t.event) {
+			changes = append(changes, storage.Change{Seq: t.seq, Kind: storage.ChangeDeleted, Event: t.event})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Seq < changes[j].Seq
+	})
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+// PurgeTombstones forgets events deleted before the given time and returns
+// the number of purged tombstones. Syncing from earlier changes is no longer possible.
+func (s *Storage) PurgeTombstones(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, t := range s.tombstones {
+		if t.deletedAt.Before(before) {
+			delete(s.tombstones, id)
+			s.purgedSeq = max(s.purgedSeq, t.seq)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,96 @@
+package memorystorage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type outboxRecord struct {
+	message storage.OutboxMessage
+	sentAt  time.Time
+}
+
+// EnqueueNotification marks the event as notified and adds the notification to the outbox.
+func (s *Storage) EnqueueNotification(_ context.Context, eventID string, msg storage.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[eventID]; !ok {
+		return storage.ErrEventNotFound
+	}
+	s.notified[eventID] = struct{}{}
+	s.enqueue(msg)
+	return nil
+}
+
+// ListOutbox returns at most limit messages that have not been sent yet, oldest first.
+func (s *Storage) ListOutbox(_ context.Context, limit int) ([]storage.OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := make([]storage.OutboxMessage, 0)
+	for _, record := range s.outbox {
+		if len(messages) == limit {
+			break
+		}
+		if record.sentAt.IsZero() {
+			messages = append(messages, record.message)
+		}
+	}
+	return messages, nil
+}
+
+func (s *Storage) MarkSent(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		sent[id] = struct{}{}
+	}
+	now := time.Now()
+	for i, record := range s.outbox {
+		if _, ok := sent[record.message.ID]; ok && record.sentAt.IsZero() {
+			s.outbox[i].sentAt = now
+		}
+	}
+	return nil
+}
+
+// PurgeOutbox deletes messages sent before the given time and returns their number.
+func (s *Storage) PurgeOutbox(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.outbox[:0]
+	for _, record := range s.outbox {
+		if record.sentAt.IsZero() || !record.sentAt.Before(before) {
+			kept = append(kept, record)
+		}
+	}
+	count := len(s.outbox) - len(kept)
+	clear(s.outbox[len(kept):])
+	s.outbox = kept
+	return count, nil
+}
+
+func (s *Storage) enqueue(msg storage.OutboxMessage) {
+	s.outboxSeq++
+	msg.ID = s.outboxSeq
+	s.outbox = append(s.outbox, outboxRecord{message: msg})
+}
+
+func (s *Storage) enqueueChange(kind storage.ChangeKind, event storage.Event) {
+	// The message always marshals.
+	body, _ := json.Marshal(storage.EventChanged{
+		Kind:       kind,
+		EventID:    event.ID,
+		UserID:     event.UserID,
+		CalendarID: event.CalendarID,
+		Version:    event.Version,
+	})
+	s.enqueue(storage.OutboxMessage{Topic: storage.TopicEventChanges, Body: body})
+}
@@ -0,0 +1,101 @@
+package memorystorage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type usageKey struct {
+	period int
+	userID string
+	tag    string
+}
+
+// ReportUsage aggregates the events selected by the query, see storage.Usage.
+// Rows are ordered by period, user and tag.
+func (s *Storage) ReportUsage(_ context.Context, query storage.UsageQuery) ([]storage.Usage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	spans := make(map[usageKey][]storage.Period)
+	for _, event := range s.events {
+		if event.Kind == storage.KindOutOfOffice {
+			continue
+		}
+		if event.CalendarID == "" && (query.UserID == "" || event.UserID != query.UserID) ||
+			event.CalendarID != "" && !slices.Contains(query.CalendarIDs, event.CalendarID) {
+			continue
+		}
+		for i, period := range query.Periods {
+			if !event.Intersects(period.Start, period.End) {
+				continue
+			}
+			span := storage.Period{Start: maxTime(event.StartAt, period.Start), End: minTime(event.EndAt, period.End)}
+			key := usageKey{period: i, userID: event.UserID}
+			spans[key] = append(spans[key], span)
+			for _, tag := range event.Tags {
+				key.tag = strings.ToLower(tag)
+				spans[key] = append(spans[key], span)
+			}
+		}
+	}
+
+	usage := make([]storage.Usage, 0, len(spans))
+	for key, periods := range spans {
+		usage = append(usage, storage.Usage{
+			Period:   query.Periods[key.period],
+			UserID:   key.userID,
+			Tag:      key.tag,
+			Events:   len(periods),
+			Duration: unionLength(periods),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		if !a.Period.Start.Equal(b.Period.Start) {
+			return a.Period.Start.Before(b.Period.Start)
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Tag < b.Tag
+	})
+	return usage, nil
+}
+
+// unionLength returns the length of the union of the ranges, it sorts them in place.
+func unionLength(periods []storage.Period) time.Duration {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Before(periods[j].Start)
+	})
+	var total time.Duration
+	current := periods[0]
+	for _, period := range periods[1:] {
+		if period.Start.After(current.End) {
+			total += current.End.Sub(current.Start)
+			current = period
+			continue
+		}
+		current.End = maxTime(current.End, period.End)
+	}
+	return total + current.End.Sub(current.Start)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -1,14 +1,429 @@
 package memorystorage
 
-import "sync"
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
 
 type Storage struct {
-	// TODO
-	mu sync.RWMutex //nolint:unused
+	mu              sync.RWMutex
+	events          map[string]storage.Event
+	idempotencyKeys map[idempotencyKey]idempotencyRecord
+	notified        map[string]struct{}
+	calendars       map[string]storage.Calendar
+	// members maps calendar IDs to the roles of their users.
+	members     map[string]map[string]storage.Role
+	attachments map[string]storage.Attachment
+	categories  map[string]storage.Category
+	// availability maps user IDs to their settings.
+	availability map[string]storage.Availability
+
+	// seq is the change sequence, see ListChanges.
+	seq        int64
+	changes    map[string]changeSeq
+	tombstones map[string]tombstone
+	// purgedSeq is the sequence of the latest purged tombstone.
+	purgedSeq int64
+
+	// outbox keeps messages in the order they were added.
+	outbox    []outboxRecord
+	outboxSeq int64
+
+	audit []storage.AuditRecord
+}
+
+type changeSeq struct {
+	created int64
+	changed int64
+}
+
+type tombstone struct {
+	event     storage.Event
+	seq       int64
+	deletedAt time.Time
+}
+
+type idempotencyKey struct {
+	userID string
+	key    string
+}
+
+type idempotencyRecord struct {
+	eventID   string
+	createdAt time.Time
 }
 
 func New() *Storage {
-	return &Storage{}
+	return &Storage{
+		events:          make(map[string]storage.Event),
+		idempotencyKeys: make(map[idempotencyKey]idempotencyRecord),
+		notified:        make(map[string]struct{}),
+		calendars:       make(map[string]storage.Calendar),
+		members:         make(map[string]map[string]storage.Role),
+		attachments:     make(map[string]storage.Attachment),
+		categories:      make(map[string]storage.Category),
+		availability:    make(map[string]storage.Availability),
+		changes:         make(map[string]changeSeq),
+		tombstones:      make(map[string]tombstone),
+	}
+}
+
+func (s *Storage) CreateEvent(_ context.Context, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createEvent(event)
 }
 
-// TODO
+// CreateEventWithKey creates the event unless the user already created one with the same
+// idempotency key after notBefore, in which case the earlier event is returned.
+func (s *Storage) CreateEventWithKey(
+	_ context.Context, event storage.Event, key string, notBefore time.Time,
+) (storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, err := s.getEventByKey(event.UserID, key, notBefore); err == nil {
+		return existing, nil
+	}
+	k := idempotencyKey{userID: event.UserID, key: key}
+
+	if err := s.createEvent(event); err != nil {
+		return storage.Event{}, err
+	}
+	// There is no scheduler scanning the memory storage, expired keys are purged on write.
+	s.purgeIdempotencyKeys(notBefore)
+	s.idempotencyKeys[k] = idempotencyRecord{eventID: event.ID, createdAt: time.Now()}
+	return event, nil
+}
+
+// GetEventByKey returns the event the user created with the idempotency key after notBefore,
+// storage.ErrEventNotFound if there is none.
+func (s *Storage) GetEventByKey(_ context.Context, userID, key string, notBefore time.Time) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getEventByKey(userID, key, notBefore)
+}
+
+func (s *Storage) getEventByKey(userID, key string, notBefore time.Time) (storage.Event, error) {
+	record, ok := s.idempotencyKeys[idempotencyKey{userID: userID, key: key}]
+	if !ok || record.createdAt.Before(notBefore) {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	event, ok := s.events[record.eventID]
+	if !ok {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return event, nil
+}
+
+// PurgeIdempotencyKeys forgets idempotency keys used before the given time and returns
+// the number of purged keys.
+func (s *Storage) PurgeIdempotencyKeys(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purgeIdempotencyKeys(before), nil
+}
+
+func (s *Storage) purgeIdempotencyKeys(before time.Time) int {
+	count := 0
+	for k, record := range s.idempotencyKeys {
+		if record.createdAt.Before(before) {
+			delete(s.idempotencyKeys, k)
+			count++
+		}
+	}
+	return count
+}
+
+// UpdateEvent replaces the event if its stored version equals event.Version
+// and increments the stored version.
+func (s *Storage) UpdateEvent(_ context.Context, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[event.ID]
+	if !ok {
+		return storage.ErrEventNotFound
+	}
+	if stored.Version != event.Version {
+		return storage.ErrVersionConflict
+	}
+	if err := s.checkCategory(event); err != nil {
+		return err
+	}
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	if rescheduled(stored, event) && s.isOutOfOffice(event) {
+		return storage.ErrOutOfOffice
+	}
+	s.updateEvent(event)
+	return nil
+}
+
+func (s *Storage) DeleteEvent(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[id]; !ok {
+		return storage.ErrEventNotFound
+	}
+	s.deleteEvent(id)
+	return nil
+}
+
+func (s *Storage) GetEvent(_ context.Context, id string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return event, nil
+}
+
+// ListEvents returns user's personal events starting in [from, to) ordered by start time.
+func (s *Storage) ListEvents(_ context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listEvents(func(event storage.Event) bool {
+		return event.CalendarID == "" && event.UserID == userID
+	}, from, to), nil
+}
+
+// ListOverlappingEvents returns user's personal events taking place in [from, to) ordered by start time.
+func (s *Storage) ListOverlappingEvents(
+	_ context.Context, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if event.CalendarID == "" && event.UserID == userID && event.Intersects(from, to) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+	return events, nil
+}
+
+// ListCalendarEvents returns events of the calendars starting in [from, to) ordered by start time.
+func (s *Storage) ListCalendarEvents(
+	_ context.Context, calendarIDs []string, from, to time.Time,
+) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[string]struct{}, len(calendarIDs))
+	for _, id := range calendarIDs {
+		ids[id] = struct{}{}
+	}
+	return s.listEvents(func(event storage.Event) bool {
+		_, ok := ids[event.CalendarID]
+		return ok && event.CalendarID != ""
+	}, from, to), nil
+}
+
+func (s *Storage) CountEvents(_ context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, event := range s.events {
+		if event.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ListEventsToNotify returns events whose notification time has come by now
+// and that have not been marked as notified yet.
+func (s *Storage) ListEventsToNotify(_ context.Context, now time.Time) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for id, event := range s.events {
+		if _, ok := s.notified[id]; ok || event.NotifyBefore <= 0 {
+			continue
+		}
+		if event.StartAt.Add(-event.NotifyBefore).After(now) || !event.StartAt.After(now) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+	return events, nil
+}
+
+func (s *Storage) MarkNotified(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[id]; ok {
+		s.notified[id] = struct{}{}
+	}
+	return nil
+}
+
+// ListExpiredEvents returns at most limit events selected by the filter, those that ended first go first.
+func (s *Storage) ListExpiredEvents(
+	_ context.Context, filter storage.ExpiryFilter, limit int,
+) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EndAt.Before(events[j].EndAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// DeleteEvents deletes the events unless they have been modified since they were read
+// and returns the number of deleted events.
+func (s *Storage) DeleteEvents(_ context.Context, events []storage.Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, event := range events {
+		if stored, ok := s.events[event.ID]; ok && stored.Version == event.Version {
+			s.deleteEvent(event.ID)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Storage) listEvents(match func(storage.Event) bool, from, to time.Time) []storage.Event {
+	events := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if !match(event) || event.StartAt.Before(from) || !event.StartAt.Before(to) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+	return events
+}
+
+func (s *Storage) createEvent(event storage.Event) error {
+	if _, ok := s.events[event.ID]; ok {
+		return storage.ErrEventExists
+	}
+	if _, ok := s.calendars[event.CalendarID]; event.CalendarID != "" && !ok {
+		return storage.ErrCalendarNotFound
+	}
+	if err := s.checkCategory(event); err != nil {
+		return err
+	}
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	if s.isOutOfOffice(event) {
+		return storage.ErrOutOfOffice
+	}
+	s.insertEvent(event)
+	return nil
+}
+
+func (s *Storage) insertEvent(event storage.Event) {
+	event.Tags = slices.Clone(event.Tags)
+	s.events[event.ID] = event
+	s.seq++
+	s.changes[event.ID] = changeSeq{created: s.seq, changed: s.seq}
+	delete(s.tombstones, event.ID)
+	s.enqueueChange(storage.ChangeCreated, event)
+}
+
+// updateEvent stores the event and increments its version.
+func (s *Storage) updateEvent(event storage.Event) {
+	stored := s.events[event.ID]
+	if !stored.StartAt.Equal(event.StartAt) || stored.NotifyBefore != event.NotifyBefore {
+		// a rescheduled event is notified again
+		delete(s.notified, event.ID)
+	}
+	event.Version++
+	event.Tags = slices.Clone(event.Tags)
+	s.events[event.ID] = event
+	s.seq++
+	s.changes[event.ID] = changeSeq{created: s.changes[event.ID].created, changed: s.seq}
+	s.enqueueChange(storage.ChangeUpdated, event)
+}
+
+// deleteEvent deletes the event and leaves its tombstone.
+func (s *Storage) deleteEvent(id string) {
+	event := s.events[id]
+	delete(s.events, id)
+	delete(s.notified, id)
+	delete(s.changes, id)
+	for attachmentID, attachment := range s.attachments {
+		if attachment.EventID == id {
+			delete(s.attachments, attachmentID)
+		}
+	}
+	for k, record := range s.idempotencyKeys {
+		if record.eventID == id {
+			delete(s.idempotencyKeys, k)
+		}
+	}
+	s.seq++
+	s.tombstones[id] = tombstone{
+		event:     storage.Event{ID: id, UserID: event.UserID, CalendarID: event.CalendarID},
+		seq:       s.seq,
+		deletedAt: time.Now(),
+	}
+	s.enqueueChange(storage.ChangeDeleted, storage.Event{ID: id, UserID: event.UserID, CalendarID: event.CalendarID})
+}
+
+func (s *Storage) isBusy(event storage.Event) bool {
+	for id, other := range s.events {
+		if id != event.ID && event.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOutOfOffice reports whether the event is blocked by an out-of-office period.
+func (s *Storage) isOutOfOffice(event storage.Event) bool {
+	for id, other := range s.events {
+		if id != event.ID && event.BlockedBy(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// rescheduled reports whether the update moves the event or changes its kind, events
+// overlapping periods created after them may still be edited otherwise.
+func rescheduled(stored, event storage.Event) bool {
+	return !stored.StartAt.Equal(event.StartAt) || !stored.EndAt.Equal(event.EndAt) || stored.Kind != event.Kind
+}
@@ -1,7 +1,649 @@
 package memorystorage
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
 
 func TestStorage(t *testing.T) {
-	// TODO
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	newEvent := func(id string, startAt time.Time) storage.Event {
+		return storage.Event{
+			ID:      id,
+			Title:   "event " + id,
+			StartAt: startAt,
+			EndAt:   startAt.Add(time.Hour),
+			UserID:  "user",
+		}
+	}
+
+	t.Run("crud", func(t *testing.T) {
+		s := New()
+
+		event := newEvent("1", start)
+		require.NoError(t, s.CreateEvent(ctx, event))
+		require.ErrorIs(t, s.CreateEvent(ctx, event), storage.ErrEventExists)
+
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, event, got)
+
+		event.Title = "updated"
+		require.NoError(t, s.UpdateEvent(ctx, event))
+		got, err = s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, "updated", got.Title)
+
+		require.NoError(t, s.DeleteEvent(ctx, "1"))
+		_, err = s.GetEvent(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		require.ErrorIs(t, s.DeleteEvent(ctx, "1"), storage.ErrEventNotFound)
+		require.ErrorIs(t, s.UpdateEvent(ctx, event), storage.ErrEventNotFound)
+	})
+
+	t.Run("date busy", func(t *testing.T) {
+		s := New()
+
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", start)))
+		require.ErrorIs(t, s.CreateEvent(ctx, newEvent("2", start.Add(30*time.Minute))), storage.ErrDateBusy)
+		require.NoError(t, s.CreateEvent(ctx, newEvent("3", start.Add(time.Hour))))
+
+		other := newEvent("4", start)
+		other.UserID = "other"
+		require.NoError(t, s.CreateEvent(ctx, other))
+
+		// Moving an event over itself is not a conflict.
+		moved := newEvent("1", start.Add(-30*time.Minute))
+		require.NoError(t, s.UpdateEvent(ctx, moved))
+		moved = newEvent("1", start.Add(30*time.Minute))
+		moved.Version = 1
+		require.ErrorIs(t, s.UpdateEvent(ctx, moved), storage.ErrDateBusy)
+	})
+
+	t.Run("out of office", func(t *testing.T) {
+		s := New()
+
+		planned := newEvent("1", start.Add(time.Hour))
+		require.NoError(t, s.CreateEvent(ctx, planned))
+		away := newEvent("2", start)
+		away.Kind = storage.KindOutOfOffice
+		away.EndAt = start.Add(4 * time.Hour)
+		require.NoError(t, s.CreateEvent(ctx, away))
+		require.ErrorIs(t, s.CreateEvent(ctx, newEvent("3", start.Add(2*time.Hour))), storage.ErrOutOfOffice)
+
+		// Events planned before the period may be changed but not moved within it.
+		planned.Title = "planned"
+		require.NoError(t, s.UpdateEvent(ctx, planned))
+		planned = newEvent("1", start.Add(3*time.Hour))
+		planned.Version = 1
+		require.ErrorIs(t, s.UpdateEvent(ctx, planned), storage.ErrOutOfOffice)
+
+		// Periods created by the batch block the following operations.
+		errs, err := s.ApplyBatch(ctx, []storage.BatchOp{
+			{Kind: storage.ChangeCreated, Event: storage.Event{
+				ID: "4", UserID: "user", Kind: storage.KindOutOfOffice, StartAt: start.AddDate(0, 0, 1),
+				EndAt: start.AddDate(0, 0, 2),
+			}},
+			{Kind: storage.ChangeCreated, Event: newEvent("5", start.AddDate(0, 0, 1))},
+		})
+		require.NoError(t, err)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], storage.ErrOutOfOffice)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		s := New()
+
+		event := newEvent("1", start)
+		event.Version = 1
+		require.NoError(t, s.CreateEvent(ctx, event))
+		require.NoError(t, s.UpdateEvent(ctx, event))
+
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, int64(2), got.Version)
+		require.ErrorIs(t, s.UpdateEvent(ctx, event), storage.ErrVersionConflict)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		s := New()
+
+		created, err := s.CreateEventWithKey(ctx, newEvent("1", start), "key", start)
+		require.NoError(t, err)
+		require.Equal(t, "1", created.ID)
+
+		replayed, err := s.CreateEventWithKey(ctx, newEvent("2", start.Add(time.Hour)), "key", start)
+		require.NoError(t, err)
+		require.Equal(t, "1", replayed.ID)
+
+		// The key has expired.
+		created, err = s.CreateEventWithKey(ctx, newEvent("3", start.Add(time.Hour)), "key", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, "3", created.ID)
+
+		// Keys are forgotten with their events and when they are purged.
+		_, err = s.CreateEventWithKey(ctx, newEvent("4", start.Add(2*time.Hour)), "other", start)
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, "3"))
+		require.Len(t, s.idempotencyKeys, 1)
+		purged, err := s.PurgeIdempotencyKeys(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 1, purged)
+		require.Empty(t, s.idempotencyKeys)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := New()
+
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", start.AddDate(0, 0, 1))))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", start)))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("3", start.AddDate(0, 0, 7))))
+
+		events, err := s.ListEvents(ctx, "user", start, start.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "1", events[0].ID)
+		require.Equal(t, "2", events[1].ID)
+
+		events, err = s.ListEvents(ctx, "other", start, start.AddDate(0, 1, 0))
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("notifications", func(t *testing.T) {
+		s := New()
+
+		event := newEvent("1", start)
+		event.NotifyBefore = time.Hour
+		require.NoError(t, s.CreateEvent(ctx, event))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", start.Add(2*time.Hour))))
+
+		events, err := s.ListEventsToNotify(ctx, start.Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		events, err = s.ListEventsToNotify(ctx, start.Add(-30*time.Minute))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "1", events[0].ID)
+
+		require.NoError(t, s.MarkNotified(ctx, "1"))
+		events, err = s.ListEventsToNotify(ctx, start.Add(-30*time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "room", Name: "Team Room"}, "alice"))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", start)))
+		shared := newEvent("2", start.Add(time.Hour))
+		shared.CalendarID = "room"
+		require.NoError(t, s.CreateEvent(ctx, shared))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("3", start.AddDate(0, 0, 1))))
+
+		before := start.Add(3 * time.Hour)
+		events, err := s.ListExpiredEvents(ctx, storage.ExpiryFilter{Before: before}, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "1", events[0].ID)
+		events, err = s.ListExpiredEvents(ctx, storage.ExpiryFilter{Before: before}, 1)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		events, err = s.ListExpiredEvents(ctx, storage.ExpiryFilter{Before: before, CalendarIDs: []string{"room"}}, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "2", events[0].ID)
+		events, err = s.ListExpiredEvents(ctx, storage.ExpiryFilter{Before: before, ExceptCalendarIDs: []string{"room"}}, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "1", events[0].ID)
+
+		// Events modified since they were listed are kept.
+		modified := events[0]
+		modified.Title = "modified"
+		require.NoError(t, s.UpdateEvent(ctx, modified))
+		deleted, err := s.DeleteEvents(ctx, []storage.Event{events[0], shared})
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+		_, err = s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		_, err = s.GetEvent(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("calendars", func(t *testing.T) {
+		s := New()
+
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "room", Name: "Team Room"}, "alice"))
+		require.NoError(t, s.SetMember(ctx, storage.Member{CalendarID: "room", UserID: "bob", Role: storage.RoleViewer}))
+		require.ErrorIs(t, s.SetMember(ctx, storage.Member{CalendarID: "none", UserID: "bob"}), storage.ErrCalendarNotFound)
+
+		calendars, err := s.ListUserCalendars(ctx, "bob")
+		require.NoError(t, err)
+		require.Equal(t, []storage.UserCalendar{
+			{Calendar: storage.Calendar{ID: "room", Name: "Team Room"}, Role: storage.RoleViewer},
+		}, calendars)
+
+		// Calendar events overlap with each other but not with personal events.
+		shared := newEvent("1", start)
+		shared.CalendarID = "room"
+		require.NoError(t, s.CreateEvent(ctx, shared))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", start)))
+		other := newEvent("3", start)
+		other.UserID = "other"
+		other.CalendarID = "room"
+		require.ErrorIs(t, s.CreateEvent(ctx, other), storage.ErrDateBusy)
+		other.CalendarID = "none"
+		require.ErrorIs(t, s.CreateEvent(ctx, other), storage.ErrCalendarNotFound)
+
+		events, err := s.ListCalendarEvents(ctx, []string{"room"}, start, start.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "1", events[0].ID)
+		events, err = s.ListEvents(ctx, "user", start, start.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "2", events[0].ID)
+
+		require.NoError(t, s.RemoveMember(ctx, "room", "bob"))
+		_, err = s.GetMember(ctx, "room", "bob")
+		require.ErrorIs(t, err, storage.ErrMemberNotFound)
+
+		require.NoError(t, s.DeleteCalendar(ctx, "room"))
+		_, err = s.GetEvent(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		s := New()
+
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "room", Name: "Team Room"}, "alice"))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", start)))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", start.Add(time.Hour))))
+		shared := newEvent("3", start)
+		shared.UserID = "alice"
+		shared.CalendarID = "room"
+		require.NoError(t, s.CreateEvent(ctx, shared))
+
+		changes, err := s.ListChanges(ctx, "user", nil, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		since := changes[1].Seq
+
+		updated := newEvent("1", start)
+		updated.Title = "updated"
+		require.NoError(t, s.UpdateEvent(ctx, updated))
+		require.NoError(t, s.DeleteEvent(ctx, "2"))
+		require.NoError(t, s.DeleteCalendar(ctx, "room"))
+
+		changes, err = s.ListChanges(ctx, "user", []string{"room"}, since, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		require.Equal(t, storage.ChangeUpdated, changes[0].Kind)
+		require.Equal(t, "updated", changes[0].Event.Title)
+		require.Equal(t, storage.Change{
+			Seq: changes[1].Seq, Kind: storage.ChangeDeleted, Event: storage.Event{ID: "2", UserID: "user"},
+		}, changes[1])
+		require.Equal(t, storage.ChangeDeleted, changes[2].Kind)
+		require.Equal(t, "room", changes[2].Event.CalendarID)
+
+		changes, err = s.ListChanges(ctx, "user", nil, since, 1)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+
+		_, err = s.ListChanges(ctx, "user", nil, 100, 0)
+		require.ErrorIs(t, err, storage.ErrSyncTokenExpired)
+		purged, err := s.PurgeTombstones(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 2, purged)
+		_, err = s.ListChanges(ctx, "user", nil, since, 0)
+		require.ErrorIs(t, err, storage.ErrSyncTokenExpired)
+
+		changes, err = s.ListChanges(ctx, "user", nil, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, storage.ChangeCreated, changes[0].Kind)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		s := New()
+
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", start)))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", start.Add(time.Hour))))
+
+		// Events of the batch overlap each other, and the update is based on a stale version.
+		stale := newEvent("1", start)
+		stale.Version = 5
+		errs, err := s.ApplyBatch(ctx, []storage.BatchOp{
+			{Kind: storage.ChangeCreated, Event: newEvent("3", start.Add(2*time.Hour))},
+			{Kind: storage.ChangeCreated, Event: newEvent("4", start.Add(150*time.Minute))},
+			{Kind: storage.ChangeUpdated, Event: stale},
+			{Kind: storage.ChangeDeleted, Event: storage.Event{ID: "5"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, errs, 4)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], storage.ErrDateBusy)
+		require.ErrorIs(t, errs[2], storage.ErrVersionConflict)
+		require.ErrorIs(t, errs[3], storage.ErrEventNotFound)
+		_, err = s.GetEvent(ctx, "3")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		// Operations see the effects of the preceding ones: the slot freed by
+		// the delete is taken by the moved event, which frees its own slot.
+		moved := newEvent("2", start)
+		errs, err = s.ApplyBatch(ctx, []storage.BatchOp{
+			{Kind: storage.ChangeDeleted, Event: storage.Event{ID: "1"}},
+			{Kind: storage.ChangeUpdated, Event: moved},
+			{Kind: storage.ChangeCreated, Event: newEvent("3", start.Add(time.Hour))},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []error{nil, nil, nil}, errs)
+
+		events, err := s.ListEvents(ctx, "user", start, start.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "2", events[0].ID)
+		require.Equal(t, int64(1), events[0].Version)
+		require.Equal(t, "3", events[1].ID)
+
+		changes, err := s.ListChanges(ctx, "user", nil, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", start)
+		require.NoError(t, s.CreateEvent(ctx, event))
+		event.Title = "moved"
+		require.NoError(t, s.UpdateEvent(ctx, event))
+		require.NoError(t, s.DeleteEvent(ctx, "1"))
+
+		messages, err := s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		kinds := make([]storage.ChangeKind, 0, len(messages))
+		for _, msg := range messages {
+			require.Equal(t, storage.TopicEventChanges, msg.Topic)
+			var changed storage.EventChanged
+			require.NoError(t, json.Unmarshal(msg.Body, &changed))
+			require.Equal(t, "1", changed.EventID)
+			kinds = append(kinds, changed.Kind)
+		}
+		require.Equal(t, []storage.ChangeKind{
+			storage.ChangeCreated, storage.ChangeUpdated, storage.ChangeDeleted,
+		}, kinds)
+
+		notification := storage.OutboxMessage{Topic: storage.TopicNotifications, Body: []byte(`{}`)}
+		require.ErrorIs(t, s.EnqueueNotification(ctx, "1", notification), storage.ErrEventNotFound)
+		due := newEvent("2", start)
+		due.NotifyBefore = time.Hour
+		require.NoError(t, s.CreateEvent(ctx, due))
+		require.NoError(t, s.EnqueueNotification(ctx, "2", notification))
+		toNotify, err := s.ListEventsToNotify(ctx, start.Add(-time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, toNotify)
+
+		messages, err = s.ListOutbox(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		require.NoError(t, s.MarkSent(ctx, []int64{messages[0].ID, messages[1].ID}))
+		messages, err = s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		require.Equal(t, storage.TopicNotifications, messages[2].Topic)
+
+		purged, err := s.PurgeOutbox(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 2, purged)
+		messages, err = s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", start)
+		event.Location = storage.Location{Address: "Room 1", ConferenceURL: "https://meet.example.com/1"}
+		require.NoError(t, s.CreateEvent(ctx, event))
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, event.Location, got.Location)
+
+		first := storage.Attachment{ID: "a", EventID: "1", Name: "agenda.txt", ContentType: "text/plain", Size: 5}
+		require.NoError(t, s.CreateAttachment(ctx, first))
+		require.ErrorIs(t, s.CreateAttachment(ctx, first), storage.ErrEventExists)
+		require.ErrorIs(t, s.CreateAttachment(ctx, storage.Attachment{ID: "b", EventID: "2"}), storage.ErrEventNotFound)
+		require.NoError(t, s.CreateAttachment(ctx, storage.Attachment{ID: "b", EventID: "1", Name: "notes.txt"}))
+
+		attachment, err := s.GetAttachment(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, "agenda.txt", attachment.Name)
+		require.False(t, attachment.CreatedAt.IsZero())
+		attachments, err := s.ListAttachments(ctx, []string{"1", "2"})
+		require.NoError(t, err)
+		require.Len(t, attachments, 2)
+
+		require.NoError(t, s.DeleteAttachment(ctx, "a"))
+		require.ErrorIs(t, s.DeleteAttachment(ctx, "a"), storage.ErrAttachmentNotFound)
+		_, err = s.GetAttachment(ctx, "a")
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+
+		// Attachments are deleted with their events.
+		require.NoError(t, s.DeleteEvent(ctx, "1"))
+		_, err = s.GetAttachment(ctx, "b")
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+	})
+
+	t.Run("categories", func(t *testing.T) {
+		s := New()
+
+		travel := storage.Category{ID: "travel", UserID: "user", Name: "Travel", Color: "#00aa00"}
+		require.NoError(t, s.CreateCategory(ctx, travel))
+		require.ErrorIs(t, s.CreateCategory(ctx, storage.Category{ID: "2", UserID: "user", Name: "travel"}),
+			storage.ErrCategoryExists)
+		require.NoError(t, s.CreateCategory(ctx, storage.Category{ID: "3", UserID: "other", Name: "travel"}))
+		require.NoError(t, s.CreateCategory(ctx, storage.Category{ID: "4", UserID: "user", Name: "1:1"}))
+
+		travel.Color = "#0000aa"
+		require.NoError(t, s.UpdateCategory(ctx, travel))
+		require.ErrorIs(t, s.UpdateCategory(ctx, storage.Category{ID: "4", Name: "TRAVEL"}), storage.ErrCategoryExists)
+		require.ErrorIs(t, s.UpdateCategory(ctx, storage.Category{ID: "none"}), storage.ErrCategoryNotFound)
+		categories, err := s.ListCategories(ctx, "user")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Category{{ID: "4", UserID: "user", Name: "1:1"}, travel}, categories)
+
+		event := newEvent("1", start)
+		event.CategoryID = "none"
+		require.ErrorIs(t, s.CreateEvent(ctx, event), storage.ErrCategoryNotFound)
+		event.CategoryID = "travel"
+		event.Tags = []string{"oncall"}
+		require.NoError(t, s.CreateEvent(ctx, event))
+
+		// Events of a deleted category are updated to have none.
+		require.NoError(t, s.DeleteCategory(ctx, "travel"))
+		require.ErrorIs(t, s.DeleteCategory(ctx, "travel"), storage.ErrCategoryNotFound)
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		require.Empty(t, got.CategoryID)
+		require.Equal(t, []string{"oncall"}, got.Tags)
+		require.Equal(t, event.Version+1, got.Version)
+	})
+
+	t.Run("usage report", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "room", Name: "Team Room"}, "user"))
+
+		personal := newEvent("1", start)
+		personal.Tags = []string{"Oncall"}
+		shared := newEvent("2", start.Add(30*time.Minute))
+		shared.CalendarID = "room"
+		shared.Tags = []string{"oncall"}
+		away := newEvent("3", start.Add(2*time.Hour))
+		away.Kind = storage.KindOutOfOffice
+		late := newEvent("4", start.Add(13*time.Hour+30*time.Minute))
+		for _, event := range []storage.Event{personal, shared, away, late} {
+			require.NoError(t, s.CreateEvent(ctx, event))
+		}
+
+		// The late event is split between the days, the shared one overlaps the personal one.
+		day := start.Truncate(24 * time.Hour)
+		first := storage.Period{Start: day, End: day.Add(24 * time.Hour)}
+		second := storage.Period{Start: day.Add(24 * time.Hour), End: day.Add(48 * time.Hour)}
+		usage, err := s.ReportUsage(ctx, storage.UsageQuery{
+			UserID: "user", CalendarIDs: []string{"room"}, Periods: []storage.Period{first, second},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []storage.Usage{
+			{Period: first, UserID: "user", Events: 3, Duration: 2 * time.Hour},
+			{Period: first, UserID: "user", Tag: "oncall", Events: 2, Duration: 90 * time.Minute},
+			{Period: second, UserID: "user", Events: 1, Duration: 30 * time.Minute},
+		}, usage)
+
+		usage, err = s.ReportUsage(ctx, storage.UsageQuery{CalendarIDs: []string{"room"}, Periods: []storage.Period{first}})
+		require.NoError(t, err)
+		require.Len(t, usage, 2)
+		require.Equal(t, time.Hour, usage[0].Duration)
+	})
+
+	t.Run("user data", func(t *testing.T) {
+		s := New()
+
+		// The user is the only owner of "mine", a co-owner of "co" and an editor of "shared".
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "mine", Name: "Mine"}, "user"))
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "co", Name: "Co"}, "user"))
+		require.NoError(t, s.SetMember(ctx, storage.Member{CalendarID: "co", UserID: "other", Role: storage.RoleOwner}))
+		require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{ID: "shared", Name: "Shared"}, "other"))
+		require.NoError(t, s.SetMember(ctx, storage.Member{CalendarID: "shared", UserID: "user", Role: storage.RoleEditor}))
+
+		newCalendarEvent := func(id, userID, calendarID string, hour int) storage.Event {
+			event := newEvent(id, start.Add(time.Duration(hour)*time.Hour))
+			event.UserID, event.CalendarID = userID, calendarID
+			return event
+		}
+		for _, event := range []storage.Event{
+			newEvent("personal", start),
+			newEvent("deleted", start.Add(time.Hour)),
+			newCalendarEvent("in mine", "other", "mine", 2),
+			newCalendarEvent("in shared", "user", "shared", 3),
+			newCalendarEvent("others", "other", "shared", 4),
+			newCalendarEvent("others personal", "other", "", 5),
+		} {
+			require.NoError(t, s.CreateEvent(ctx, event))
+		}
+		require.NoError(t, s.DeleteEvent(ctx, "deleted"))
+		for _, attachment := range []storage.Attachment{
+			{ID: "a1", EventID: "personal", UserID: "user"},
+			{ID: "a2", EventID: "in mine", UserID: "other"},
+			{ID: "a3", EventID: "others", UserID: "user"},
+		} {
+			require.NoError(t, s.CreateAttachment(ctx, attachment))
+		}
+		require.NoError(t, s.CreateCategory(ctx, storage.Category{ID: "travel", UserID: "user", Name: "Travel"}))
+		require.NoError(t, s.SetAvailability(ctx, storage.Availability{UserID: "user", TimeZone: "UTC"}))
+		for _, n := range []storage.Notification{
+			{EventID: "personal", Title: "event personal", UserID: "user"},
+			{EventID: "others personal", Title: "event others personal", UserID: "other"},
+		} {
+			body, err := json.Marshal(n)
+			require.NoError(t, err)
+			msg := storage.OutboxMessage{Topic: storage.TopicNotifications, Body: body}
+			require.NoError(t, s.EnqueueNotification(ctx, n.EventID, msg))
+		}
+
+		data, err := s.ExportUserData(ctx, "user")
+		require.NoError(t, err)
+		require.Len(t, data.Events, 2)
+		require.Equal(t, "in shared", data.Events[1].ID)
+		require.Len(t, data.Attachments, 2)
+		require.Len(t, data.Calendars, 3)
+		require.Len(t, data.Categories, 1)
+		require.NotNil(t, data.Availability)
+		require.Len(t, data.History, 3)
+		require.Equal(t, storage.ChangeDeleted, data.History[2].Kind)
+		require.Equal(t, []storage.Notification{{EventID: "personal", Title: "event personal", UserID: "user"}},
+			data.Notifications)
+
+		erased, err := s.DeleteUserData(ctx, storage.AuditRecord{
+			ActorID: "admin", Action: storage.AuditEraseUserData, UserID: "user",
+		})
+		require.NoError(t, err)
+		require.Len(t, erased.Attachments, 3)
+		require.Equal(t, map[string]int{
+			"events": 3, "calendars": 1, "memberships": 2, "categories": 1, "availability": 1,
+			"notifications": 1, "attachments": 3,
+		}, erased.Record.Counts)
+
+		data, err = s.ExportUserData(ctx, "user")
+		require.NoError(t, err)
+		require.Empty(t, data.Events)
+		require.Empty(t, data.Attachments)
+		require.Empty(t, data.Calendars)
+		require.Nil(t, data.Availability)
+		require.Empty(t, data.History)
+		require.Empty(t, data.Notifications)
+		_, err = s.GetEvent(ctx, "others")
+		require.NoError(t, err)
+		members, err := s.ListMembers(ctx, "co")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Member{{CalendarID: "co", UserID: "other", Role: storage.RoleOwner}}, members)
+
+		// Consumers of event changes are told about the deletions, without the user.
+		messages, err := s.ListOutbox(ctx, 100)
+		require.NoError(t, err)
+		deletions := 0
+		for _, msg := range messages {
+			require.NotContains(t, string(msg.Body), `"user"`)
+			var change storage.EventChanged
+			require.NoError(t, json.Unmarshal(msg.Body, &change))
+			if change.Kind == storage.ChangeDeleted && change.UserID == "" {
+				deletions++
+			}
+		}
+		// The event deleted before and both erased events of the user.
+		require.Equal(t, 3, deletions)
+
+		// Members of the shared calendar still sync the deletion, without the user.
+		changes, err := s.ListChanges(ctx, "", []string{"shared"}, 0, 0)
+		require.NoError(t, err)
+		require.Equal(t, storage.Change{
+			Seq: changes[len(changes)-1].Seq, Kind: storage.ChangeDeleted,
+			Event: storage.Event{ID: "in shared", CalendarID: "shared"},
+		}, changes[len(changes)-1])
+
+		records, err := s.ListAuditRecords(ctx, "user")
+		require.NoError(t, err)
+		require.Equal(t, []storage.AuditRecord{erased.Record}, records)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		s := New()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_ = s.CreateEvent(ctx, newEvent(strconv.Itoa(i), start.Add(time.Duration(i)*time.Hour)))
+				_, _ = s.ListEvents(ctx, "user", start, start.AddDate(0, 1, 0))
+			}(i)
+		}
+		wg.Wait()
+
+		events, err := s.ListEvents(ctx, "user", start, start.AddDate(0, 1, 0))
+		require.NoError(t, err)
+		require.Len(t, events, 100)
+	})
 }
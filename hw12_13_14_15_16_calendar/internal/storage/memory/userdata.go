@@ -0,0 +1,227 @@
+package memorystorage
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ExportUserData returns everything stored about the user.
+func (s *Storage) ExportUserData(ctx context.Context, userID string) (storage.UserData, error) {
+	data := storage.UserData{UserID: userID}
+	var err error
+	if data.Calendars, err = s.ListUserCalendars(ctx, userID); err != nil {
+		return storage.UserData{}, err
+	}
+	if data.Categories, err = s.ListCategories(ctx, userID); err != nil {
+		return storage.UserData{}, err
+	}
+	if availability, err := s.GetAvailability(ctx, userID); err == nil {
+		data.Availability = &availability
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data.Events = make([]storage.Event, 0)
+	for _, event := range s.events {
+		if event.UserID == userID {
+			data.Events = append(data.Events, event)
+		}
+	}
+	sort.Slice(data.Events, func(i, j int) bool {
+		return data.Events[i].StartAt.Before(data.Events[j].StartAt)
+	})
+	data.Attachments = make([]storage.Attachment, 0)
+	for _, attachment := range s.attachments {
+		if attachment.UserID == userID {
+			data.Attachments = append(data.Attachments, attachment)
+		}
+	}
+	sort.Slice(data.Attachments, func(i, j int) bool {
+		a, b := data.Attachments[i], data.Attachments[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	data.History = make([]storage.Change, 0)
+	for _, event := range data.Events {
+		seq := s.changes[event.ID]
+		kind := storage.ChangeUpdated
+		if seq.created == seq.changed {
+			kind = storage.ChangeCreated
+		}
+		data.History = append(data.History, storage.Change{Seq: seq.changed, Kind: kind, Event: storage.Event{
+			ID: event.ID, UserID: event.UserID, CalendarID: event.CalendarID,
+		}})
+	}
+	for _, t := range s.tombstones {
+		if t.event.UserID == userID {
+			data.History = append(data.History, storage.Change{Seq: t.seq, Kind: storage.ChangeDeleted, Event: t.event})
+		}
+	}
+	sort.Slice(data.History, func(i, j int) bool {
+		return data.History[i].Seq < data.History[j].Seq
+	})
+
+	data.Notifications = make([]storage.Notification, 0)
+	for _, record := range s.outbox {
+		if n, ok := notificationOf(record.message, userID); ok {
+			data.Notifications = append(data.Notifications, n)
+		}
+	}
+	return data, nil
+}
+
+// DeleteUserData deletes the user's events, calendars the user is the only owner of
+// with all their events, the user's memberships, attachments, categories, availability
+// settings, tombstones of personal events and notifications kept in the outbox.
+// Tombstones of calendar events are kept for members of the calendars with the user
+// removed from them. Deletions are announced to event change consumers as usual, the user
+// is removed from the changes in the outbox, those of the deletions included. Changes
+// already published to the queue or received by consumers keep the user.
+// The audit record is stored with the counts of deleted records.
+func (s *Storage) DeleteUserData(_ context.Context, record storage.AuditRecord) (storage.ErasedUserData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID := record.UserID
+	erased := storage.ErasedUserData{Attachments: make([]storage.Attachment, 0)}
+	counts := map[string]int{
+		"events": 0, "calendars": 0, "memberships": 0, "categories": 0, "availability": 0, "notifications": 0,
+	}
+
+	calendars := make(map[string]struct{})
+	for id, members := range s.members {
+		if members[userID] == storage.RoleOwner && !hasOtherOwner(members, userID) {
+			calendars[id] = struct{}{}
+		}
+	}
+	for _, attachment := range s.attachments {
+		event := s.events[attachment.EventID]
+		_, inCalendar := calendars[event.CalendarID]
+		if attachment.UserID == userID || event.UserID == userID || inCalendar {
+			erased.Attachments = append(erased.Attachments, attachment)
+			delete(s.attachments, attachment.ID)
+		}
+	}
+	for id, event := range s.events {
+		if _, ok := calendars[event.CalendarID]; ok || event.UserID == userID {
+			s.deleteEvent(id)
+			counts["events"]++
+		}
+	}
+	for id := range calendars {
+		delete(s.calendars, id)
+		delete(s.members, id)
+		counts["calendars"]++
+	}
+	for _, members := range s.members {
+		if _, ok := members[userID]; ok {
+			delete(members, userID)
+			counts["memberships"]++
+		}
+	}
+	for id, category := range s.categories {
+		if category.UserID == userID {
+			delete(s.categories, id)
+			counts["categories"]++
+		}
+	}
+	if _, ok := s.availability[userID]; ok {
+		delete(s.availability, userID)
+		counts["availability"]++
+	}
+	for key := range s.idempotencyKeys {
+		if key.userID == userID {
+			delete(s.idempotencyKeys, key)
+		}
+	}
+	for id, t := range s.tombstones {
+		switch {
+		case t.event.UserID != userID:
+		case t.event.CalendarID == "":
+			delete(s.tombstones, id)
+		default:
+			t.event.UserID = ""
+			s.tombstones[id] = t
+		}
+	}
+	s.outbox = slices.DeleteFunc(s.outbox, func(r outboxRecord) bool {
+		_, ok := notificationOf(r.message, userID)
+		if ok {
+			counts["notifications"]++
+		}
+		return ok
+	})
+	// Changes of the deleted events stay announced, without the user.
+	for i, r := range s.outbox {
+		var change storage.EventChanged
+		if r.message.Topic != storage.TopicEventChanges || json.Unmarshal(r.message.Body, &change) != nil ||
+			change.UserID != userID {
+			continue
+		}
+		change.UserID = ""
+		// The message always marshals.
+		s.outbox[i].message.Body, _ = json.Marshal(change)
+	}
+	counts["attachments"] = len(erased.Attachments)
+
+	record.Counts = counts
+	erased.Record = s.addAuditRecord(record)
+	return erased, nil
+}
+
+// AddAuditRecord stores the record and returns it with its ID and time set.
+func (s *Storage) AddAuditRecord(_ context.Context, record storage.AuditRecord) (storage.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addAuditRecord(record), nil
+}
+
+// ListAuditRecords returns the audit records about the user, all records if userID is empty,
+// oldest first.
+func (s *Storage) ListAuditRecords(_ context.Context, userID string) ([]storage.AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]storage.AuditRecord, 0)
+	for _, record := range s.audit {
+		if userID == "" || record.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *Storage) addAuditRecord(record storage.AuditRecord) storage.AuditRecord {
+	record.ID = int64(len(s.audit)) + 1
+	record.Time = time.Now()
+	s.audit = append(s.audit, record)
+	return record
+}
+
+func hasOtherOwner(members map[string]storage.Role, userID string) bool {
+	for id, role := range members {
+		if id != userID && role == storage.RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationOf decodes the message if it is a notification of the user.
+func notificationOf(msg storage.OutboxMessage, userID string) (storage.Notification, bool) {
+	var n storage.Notification
+	if msg.Topic != storage.TopicNotifications || json.Unmarshal(msg.Body, &n) != nil {
+		return storage.Notification{}, false
+	}
+	return n, n.UserID == userID
+}
@@ -0,0 +1,27 @@
+package storage
+
+// Topics of outbox messages.
+const (
+	TopicNotifications = "notifications"
+	TopicEventChanges  = "event_changes"
+)
+
+// OutboxMessage is written in the same transaction as the change it announces
+// and is published to the queue of its topic by the outbox relay.
+type OutboxMessage struct {
+	ID      int64
+	Topic   string
+	Body    []byte
+	Headers map[string]string
+}
+
+// EventChanged is the body of messages announcing changes of events.
+// Consumers load the event itself if they need more than its IDs.
+type EventChanged struct {
+	Kind       ChangeKind `json:"kind"`
+	EventID    string     `json:"eventId"`
+	UserID     string     `json:"userId"`
+	CalendarID string     `json:"calendarId,omitempty"`
+	// Version is zero for deleted events.
+	Version int64 `json:"version,omitempty"`
+}
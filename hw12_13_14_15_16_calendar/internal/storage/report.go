@@ -0,0 +1,32 @@
+package storage
+
+import "time"
+
+// Period is the time range [Start, End).
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// UsageQuery selects events of a time-usage report: personal events of UserID,
+// none if it is empty, and events of the calendars. Out-of-office periods are not counted.
+type UsageQuery struct {
+	UserID      string
+	CalendarIDs []string
+	// Periods are the consecutive ranges the usage is aggregated over.
+	Periods []Period
+}
+
+// Usage is the time taken by events of UserID in Period.
+type Usage struct {
+	Period Period
+	UserID string
+	// Tag is a lower-cased tag of the events, empty for all events of the user.
+	Tag string
+	// Events is the number of events intersecting the period. There are no recurring
+	// events, occurrences of a series (see app.QuickAdd) are separate events counted each.
+	Events int
+	// Duration is the length of the union of the events clipped to the period,
+	// overlapping events are counted once.
+	Duration time.Duration
+}
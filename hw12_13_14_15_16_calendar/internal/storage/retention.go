@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"slices"
+	"time"
+)
+
+// ExpiryFilter selects events that ended before Before.
+type ExpiryFilter struct {
+	Before time.Time
+	// CalendarIDs limits the selection to events of the calendars.
+	CalendarIDs []string
+	// ExceptCalendarIDs excludes events of the calendars, personal events are still selected.
+	ExceptCalendarIDs []string
+}
+
+// Match reports whether the event is selected by the filter.
+func (f ExpiryFilter) Match(event Event) bool {
+	if !event.EndAt.Before(f.Before) {
+		return false
+	}
+	if f.CalendarIDs != nil && !slices.Contains(f.CalendarIDs, event.CalendarID) {
+		return false
+	}
+	return event.CalendarID == "" || !slices.Contains(f.ExceptCalendarIDs, event.CalendarID)
+}
@@ -0,0 +1,120 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type attachmentRow struct {
+	ID          string    `db:"id"`
+	EventID     string    `db:"event_id"`
+	Name        string    `db:"name"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	UserID      string    `db:"user_id"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+const attachmentColumns = `id, event_id, name, content_type, size, user_id, created_at`
+
+func (s *Storage) CreateAttachment(ctx context.Context, attachment storage.Attachment) (err error) {
+	ctx, span := startSpan(ctx, "CreateAttachment")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO attachments (id, event_id, name, content_type, size, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		attachment.ID, attachment.EventID, attachment.Name, attachment.ContentType, attachment.Size, attachment.UserID)
+	// Attachments reference only their events.
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return storage.ErrEventNotFound
+	}
+	return mapError(err)
+}
+
+func (s *Storage) GetAttachment(ctx context.Context, id string) (_ storage.Attachment, err error) {
+	ctx, span := startSpan(ctx, "GetAttachment")
+	defer func() { endSpan(span, err) }()
+
+	var row attachmentRow
+	err = s.db.GetContext(ctx, &row, `SELECT `+attachmentColumns+` FROM attachments WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return storage.Attachment{}, mapErrorWith(err, storage.ErrAttachmentNotFound)
+	}
+	return row.toAttachment(), nil
+}
+
+// MissingAttachments returns those of the given attachment IDs that have no attachment.
+func (s *Storage) MissingAttachments(ctx context.Context, ids []string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "MissingAttachments")
+	defer func() { endSpan(span, err) }()
+
+	missing := make([]string, 0)
+	err = s.db.SelectContext(ctx, &missing, `
+		SELECT k.id::text FROM unnest(COALESCE($1::uuid[], '{}')) AS k (id)
+		WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.id = k.id)`, ids)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return missing, nil
+}
+
+// ListAttachments returns attachments of the events in the order they were attached.
+func (s *Storage) ListAttachments(ctx context.Context, eventIDs []string) (_ []storage.Attachment, err error) {
+	ctx, span := startSpan(ctx, "ListAttachments")
+	defer func() { endSpan(span, err) }()
+
+	var rows []attachmentRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE event_id = ANY(COALESCE($1::uuid[], '{}'))
+		ORDER BY created_at, id`, eventIDs)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	attachments := make([]storage.Attachment, 0, len(rows))
+	for _, row := range rows {
+		attachments = append(attachments, row.toAttachment())
+	}
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteAttachment")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1`, id)
+	if err != nil {
+		return mapErrorWith(err, storage.ErrAttachmentNotFound)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrAttachmentNotFound
+	}
+	return nil
+}
+
+func (r attachmentRow) toAttachment() storage.Attachment {
+	return storage.Attachment{
+		ID:          r.ID,
+		EventID:     r.EventID,
+		Name:        r.Name,
+		ContentType: r.ContentType,
+		Size:        r.Size,
+		UserID:      r.UserID,
+		CreatedAt:   r.CreatedAt,
+	}
+}
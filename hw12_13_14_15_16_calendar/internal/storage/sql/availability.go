@@ -0,0 +1,97 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// workingHoursRow is the stored form of working hours, times are in minutes from midnight.
+type workingHoursRow struct {
+	Weekday int `json:"weekday"`
+	Start   int `json:"start"`
+	End     int `json:"end"`
+}
+
+// ListOverlappingEvents returns user's personal events taking place in [from, to) ordered by start time.
+func (s *Storage) ListOverlappingEvents(
+	ctx context.Context, userID string, from, to time.Time,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListOverlappingEvents")
+	defer func() { endSpan(span, err) }()
+
+	var rows []eventRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE user_id = $1 AND calendar_id IS NULL AND start_at < $3 AND end_at > $2
+		ORDER BY start_at`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+func (s *Storage) GetAvailability(ctx context.Context, userID string) (_ storage.Availability, err error) {
+	ctx, span := startSpan(ctx, "GetAvailability")
+	defer func() { endSpan(span, err) }()
+
+	var row struct {
+		TimeZone     string `db:"time_zone"`
+		WorkingHours []byte `db:"working_hours"`
+	}
+	err = s.db.GetContext(ctx, &row,
+		`SELECT time_zone, working_hours FROM user_availability WHERE user_id = $1`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Availability{}, storage.ErrAvailabilityNotFound
+	}
+	if err != nil {
+		return storage.Availability{}, err
+	}
+	var hours []workingHoursRow
+	if err := json.Unmarshal(row.WorkingHours, &hours); err != nil {
+		return storage.Availability{}, err
+	}
+	availability := storage.Availability{
+		UserID:       userID,
+		TimeZone:     row.TimeZone,
+		WorkingHours: make([]storage.WorkingHours, 0, len(hours)),
+	}
+	for _, h := range hours {
+		availability.WorkingHours = append(availability.WorkingHours, storage.WorkingHours{
+			Weekday: time.Weekday(h.Weekday),
+			Start:   time.Duration(h.Start) * time.Minute,
+			End:     time.Duration(h.End) * time.Minute,
+		})
+	}
+	return availability, nil
+}
+
+// SetAvailability replaces the settings of availability.UserID.
+func (s *Storage) SetAvailability(ctx context.Context, availability storage.Availability) (err error) {
+	ctx, span := startSpan(ctx, "SetAvailability")
+	defer func() { endSpan(span, err) }()
+
+	hours := make([]workingHoursRow, 0, len(availability.WorkingHours))
+	for _, h := range availability.WorkingHours {
+		hours = append(hours, workingHoursRow{
+			Weekday: int(h.Weekday),
+			Start:   int(h.Start / time.Minute),
+			End:     int(h.End / time.Minute),
+		})
+	}
+	encoded, err := json.Marshal(hours)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO user_availability (user_id, time_zone, working_hours)
+		VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (user_id) DO UPDATE SET time_zone = EXCLUDED.time_zone, working_hours = EXCLUDED.working_hours`,
+		availability.UserID, availability.TimeZone, encoded)
+	return err
+}
@@ -0,0 +1,89 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// ApplyBatch applies the operations in order in one transaction, which is committed only
+// if all of them succeed. It returns the error of every operation, nil for those that
+// succeed; if any operation fails, nothing is changed. Operations see the effects of the
+// preceding ones, so events created or moved by the batch must not overlap each other either.
+func (s *Storage) ApplyBatch(ctx context.Context, ops []storage.BatchOp) (_ []error, err error) {
+	ctx, span := startSpan(ctx, "ApplyBatch")
+	defer func() { endSpan(span, err) }()
+
+	return s.runBatch(ctx, ops, true)
+}
+
+// CheckBatch returns the errors ApplyBatch would return without changing anything.
+func (s *Storage) CheckBatch(ctx context.Context, ops []storage.BatchOp) (_ []error, err error) {
+	ctx, span := startSpan(ctx, "CheckBatch")
+	defer func() { endSpan(span, err) }()
+
+	return s.runBatch(ctx, ops, false)
+}
+
+// runBatch applies the operations and commits them if all succeed and commit is set.
+func (s *Storage) runBatch(ctx context.Context, ops []storage.BatchOp, commit bool) ([]error, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	errs := make([]error, len(ops))
+	failed := false
+	for i, op := range ops {
+		// A failed statement aborts the transaction, the savepoint lets the batch
+		// go on to report errors of the following operations.
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_op`); err != nil {
+			return nil, err
+		}
+		err := applyOp(ctx, tx, op)
+		switch {
+		case err == nil:
+		case isOpError(err):
+			errs[i] = err
+			failed = true
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_op`); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_op`); err != nil {
+			return nil, err
+		}
+	}
+	if failed || !commit {
+		return errs, nil
+	}
+	return errs, tx.Commit()
+}
+
+func applyOp(ctx context.Context, tx *sqlx.Tx, op storage.BatchOp) error {
+	switch op.Kind {
+	case storage.ChangeCreated:
+		return createEvent(ctx, tx, op.Event)
+	case storage.ChangeUpdated:
+		return updateEvent(ctx, tx, op.Event)
+	case storage.ChangeDeleted:
+		return deleteEvent(ctx, tx, op.Event.ID)
+	}
+	return fmt.Errorf("unknown batch operation %q", op.Kind)
+}
+
+// isOpError reports whether the error is caused by the operation rather than by the database.
+func isOpError(err error) bool {
+	return errors.Is(err, storage.ErrEventExists) ||
+		errors.Is(err, storage.ErrEventNotFound) ||
+		errors.Is(err, storage.ErrCalendarNotFound) ||
+		errors.Is(err, storage.ErrDateBusy) ||
+		errors.Is(err, storage.ErrOutOfOffice) ||
+		errors.Is(err, storage.ErrVersionConflict)
+}
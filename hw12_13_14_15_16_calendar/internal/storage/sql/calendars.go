@@ -0,0 +1,162 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// CreateCalendar creates the calendar with ownerID as its owner.
+func (s *Storage) CreateCalendar(ctx context.Context, calendar storage.Calendar, ownerID string) (err error) {
+	ctx, span := startSpan(ctx, "CreateCalendar")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO calendars (id, name) VALUES ($1, $2)`, calendar.ID, calendar.Name,
+	); err != nil {
+		if errors.Is(mapError(err), storage.ErrEventExists) {
+			return storage.ErrCalendarExists
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO calendar_members (calendar_id, user_id, role) VALUES ($1, $2, $3)`,
+		calendar.ID, ownerID, storage.RoleOwner,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, id string) (_ storage.Calendar, err error) {
+	ctx, span := startSpan(ctx, "GetCalendar")
+	defer func() { endSpan(span, err) }()
+
+	var calendar storage.Calendar
+	err = s.db.QueryRowxContext(ctx, `SELECT id, name FROM calendars WHERE id = $1`, id).
+		Scan(&calendar.ID, &calendar.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Calendar{}, storage.ErrCalendarNotFound
+	}
+	if err != nil {
+		return storage.Calendar{}, mapErrorWith(err, storage.ErrCalendarNotFound)
+	}
+	return calendar, nil
+}
+
+// DeleteCalendar deletes the calendar, its events and members are deleted by cascade.
+func (s *Storage) DeleteCalendar(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCalendar")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM calendars WHERE id = $1`, id)
+	if err != nil {
+		return mapErrorWith(err, storage.ErrCalendarNotFound)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrCalendarNotFound
+	}
+	return nil
+}
+
+// SetMember adds the user to the calendar or changes the user's role.
+func (s *Storage) SetMember(ctx context.Context, member storage.Member) (err error) {
+	ctx, span := startSpan(ctx, "SetMember")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO calendar_members (calendar_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (calendar_id, user_id) DO UPDATE SET role = excluded.role`,
+		member.CalendarID, member.UserID, member.Role)
+	return mapErrorWith(err, storage.ErrCalendarNotFound)
+}
+
+func (s *Storage) RemoveMember(ctx context.Context, calendarID, userID string) (err error) {
+	ctx, span := startSpan(ctx, "RemoveMember")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM calendar_members WHERE calendar_id = $1 AND user_id = $2`, calendarID, userID)
+	if err != nil {
+		return mapErrorWith(err, storage.ErrMemberNotFound)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetMember(ctx context.Context, calendarID, userID string) (_ storage.Member, err error) {
+	ctx, span := startSpan(ctx, "GetMember")
+	defer func() { endSpan(span, err) }()
+
+	member := storage.Member{CalendarID: calendarID, UserID: userID}
+	err = s.db.QueryRowxContext(ctx,
+		`SELECT role FROM calendar_members WHERE calendar_id = $1 AND user_id = $2`, calendarID, userID,
+	).Scan(&member.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Member{}, storage.ErrMemberNotFound
+	}
+	if err != nil {
+		return storage.Member{}, mapErrorWith(err, storage.ErrMemberNotFound)
+	}
+	return member, nil
+}
+
+// ListMembers returns members of the calendar ordered by user ID.
+func (s *Storage) ListMembers(ctx context.Context, calendarID string) (_ []storage.Member, err error) {
+	ctx, span := startSpan(ctx, "ListMembers")
+	defer func() { endSpan(span, err) }()
+
+	if _, err := s.GetCalendar(ctx, calendarID); err != nil {
+		return nil, err
+	}
+	var members []storage.Member
+	err = s.db.SelectContext(ctx, &members, `
+		SELECT calendar_id AS calendarid, user_id AS userid, role
+		FROM calendar_members WHERE calendar_id = $1
+		ORDER BY user_id`, calendarID)
+	return members, err
+}
+
+// ListUserCalendars returns calendars the user is a member of ordered by name.
+func (s *Storage) ListUserCalendars(ctx context.Context, userID string) (_ []storage.UserCalendar, err error) {
+	ctx, span := startSpan(ctx, "ListUserCalendars")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.QueryxContext(ctx, `
+		SELECT c.id, c.name, m.role
+		FROM calendar_members m JOIN calendars c ON c.id = m.calendar_id
+		WHERE m.user_id = $1
+		ORDER BY c.name, c.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := make([]storage.UserCalendar, 0)
+	for rows.Next() {
+		var c storage.UserCalendar
+		if err := rows.Scan(&c.ID, &c.Name, &c.Role); err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, c)
+	}
+	return calendars, rows.Err()
+}
@@ -0,0 +1,109 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const categoryColumns = `id, user_id AS userid, name, color`
+
+// CreateCategory creates the category unless its user has one of the same name regardless of case.
+func (s *Storage) CreateCategory(ctx context.Context, category storage.Category) (err error) {
+	ctx, span := startSpan(ctx, "CreateCategory")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO categories (id, user_id, name, color) VALUES ($1, $2, $3, $4)`,
+		category.ID, category.UserID, category.Name, category.Color)
+	return mapCategoryError(err)
+}
+
+func (s *Storage) GetCategory(ctx context.Context, id string) (_ storage.Category, err error) {
+	ctx, span := startSpan(ctx, "GetCategory")
+	defer func() { endSpan(span, err) }()
+
+	var category storage.Category
+	err = s.db.GetContext(ctx, &category, `SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Category{}, storage.ErrCategoryNotFound
+	}
+	if err != nil {
+		return storage.Category{}, mapCategoryError(err)
+	}
+	return category, nil
+}
+
+// UpdateCategory renames and recolors the category, its user cannot change.
+func (s *Storage) UpdateCategory(ctx context.Context, category storage.Category) (err error) {
+	ctx, span := startSpan(ctx, "UpdateCategory")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE categories SET name = $2, color = $3 WHERE id = $1`, category.ID, category.Name, category.Color)
+	if err != nil {
+		return mapCategoryError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrCategoryNotFound
+	}
+	return nil
+}
+
+// DeleteCategory deletes the category, its events are updated to have none.
+func (s *Storage) DeleteCategory(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCategory")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// The version bump makes the change visible to syncing clients.
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE events SET category_id = NULL, version = version + 1 WHERE category_id = $1`, id,
+	); err != nil {
+		return mapCategoryError(err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return mapCategoryError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrCategoryNotFound
+	}
+	return tx.Commit()
+}
+
+// ListCategories returns categories of the user ordered by name.
+func (s *Storage) ListCategories(ctx context.Context, userID string) (_ []storage.Category, err error) {
+	ctx, span := startSpan(ctx, "ListCategories")
+	defer func() { endSpan(span, err) }()
+
+	categories := make([]storage.Category, 0)
+	err = s.db.SelectContext(ctx, &categories, `
+		SELECT `+categoryColumns+`
+		FROM categories WHERE user_id = $1
+		ORDER BY name, id`, userID)
+	return categories, err
+}
+
+func mapCategoryError(err error) error {
+	err = mapErrorWith(err, storage.ErrCategoryNotFound)
+	if errors.Is(err, storage.ErrEventExists) {
+		return storage.ErrCategoryExists
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type changeRow struct {
+	eventRow
+	Seq     int64 `db:"seq"`
+	Created bool  `db:"created"`
+}
+
+// ListChanges returns the latest changes after since of the user's personal events,
+// if userID is not empty, and of events of the calendars, ordered by sequence.
+// At most limit changes are returned, zero means no limit.
+// Changes of deleted events are kept until their tombstones are purged.
+func (s *Storage) ListChanges(
+	ctx context.Context, userID string, calendarIDs []string, since int64, limit int,
+) (_ []storage.Change, err error) {
+	ctx, span := startSpan(ctx, "ListChanges")
+	defer func() { endSpan(span, err) }()
+
+	// Events and tombstones are read from the same snapshot.
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var expired bool
+	if err := tx.GetContext(ctx, &expired, `
+		SELECT $1 > (SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM event_change_seq)
+			OR $1 > 0 AND $1 < (SELECT purged_seq FROM event_tombstone_purges)`, since,
+	); err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, storage.ErrSyncTokenExpired
+	}
+
+	var rows []changeRow
+	if err := tx.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`, changed_seq AS seq, created_seq > $3 AS created
+		FROM events
+		WHERE changed_seq > $3 AND (calendar_id IS NULL AND user_id = $1 OR calendar_id = ANY($2::uuid[]))
+		ORDER BY changed_seq
+		LIMIT NULLIF($4, 0)`, userID, calendarIDs, since, limit,
+	); err != nil {
+		return nil, mapErrorWith(err, storage.ErrCalendarNotFound)
+	}
+	var tombstones []changeRow
+	if err := tx.SelectContext(ctx, &tombstones, `
+		SELECT id, user_id, coalesce(calendar_id::text, '') AS calendar_id, seq
+		FROM event_tombstones
+		WHERE seq > $3 AND (calendar_id IS NULL AND user_id = $1 OR calendar_id = ANY($2::uuid[]))
+		ORDER BY seq
+		LIMIT NULLIF($4, 0)`, userID, calendarIDs, since, limit,
+	); err != nil {
+		return nil, mapErrorWith(err, storage.ErrCalendarNotFound)
+	}
+
+	changes := make([]storage.Change, 0, len(rows)+len(tombstones))
+	for _, row := range rows {
+		kind := storage.ChangeUpdated
+		if row.Created {
+			kind = storage.ChangeCreated
+		}
+		changes = append(changes, storage.Change{Seq: row.Seq, Kind: kind, Event: row.toEvent()})
+	}
+	for _, row := range tombstones {
+		changes = append(changes, storage.Change{Seq: row.Seq, Kind: storage.ChangeDeleted, Event: storage.Event{
+			ID: row.ID, UserID: row.UserID, CalendarID: row.CalendarID,
+		}})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Seq < changes[j].Seq
+	})
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+// PurgeTombstones forgets events deleted before the given time and returns
+// the number of purged tombstones. Syncing from earlier changes is no longer possible.
+func (s *Storage) PurgeTombstones(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PurgeTombstones")
+	defer func() { endSpan(span, err) }()
+
+	var count int
+	err = s.db.GetContext(ctx, &count, `
+		WITH purged AS (
+			DELETE FROM event_tombstones WHERE deleted_at < $1 RETURNING seq
+		), horizon AS (
+			UPDATE event_tombstone_purges SET purged_seq = greatest(purged_seq, (SELECT max(seq) FROM purged))
+			WHERE EXISTS (SELECT 1 FROM purged)
+		)
+		SELECT count(*) FROM purged`, before)
+	return count, err
+}
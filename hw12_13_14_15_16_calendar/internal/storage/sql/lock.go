@@ -0,0 +1,64 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// AdvisoryLock is a session-level advisory lock shared by replicas connected to the
+// same database. It is held on a dedicated connection and is released by PostgreSQL
+// as soon as the session of its holder ends.
+type AdvisoryLock struct {
+	db   *sql.DB
+	name string
+	conn *sql.Conn
+}
+
+// AdvisoryLock creates a lock with the given name on the connected storage.
+func (s *Storage) AdvisoryLock(name string) *AdvisoryLock {
+	return &AdvisoryLock{db: s.db.DB, name: name}
+}
+
+func (l *AdvisoryLock) TryLock(ctx context.Context) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "TryLock")
+	defer func() { endSpan(span, err) }()
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, l.name).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Check fails once the session holding the lock is gone.
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return errors.New("lock is not taken")
+	}
+	return l.conn.PingContext(ctx)
+}
+
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+	defer conn.Close()
+
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, l.name)
+	if err != nil {
+		// The session may still hold the lock, it must not go back to the pool.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	return err
+}
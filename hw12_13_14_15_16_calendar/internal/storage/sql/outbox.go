@@ -0,0 +1,100 @@
+package sqlstorage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type outboxRow struct {
+	ID      int64  `db:"id"`
+	Topic   string `db:"topic"`
+	Body    []byte `db:"body"`
+	Headers []byte `db:"headers"`
+}
+
+// EnqueueNotification marks the event as notified and adds the notification to the outbox.
+func (s *Storage) EnqueueNotification(
+	ctx context.Context, eventID string, msg storage.OutboxMessage,
+) (err error) {
+	ctx, span := startSpan(ctx, "EnqueueNotification")
+	defer func() { endSpan(span, err) }()
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.ExecContext(ctx, `UPDATE events SET notified_at = now() WHERE id = $1`, eventID)
+	if err != nil {
+		return mapError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrEventNotFound
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (topic, body, headers) VALUES ($1, $2::jsonb, $3::jsonb)`,
+		msg.Topic, string(msg.Body), string(headers),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListOutbox returns at most limit messages that have not been sent yet, oldest first.
+func (s *Storage) ListOutbox(ctx context.Context, limit int) (_ []storage.OutboxMessage, err error) {
+	ctx, span := startSpan(ctx, "ListOutbox")
+	defer func() { endSpan(span, err) }()
+
+	var rows []outboxRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT id, topic, body, headers
+		FROM outbox
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]storage.OutboxMessage, 0, len(rows))
+	for _, row := range rows {
+		msg := storage.OutboxMessage{ID: row.ID, Topic: row.Topic, Body: row.Body}
+		if err := json.Unmarshal(row.Headers, &msg.Headers); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (s *Storage) MarkSent(ctx context.Context, ids []int64) (err error) {
+	ctx, span := startSpan(ctx, "MarkSent")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `UPDATE outbox SET sent_at = now() WHERE id = ANY($1) AND sent_at IS NULL`, ids)
+	return err
+}
+
+// PurgeOutbox deletes messages sent before the given time and returns their number.
+func (s *Storage) PurgeOutbox(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PurgeOutbox")
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,77 @@
+package sqlstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type usageRow struct {
+	PeriodStart time.Time `db:"period_start"`
+	UserID      string    `db:"user_id"`
+	Tag         string    `db:"tag"`
+	Events      int       `db:"events"`
+	// Micros is the duration in microseconds, the precision of timestamptz.
+	Micros int64 `db:"micros"`
+}
+
+// ReportUsage aggregates the events selected by the query in the database, see storage.Usage.
+// Events are clipped to the periods and merged with range_agg, so overlaps are counted once.
+// Rows are ordered by period, user and tag.
+func (s *Storage) ReportUsage(ctx context.Context, query storage.UsageQuery) (_ []storage.Usage, err error) {
+	ctx, span := startSpan(ctx, "ReportUsage")
+	defer func() { endSpan(span, err) }()
+
+	starts := make([]time.Time, 0, len(query.Periods))
+	ends := make([]time.Time, 0, len(query.Periods))
+	// The database returns timestamps in the session time zone,
+	// rows get the periods of the query to keep their location.
+	periods := make(map[int64]storage.Period, len(query.Periods))
+	for _, period := range query.Periods {
+		starts = append(starts, period.Start)
+		ends = append(ends, period.End)
+		periods[period.Start.UnixMicro()] = period
+	}
+
+	var rows []usageRow
+	err = s.db.SelectContext(ctx, &rows, `
+		WITH periods AS (
+			SELECT * FROM unnest($3::timestamptz[], $4::timestamptz[]) AS p (start_at, end_at)
+		), spans AS (
+			SELECT p.start_at AS period_start, e.id, e.user_id, e.tags,
+				tstzrange(p.start_at, p.end_at) * tstzrange(e.start_at, e.end_at) AS span
+			FROM events e
+			JOIN periods p ON e.start_at < p.end_at AND e.end_at > p.start_at
+			WHERE e.kind <> 'out_of_office'
+				AND (e.calendar_id IS NULL AND e.user_id = $1 OR e.calendar_id = ANY($2::uuid[]))
+		), tagged AS (
+			SELECT period_start, user_id, '' AS tag, span FROM spans
+			UNION ALL
+			SELECT period_start, user_id, lower(tag), span FROM spans, unnest(tags) AS tag
+		), merged AS (
+			SELECT period_start, user_id, tag, count(*) AS events, range_agg(span) AS spans
+			FROM tagged
+			GROUP BY period_start, user_id, tag
+		)
+		SELECT period_start, user_id, tag, events,
+			(SELECT coalesce(sum(extract(epoch FROM upper(r) - lower(r)) * 1000000), 0)
+				FROM unnest(spans) AS r)::bigint AS micros
+		FROM merged
+		ORDER BY period_start, user_id, tag`, query.UserID, query.CalendarIDs, starts, ends)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]storage.Usage, 0, len(rows))
+	for _, row := range rows {
+		usage = append(usage, storage.Usage{
+			Period:   periods[row.PeriodStart.UnixMicro()],
+			UserID:   row.UserID,
+			Tag:      row.Tag,
+			Events:   row.Events,
+			Duration: time.Duration(row.Micros) * time.Microsecond,
+		})
+	}
+	return usage, nil
+}
@@ -1,20 +1,497 @@
 package sqlstorage
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
-type Storage struct { // TODO
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx database/sql driver
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgExclusionViolation        = "23P01"
+	pgInvalidTextRepresentation = "22P02"
+	// pgOutOfOffice is raised by the trigger checking out-of-office periods, see migration 00012.
+	pgOutOfOffice = "CA001"
+)
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql")
+
+// Tags are read and written as JSON, see stringList.
+const eventColumns = `id, title, start_at, end_at, description, user_id,
+	coalesce(calendar_id::text, '') AS calendar_id, kind, location, conference_url, notify_before, version,
+	coalesce(category_id::text, '') AS category_id, to_jsonb(tags) AS tags`
+
+// categoryFKey is the constraint of events referencing their categories.
+const categoryFKey = "events_category_id_fkey"
+
+type Storage struct {
+	dsn string
+	db  *sqlx.DB
+}
+
+type eventRow struct {
+	ID            string     `db:"id"`
+	Title         string     `db:"title"`
+	StartAt       time.Time  `db:"start_at"`
+	EndAt         time.Time  `db:"end_at"`
+	Description   string     `db:"description"`
+	UserID        string     `db:"user_id"`
+	CalendarID    string     `db:"calendar_id"`
+	Kind          string     `db:"kind"`
+	Location      string     `db:"location"`
+	ConferenceURL string     `db:"conference_url"`
+	NotifyBefore  int64      `db:"notify_before"`
+	Version       int64      `db:"version"`
+	CategoryID    string     `db:"category_id"`
+	Tags          stringList `db:"tags"`
+}
+
+// stringList is a text[] column passed as a JSON array of strings.
+type stringList []string
+
+// Scan reads an empty list as nil.
+func (l *stringList) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into a list of strings", src)
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		list = nil
+	}
+	*l = list
+	return nil
 }
 
-func New() *Storage {
-	return &Storage{}
+func (l stringList) Value() (driver.Value, error) {
+	if l == nil {
+		l = stringList{}
+	}
+	return json.Marshal([]string(l))
+}
+
+func New(dsn string) *Storage {
+	return &Storage{dsn: dsn}
 }
 
 func (s *Storage) Connect(ctx context.Context) error {
-	// TODO
+	db, err := sqlx.ConnectContext(ctx, "pgx", s.dsn)
+	if err != nil {
+		return err
+	}
+	s.db = db
 	return nil
 }
 
-func (s *Storage) Close(ctx context.Context) error {
-	// TODO
+func (s *Storage) Close(_ context.Context) error {
+	return s.db.Close()
+}
+
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "CreateEvent")
+	defer func() { endSpan(span, err) }()
+
+	return createEvent(ctx, s.db, event)
+}
+
+// CreateEventWithKey creates the event unless the user already created one with the same
+// idempotency key after notBefore, in which case the earlier event is returned.
+func (s *Storage) CreateEventWithKey(
+	ctx context.Context, event storage.Event, key string, notBefore time.Time,
+) (_ storage.Event, err error) {
+	ctx, span := startSpan(ctx, "CreateEventWithKey")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// Concurrent retries with the same key wait for each other here.
+	if _, err := tx.ExecContext(ctx,
+		`SELECT pg_advisory_xact_lock(hashtextextended($1::text || ':' || $2::text, 0))`, event.UserID, key,
+	); err != nil {
+		return storage.Event{}, err
+	}
+
+	existing, err := getEventByKey(ctx, tx, event.UserID, key, notBefore)
+	switch {
+	case err == nil:
+		return existing, nil
+	case !errors.Is(err, storage.ErrEventNotFound):
+		return storage.Event{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, event.UserID, key,
+	); err != nil {
+		return storage.Event{}, err
+	}
+	if err := createEvent(ctx, tx, event); err != nil {
+		return storage.Event{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (user_id, key, event_id) VALUES ($1, $2, $3)`, event.UserID, key, event.ID,
+	); err != nil {
+		return storage.Event{}, err
+	}
+	return event, tx.Commit()
+}
+
+// GetEventByKey returns the event the user created with the idempotency key after notBefore,
+// storage.ErrEventNotFound if there is none.
+func (s *Storage) GetEventByKey(
+	ctx context.Context, userID, key string, notBefore time.Time,
+) (_ storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventByKey")
+	defer func() { endSpan(span, err) }()
+
+	return getEventByKey(ctx, s.db, userID, key, notBefore)
+}
+
+// PurgeIdempotencyKeys forgets idempotency keys used before the given time and returns
+// the number of purged keys.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PurgeIdempotencyKeys")
+	defer func() { endSpan(span, err) }()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// UpdateEvent replaces the event if its stored version equals event.Version
+// and increments the stored version.
+func (s *Storage) UpdateEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "UpdateEvent")
+	defer func() { endSpan(span, err) }()
+
+	return updateEvent(ctx, s.db, event)
+}
+
+func (s *Storage) DeleteEvent(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteEvent")
+	defer func() { endSpan(span, err) }()
+
+	return deleteEvent(ctx, s.db, id)
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (_ storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEvent")
+	defer func() { endSpan(span, err) }()
+
+	return getEvent(ctx, s.db, id)
+}
+
+// ListEvents returns user's personal events starting in [from, to) ordered by start time.
+func (s *Storage) ListEvents(ctx context.Context, userID string, from, to time.Time) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListEvents")
+	defer func() { endSpan(span, err) }()
+
+	var rows []eventRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE user_id = $1 AND calendar_id IS NULL AND start_at >= $2 AND start_at < $3
+		ORDER BY start_at`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+// ListCalendarEvents returns events of the calendars starting in [from, to) ordered by start time.
+func (s *Storage) ListCalendarEvents(
+	ctx context.Context, calendarIDs []string, from, to time.Time,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListCalendarEvents")
+	defer func() { endSpan(span, err) }()
+
+	var rows []eventRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE calendar_id = ANY($1::uuid[]) AND start_at >= $2 AND start_at < $3
+		ORDER BY start_at`, calendarIDs, from, to)
+	if err != nil {
+		return nil, mapErrorWith(err, storage.ErrCalendarNotFound)
+	}
+	return toEvents(rows), nil
+}
+
+func (s *Storage) CountEvents(ctx context.Context, userID string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "CountEvents")
+	defer func() { endSpan(span, err) }()
+
+	var count int
+	err = s.db.GetContext(ctx, &count, `SELECT count(*) FROM events WHERE user_id = $1`, userID)
+	return count, err
+}
+
+// ListEventsToNotify returns events whose notification time has come by now
+// and that have not been marked as notified yet.
+func (s *Storage) ListEventsToNotify(ctx context.Context, now time.Time) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListEventsToNotify")
+	defer func() { endSpan(span, err) }()
+
+	var rows []eventRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE notified_at IS NULL AND notify_before > 0
+			AND start_at - make_interval(secs => notify_before / 1e9) <= $1 AND start_at > $1
+		ORDER BY start_at`, now)
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+func (s *Storage) MarkNotified(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "MarkNotified")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `UPDATE events SET notified_at = now() WHERE id = $1`, id)
+	return mapError(err)
+}
+
+// ListExpiredEvents returns at most limit events selected by the filter, those that ended first go first.
+func (s *Storage) ListExpiredEvents(
+	ctx context.Context, filter storage.ExpiryFilter, limit int,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListExpiredEvents")
+	defer func() { endSpan(span, err) }()
+
+	var rows []eventRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE end_at < $1
+			AND ($2::uuid[] IS NULL OR calendar_id = ANY($2::uuid[]))
+			AND (calendar_id IS NULL OR NOT calendar_id = ANY(COALESCE($3::uuid[], '{}')))
+		ORDER BY end_at
+		LIMIT $4`, filter.Before, filter.CalendarIDs, filter.ExceptCalendarIDs, limit)
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+// DeleteEvents deletes the events unless they have been modified since they were read
+// and returns the number of deleted events.
+func (s *Storage) DeleteEvents(ctx context.Context, events []storage.Event) (_ int, err error) {
+	ctx, span := startSpan(ctx, "DeleteEvents")
+	defer func() { endSpan(span, err) }()
+
+	ids := make([]string, 0, len(events))
+	versions := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+		versions = append(versions, event.Version)
+	}
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM events e
+		USING unnest($1::uuid[], $2::bigint[]) AS d (id, version)
+		WHERE e.id = d.id AND e.version = d.version`, ids, versions)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func createEvent(ctx context.Context, db sqlx.ExtContext, event storage.Event) error {
+	_, err := sqlx.NamedExecContext(ctx, db, `
+		INSERT INTO events (id, title, start_at, end_at, description, user_id, calendar_id,
+			kind, location, conference_url, notify_before, version, category_id, tags)
+		VALUES (:id, :title, :start_at, :end_at, :description, :user_id, NULLIF(:calendar_id, '')::uuid,
+			:kind, :location, :conference_url, :notify_before, :version, NULLIF(:category_id, '')::uuid,
+			ARRAY(SELECT jsonb_array_elements_text(:tags::jsonb)))`,
+		newEventRow(event))
+	return mapErrorWith(err, storage.ErrCalendarNotFound)
+}
+
+func updateEvent(ctx context.Context, db sqlx.ExtContext, event storage.Event) error {
+	res, err := sqlx.NamedExecContext(ctx, db, `
+		UPDATE events SET
+			title = :title, start_at = :start_at, end_at = :end_at, description = :description,
+			user_id = :user_id, kind = :kind, location = :location, conference_url = :conference_url,
+			notify_before = :notify_before, category_id = NULLIF(:category_id, '')::uuid,
+			tags = ARRAY(SELECT jsonb_array_elements_text(:tags::jsonb)), version = version + 1,
+			-- a rescheduled event is notified again
+			notified_at = CASE WHEN start_at = :start_at AND notify_before = :notify_before THEN notified_at END
+		WHERE id = :id AND version = :version`,
+		newEventRow(event))
+	if err != nil {
+		return mapError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	if _, err := getEvent(ctx, db, event.ID); err != nil {
+		return err
+	}
+	return storage.ErrVersionConflict
+}
+
+func deleteEvent(ctx context.Context, db sqlx.ExecerContext, id string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM events WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrEventNotFound
+	}
 	return nil
 }
+
+func getEventByKey(
+	ctx context.Context, db sqlx.QueryerContext, userID, key string, notBefore time.Time,
+) (storage.Event, error) {
+	var row eventRow
+	err := sqlx.GetContext(ctx, db, &row, `
+		SELECT `+eventColumns+`
+		FROM events WHERE id = (
+			SELECT event_id FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND created_at >= $3)`,
+		userID, key, notBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if err != nil {
+		return storage.Event{}, mapError(err)
+	}
+	return row.toEvent(), nil
+}
+
+func getEvent(ctx context.Context, db sqlx.QueryerContext, id string) (storage.Event, error) {
+	var row eventRow
+	err := sqlx.GetContext(ctx, db, &row, `
+		SELECT `+eventColumns+`
+		FROM events WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if err != nil {
+		return storage.Event{}, mapError(err)
+	}
+	return row.toEvent(), nil
+}
+
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sql."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func mapError(err error) error {
+	return mapErrorWith(err, storage.ErrEventNotFound)
+}
+
+// mapErrorWith returns notFound for malformed IDs: IDs are UUIDs, anything else cannot be found.
+func mapErrorWith(err error, notFound error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgExclusionViolation:
+		return storage.ErrDateBusy
+	case pgOutOfOffice:
+		return storage.ErrOutOfOffice
+	case pgUniqueViolation:
+		return storage.ErrEventExists
+	case pgForeignKeyViolation:
+		// Only calendars and categories are referenced by rows written on behalf of users.
+		if pgErr.ConstraintName == categoryFKey {
+			return storage.ErrCategoryNotFound
+		}
+		return storage.ErrCalendarNotFound
+	case pgInvalidTextRepresentation:
+		return notFound
+	}
+	return err
+}
+
+func newEventRow(event storage.Event) eventRow {
+	return eventRow{
+		ID:            event.ID,
+		Title:         event.Title,
+		StartAt:       event.StartAt,
+		EndAt:         event.EndAt,
+		Description:   event.Description,
+		UserID:        event.UserID,
+		CalendarID:    event.CalendarID,
+		Kind:          string(event.Kind),
+		Location:      event.Location.Address,
+		ConferenceURL: event.Location.ConferenceURL,
+		NotifyBefore:  int64(event.NotifyBefore),
+		Version:       event.Version,
+		CategoryID:    event.CategoryID,
+		Tags:          event.Tags,
+	}
+}
+
+func toEvents(rows []eventRow) []storage.Event {
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toEvent())
+	}
+	return events
+}
+
+func (r eventRow) toEvent() storage.Event {
+	return storage.Event{
+		ID:           r.ID,
+		Title:        r.Title,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Description:  r.Description,
+		UserID:       r.UserID,
+		CalendarID:   r.CalendarID,
+		Kind:         storage.EventKind(r.Kind),
+		Location:     storage.Location{Address: r.Location, ConferenceURL: r.ConferenceURL},
+		NotifyBefore: time.Duration(r.NotifyBefore),
+		Version:      r.Version,
+		CategoryID:   r.CategoryID,
+		Tags:         r.Tags,
+	}
+}
@@ -0,0 +1,258 @@
+package sqlstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// ownedCalendars selects calendars whose only owner is the user $1.
+const ownedCalendars = `
+	SELECT m.calendar_id FROM calendar_members m
+	WHERE m.user_id = $1 AND m.role = 'owner' AND NOT EXISTS (
+		SELECT 1 FROM calendar_members o
+		WHERE o.calendar_id = m.calendar_id AND o.user_id <> $1 AND o.role = 'owner')`
+
+type historyRow struct {
+	ID         string `db:"id"`
+	UserID     string `db:"user_id"`
+	CalendarID string `db:"calendar_id"`
+	Seq        int64  `db:"seq"`
+	Created    bool   `db:"created"`
+	Deleted    bool   `db:"deleted"`
+}
+
+type auditRow struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+	ActorID   string    `db:"actor_id"`
+	Action    string    `db:"action"`
+	UserID    string    `db:"user_id"`
+	Counts    []byte    `db:"counts"`
+}
+
+// ExportUserData returns everything stored about the user.
+func (s *Storage) ExportUserData(ctx context.Context, userID string) (_ storage.UserData, err error) {
+	ctx, span := startSpan(ctx, "ExportUserData")
+	defer func() { endSpan(span, err) }()
+
+	data := storage.UserData{UserID: userID}
+	if data.Calendars, err = s.ListUserCalendars(ctx, userID); err != nil {
+		return storage.UserData{}, err
+	}
+	if data.Categories, err = s.ListCategories(ctx, userID); err != nil {
+		return storage.UserData{}, err
+	}
+	availability, err := s.GetAvailability(ctx, userID)
+	switch {
+	case err == nil:
+		data.Availability = &availability
+	case !errors.Is(err, storage.ErrAvailabilityNotFound):
+		return storage.UserData{}, err
+	}
+
+	var events []eventRow
+	if err := s.db.SelectContext(ctx, &events, `
+		SELECT `+eventColumns+` FROM events WHERE user_id = $1 ORDER BY start_at`, userID,
+	); err != nil {
+		return storage.UserData{}, err
+	}
+	data.Events = toEvents(events)
+
+	var attachments []attachmentRow
+	if err := s.db.SelectContext(ctx, &attachments, `
+		SELECT `+attachmentColumns+` FROM attachments WHERE user_id = $1 ORDER BY created_at, id`, userID,
+	); err != nil {
+		return storage.UserData{}, err
+	}
+	data.Attachments = make([]storage.Attachment, 0, len(attachments))
+	for _, row := range attachments {
+		data.Attachments = append(data.Attachments, row.toAttachment())
+	}
+
+	var history []historyRow
+	if err := s.db.SelectContext(ctx, &history, `
+		SELECT id, user_id, coalesce(calendar_id::text, '') AS calendar_id, changed_seq AS seq,
+			created_seq = changed_seq AS created, false AS deleted
+		FROM events WHERE user_id = $1
+		UNION ALL
+		SELECT id, user_id, coalesce(calendar_id::text, '') AS calendar_id, seq, false, true
+		FROM event_tombstones WHERE user_id = $1
+		ORDER BY seq`, userID,
+	); err != nil {
+		return storage.UserData{}, err
+	}
+	data.History = make([]storage.Change, 0, len(history))
+	for _, row := range history {
+		kind := storage.ChangeUpdated
+		switch {
+		case row.Deleted:
+			kind = storage.ChangeDeleted
+		case row.Created:
+			kind = storage.ChangeCreated
+		}
+		data.History = append(data.History, storage.Change{Seq: row.Seq, Kind: kind, Event: storage.Event{
+			ID: row.ID, UserID: row.UserID, CalendarID: row.CalendarID,
+		}})
+	}
+
+	var bodies [][]byte
+	if err := s.db.SelectContext(ctx, &bodies, `
+		SELECT body FROM outbox WHERE topic = $1 AND body->>'userId' = $2 ORDER BY id`,
+		storage.TopicNotifications, userID,
+	); err != nil {
+		return storage.UserData{}, err
+	}
+	data.Notifications = make([]storage.Notification, 0, len(bodies))
+	for _, body := range bodies {
+		var n storage.Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			return storage.UserData{}, err
+		}
+		data.Notifications = append(data.Notifications, n)
+	}
+	return data, nil
+}
+
+// DeleteUserData deletes the user's events, calendars the user is the only owner of
+// with all their events, the user's memberships, attachments, categories, availability
+// settings, tombstones of personal events and notifications kept in the outbox.
+// Tombstones of calendar events are kept for members of the calendars with the user
+// removed from them. Deletions are announced to event change consumers as usual, the user
+// is removed from the changes in the outbox, those of the deletions included. Changes
+// already published to the queue or received by consumers keep the user.
+// The audit record is stored in the same transaction with the counts of deleted records.
+func (s *Storage) DeleteUserData(
+	ctx context.Context, record storage.AuditRecord,
+) (_ storage.ErasedUserData, err error) {
+	ctx, span := startSpan(ctx, "DeleteUserData")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return storage.ErasedUserData{}, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	userID := record.UserID
+	var attachments []attachmentRow
+	if err := tx.SelectContext(ctx, &attachments, `
+		DELETE FROM attachments
+		WHERE user_id = $1 OR event_id IN (
+			SELECT id FROM events WHERE user_id = $1 OR calendar_id IN (`+ownedCalendars+`))
+		RETURNING `+attachmentColumns, userID,
+	); err != nil {
+		return storage.ErasedUserData{}, err
+	}
+	erased := storage.ErasedUserData{Attachments: make([]storage.Attachment, 0, len(attachments))}
+	for _, row := range attachments {
+		erased.Attachments = append(erased.Attachments, row.toAttachment())
+	}
+
+	counts := map[string]int{"attachments": len(attachments)}
+	// Every query returns the number of affected records, those of an empty kind are not counted.
+	deletes := []struct {
+		kind  string
+		query string
+	}{
+		// Events of the calendars are counted before the calendars cascade to them.
+		{"events", `SELECT count(*) FROM events WHERE user_id = $1 OR calendar_id IN (` + ownedCalendars + `)`},
+		{"calendars", `WITH d AS (DELETE FROM calendars WHERE id IN (` + ownedCalendars + `) RETURNING 1)
+			SELECT count(*) FROM d`},
+		{"", `WITH d AS (DELETE FROM events WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM d`},
+		{"memberships", `WITH d AS (DELETE FROM calendar_members WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM d`},
+		{"categories", `WITH d AS (DELETE FROM categories WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM d`},
+		{"availability", `WITH d AS (DELETE FROM user_availability WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM d`},
+		{"", `WITH d AS (DELETE FROM idempotency_keys WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM d`},
+		{"", `WITH d AS (DELETE FROM event_tombstones WHERE user_id = $1 AND calendar_id IS NULL RETURNING 1)
+			SELECT count(*) FROM d`},
+		{"", `WITH u AS (UPDATE event_tombstones SET user_id = '' WHERE user_id = $1 RETURNING 1) SELECT count(*) FROM u`},
+		{"notifications", `WITH d AS (
+				DELETE FROM outbox WHERE topic = 'notifications' AND body->>'userId' = $1 RETURNING 1)
+			SELECT count(*) FROM d`},
+		// Changes of the deleted events, announced by the deletes above too, stay announced without the user.
+		{"", `WITH u AS (
+				UPDATE outbox SET body = body || '{"userId": ""}'
+				WHERE topic = 'event_changes' AND body->>'userId' = $1 RETURNING 1)
+			SELECT count(*) FROM u`},
+	}
+	for _, d := range deletes {
+		var n int
+		if err := tx.GetContext(ctx, &n, d.query, userID); err != nil {
+			return storage.ErasedUserData{}, err
+		}
+		if d.kind != "" {
+			counts[d.kind] = n
+		}
+	}
+
+	record.Counts = counts
+	if erased.Record, err = addAuditRecord(ctx, tx, record); err != nil {
+		return storage.ErasedUserData{}, err
+	}
+	return erased, tx.Commit()
+}
+
+// AddAuditRecord stores the record and returns it with its ID and time set.
+func (s *Storage) AddAuditRecord(
+	ctx context.Context, record storage.AuditRecord,
+) (_ storage.AuditRecord, err error) {
+	ctx, span := startSpan(ctx, "AddAuditRecord")
+	defer func() { endSpan(span, err) }()
+
+	return addAuditRecord(ctx, s.db, record)
+}
+
+// ListAuditRecords returns the audit records about the user, all records if userID is empty,
+// oldest first.
+func (s *Storage) ListAuditRecords(ctx context.Context, userID string) (_ []storage.AuditRecord, err error) {
+	ctx, span := startSpan(ctx, "ListAuditRecords")
+	defer func() { endSpan(span, err) }()
+
+	var rows []auditRow
+	if err := s.db.SelectContext(ctx, &rows, `
+		SELECT id, created_at, actor_id, action, user_id, counts
+		FROM audit_log WHERE $1 = '' OR user_id = $1
+		ORDER BY id`, userID,
+	); err != nil {
+		return nil, err
+	}
+	records := make([]storage.AuditRecord, 0, len(rows))
+	for _, row := range rows {
+		record := storage.AuditRecord{
+			ID:      row.ID,
+			Time:    row.CreatedAt,
+			ActorID: row.ActorID,
+			Action:  storage.AuditAction(row.Action),
+			UserID:  row.UserID,
+		}
+		if err := json.Unmarshal(row.Counts, &record.Counts); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func addAuditRecord(
+	ctx context.Context, q sqlx.QueryerContext, record storage.AuditRecord,
+) (storage.AuditRecord, error) {
+	counts, err := json.Marshal(record.Counts)
+	if err != nil {
+		return storage.AuditRecord{}, err
+	}
+	var row auditRow
+	err = sqlx.GetContext(ctx, q, &row, `
+		INSERT INTO audit_log (actor_id, action, user_id, counts) VALUES ($1, $2, $3, $4::jsonb)
+		RETURNING id, created_at, actor_id, action, user_id, counts`,
+		record.ActorID, record.Action, record.UserID, string(counts))
+	if err != nil {
+		return storage.AuditRecord{}, err
+	}
+	record.ID, record.Time = row.ID, row.CreatedAt
+	return record, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "time"
+
+// UserData is everything stored about a user.
+type UserData struct {
+	UserID string
+	// Events are the events the user created, personal ones and those of calendars, ordered by start time.
+	Events []Event
+	// Attachments are the files the user attached to any event.
+	Attachments []Attachment
+	Calendars   []UserCalendar
+	Categories  []Category
+	// Availability is nil if the user has not set it.
+	Availability *Availability
+	// History is the latest change of every event the user created, deleted events
+	// included until their tombstones are purged, ordered by sequence.
+	History []Change
+	// Notifications are the user's notifications kept in the outbox, sent or not.
+	Notifications []Notification
+}
+
+// ErasedUserData is what DeleteUserData removed along with the user.
+type ErasedUserData struct {
+	// Record is the stored audit record of the erasure.
+	Record AuditRecord
+	// Attachments are deleted attachments whose contents are left in the blob store.
+	Attachments []Attachment
+}
+
+type AuditAction string
+
+const (
+	AuditExportUserData AuditAction = "export_user_data"
+	AuditEraseUserData  AuditAction = "erase_user_data"
+)
+
+// AuditRecord tells that an admin accessed or erased the data of a user.
+// Audit records outlive the data they are about.
+type AuditRecord struct {
+	ID      int64
+	Time    time.Time
+	ActorID string
+	Action  AuditAction
+	UserID  string
+	// Counts are the numbers of exported or erased records by their kind, e.g. "events".
+	Counts map[string]int
+}
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE events (
+    id            uuid PRIMARY KEY,
+    title         text        NOT NULL,
+    start_at      timestamptz NOT NULL,
+    end_at        timestamptz NOT NULL,
+    description   text        NOT NULL DEFAULT '',
+    user_id       text        NOT NULL,
+    notify_before bigint      NOT NULL DEFAULT 0,
+    CHECK (end_at > start_at),
+    -- Events of the same user must not overlap (storage.ErrDateBusy).
+    CONSTRAINT events_no_overlap EXCLUDE USING gist (user_id WITH =, tstzrange(start_at, end_at) WITH &&)
+);
+
+CREATE INDEX events_user_start_idx ON events (user_id, start_at);
+
+-- +goose Down
+DROP TABLE events;
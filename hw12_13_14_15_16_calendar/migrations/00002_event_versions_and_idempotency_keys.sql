@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN version bigint NOT NULL DEFAULT 1;
+
+CREATE TABLE idempotency_keys (
+    user_id    text        NOT NULL,
+    key        text        NOT NULL,
+    event_id   uuid        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, key)
+);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
+ALTER TABLE events DROP COLUMN version;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN notified_at timestamptz;
+
+CREATE INDEX events_to_notify_idx ON events (start_at) WHERE notified_at IS NULL AND notify_before > 0;
+
+-- +goose Down
+DROP INDEX events_to_notify_idx;
+ALTER TABLE events DROP COLUMN notified_at;
@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE calendars (
+    id   uuid PRIMARY KEY,
+    name text NOT NULL
+);
+
+CREATE TABLE calendar_members (
+    calendar_id uuid NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    user_id     text NOT NULL,
+    role        text NOT NULL CHECK (role IN ('owner', 'editor', 'viewer', 'freebusy')),
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX calendar_members_user_idx ON calendar_members (user_id);
+
+-- Events without a calendar are personal events of their user.
+ALTER TABLE events ADD COLUMN calendar_id uuid REFERENCES calendars (id) ON DELETE CASCADE;
+
+-- Events of the same calendar, or personal events of the same user, must not overlap.
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    (coalesce(calendar_id::text, 'user:' || user_id)) WITH =,
+    tstzrange(start_at, end_at) WITH &&
+);
+
+CREATE INDEX events_calendar_start_idx ON events (calendar_id, start_at) WHERE calendar_id IS NOT NULL;
+
+-- +goose Down
+DELETE FROM events WHERE calendar_id IS NOT NULL;
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    user_id WITH =,
+    tstzrange(start_at, end_at) WITH &&
+);
+ALTER TABLE events DROP COLUMN calendar_id;
+DROP TABLE calendar_members;
+DROP TABLE calendars;
@@ -0,0 +1,70 @@
+-- +goose Up
+-- Every change of an event takes the next value of the sequence, clients sync
+-- from the last value they have seen (storage ListChanges).
+CREATE SEQUENCE event_change_seq;
+
+ALTER TABLE events ADD COLUMN created_seq bigint NOT NULL DEFAULT nextval('event_change_seq');
+ALTER TABLE events ADD COLUMN changed_seq bigint;
+UPDATE events SET changed_seq = created_seq;
+ALTER TABLE events ALTER COLUMN changed_seq SET NOT NULL;
+ALTER TABLE events ALTER COLUMN created_seq DROP DEFAULT;
+
+CREATE INDEX events_changed_seq_idx ON events (changed_seq);
+
+-- Deleted events, including those deleted by cascade or retention.
+CREATE TABLE event_tombstones (
+    id          uuid PRIMARY KEY,
+    user_id     text        NOT NULL,
+    calendar_id uuid,
+    seq         bigint      NOT NULL,
+    deleted_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX event_tombstones_seq_idx ON event_tombstones (seq);
+
+-- The sequence of the latest purged tombstone, clients that synced before it must resync.
+CREATE TABLE event_tombstone_purges (
+    id         boolean PRIMARY KEY DEFAULT true CHECK (id),
+    purged_seq bigint NOT NULL
+);
+
+INSERT INTO event_tombstone_purges (purged_seq) VALUES (0);
+
+-- Writers take the lock until commit, so changes become visible in sequence order
+-- and a client never skips a change committed after it has synced past its sequence.
+-- +goose StatementBegin
+CREATE FUNCTION events_track_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtextextended('event_change_seq', 0));
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO event_tombstones (id, user_id, calendar_id, seq)
+        VALUES (OLD.id, OLD.user_id, OLD.calendar_id, nextval('event_change_seq'));
+        RETURN OLD;
+    END IF;
+
+    NEW.changed_seq := nextval('event_change_seq');
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_seq := NEW.changed_seq;
+        DELETE FROM event_tombstones WHERE id = NEW.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_track_insert_delete BEFORE INSERT OR DELETE ON events
+    FOR EACH ROW EXECUTE FUNCTION events_track_change();
+
+-- Marking an event as notified does not change it for clients.
+CREATE TRIGGER events_track_update BEFORE UPDATE ON events
+    FOR EACH ROW WHEN (OLD.version IS DISTINCT FROM NEW.version) EXECUTE FUNCTION events_track_change();
+
+-- +goose Down
+DROP TRIGGER events_track_update ON events;
+DROP TRIGGER events_track_insert_delete ON events;
+DROP FUNCTION events_track_change();
+DROP TABLE event_tombstone_purges;
+DROP TABLE event_tombstones;
+ALTER TABLE events DROP COLUMN changed_seq;
+ALTER TABLE events DROP COLUMN created_seq;
+DROP SEQUENCE event_change_seq;
@@ -0,0 +1,66 @@
+-- +goose Up
+-- Messages written in the same transaction as the changes they announce
+-- and published to the queue of their topic by the outbox relay.
+CREATE TABLE outbox (
+    id         bigserial PRIMARY KEY,
+    topic      text        NOT NULL,
+    body       jsonb       NOT NULL,
+    headers    jsonb       NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    sent_at    timestamptz
+);
+
+CREATE INDEX outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
+CREATE INDEX outbox_sent_at_idx ON outbox (sent_at) WHERE sent_at IS NOT NULL;
+
+-- Changes are announced by the trigger that tracks them, so that deletes
+-- by calendar cascade and by retention are announced too.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION events_track_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtextextended('event_change_seq', 0));
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO event_tombstones (id, user_id, calendar_id, seq)
+        VALUES (OLD.id, OLD.user_id, OLD.calendar_id, nextval('event_change_seq'));
+        INSERT INTO outbox (topic, body)
+        VALUES ('event_changes', jsonb_strip_nulls(jsonb_build_object(
+            'kind', 'deleted', 'eventId', OLD.id, 'userId', OLD.user_id, 'calendarId', OLD.calendar_id)));
+        RETURN OLD;
+    END IF;
+
+    NEW.changed_seq := nextval('event_change_seq');
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_seq := NEW.changed_seq;
+        DELETE FROM event_tombstones WHERE id = NEW.id;
+    END IF;
+    INSERT INTO outbox (topic, body)
+    VALUES ('event_changes', jsonb_strip_nulls(jsonb_build_object(
+        'kind', CASE WHEN TG_OP = 'INSERT' THEN 'created' ELSE 'updated' END,
+        'eventId', NEW.id, 'userId', NEW.user_id, 'calendarId', NEW.calendar_id, 'version', NEW.version)));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION events_track_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtextextended('event_change_seq', 0));
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO event_tombstones (id, user_id, calendar_id, seq)
+        VALUES (OLD.id, OLD.user_id, OLD.calendar_id, nextval('event_change_seq'));
+        RETURN OLD;
+    END IF;
+
+    NEW.changed_seq := nextval('event_change_seq');
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_seq := NEW.changed_seq;
+        DELETE FROM event_tombstones WHERE id = NEW.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TABLE outbox;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE events
+    ADD COLUMN location       text NOT NULL DEFAULT '',
+    ADD COLUMN conference_url text NOT NULL DEFAULT '';
+
+-- Metadata of files attached to events, their contents are kept in the blob store.
+CREATE TABLE attachments (
+    id           uuid PRIMARY KEY,
+    event_id     uuid        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    name         text        NOT NULL,
+    content_type text        NOT NULL,
+    size         bigint      NOT NULL,
+    user_id      text        NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX attachments_event_idx ON attachments (event_id);
+
+-- +goose Down
+DROP TABLE attachments;
+
+ALTER TABLE events DROP COLUMN location, DROP COLUMN conference_url;
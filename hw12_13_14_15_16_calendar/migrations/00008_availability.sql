@@ -0,0 +1,29 @@
+-- +goose Up
+-- Out-of-office periods are personal events that other events of their user may overlap.
+ALTER TABLE events
+    ADD COLUMN kind text NOT NULL DEFAULT '' CHECK (kind IN ('', 'out_of_office')),
+    ADD CONSTRAINT events_out_of_office_personal CHECK (kind <> 'out_of_office' OR calendar_id IS NULL);
+
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    (coalesce(calendar_id::text, 'user:' || user_id)) WITH =,
+    tstzrange(start_at, end_at) WITH &&
+) WHERE (kind <> 'out_of_office');
+
+CREATE TABLE user_availability (
+    user_id       text PRIMARY KEY,
+    time_zone     text  NOT NULL,
+    -- [{"weekday": 1, "start": 540, "end": 1080}, ...], times in minutes from midnight.
+    working_hours jsonb NOT NULL
+);
+
+-- +goose Down
+DROP TABLE user_availability;
+
+DELETE FROM events WHERE kind = 'out_of_office';
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_no_overlap EXCLUDE USING gist (
+    (coalesce(calendar_id::text, 'user:' || user_id)) WITH =,
+    tstzrange(start_at, end_at) WITH &&
+);
+ALTER TABLE events DROP COLUMN kind;
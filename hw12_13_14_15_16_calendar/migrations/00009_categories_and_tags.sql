@@ -0,0 +1,20 @@
+-- +goose Up
+-- Categories are per user, names are unique per user regardless of case.
+CREATE TABLE categories (
+    id      uuid PRIMARY KEY,
+    user_id text NOT NULL,
+    name    text NOT NULL,
+    color   text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX categories_user_name_idx ON categories (user_id, lower(name));
+
+-- Deleting a category clears it from its events, storage DeleteCategory does so
+-- with a version bump so that clients see the change.
+ALTER TABLE events
+    ADD COLUMN category_id uuid REFERENCES categories (id) ON DELETE SET NULL,
+    ADD COLUMN tags text[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN tags, DROP COLUMN category_id;
+DROP TABLE categories;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Admin operations on user data, kept after the data they are about is erased.
+CREATE TABLE audit_log (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    actor_id   text        NOT NULL,
+    action     text        NOT NULL,
+    user_id    text        NOT NULL,
+    counts     jsonb       NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_user_idx ON audit_log (user_id, id);
+
+-- +goose Down
+DROP TABLE audit_log;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Regular personal events must not overlap out-of-office periods of their users, while
+-- the periods may overlap events created before them. The exclusion constraint cannot
+-- tell these apart, the trigger checks new and rescheduled events instead.
+-- Writers of personal events of a user take the lock until commit, so an event and
+-- a period created concurrently see each other.
+-- +goose StatementBegin
+CREATE FUNCTION events_check_out_of_office() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtextextended('events:' || NEW.user_id, 0));
+    IF NEW.kind <> 'out_of_office' AND EXISTS (
+        SELECT 1 FROM events
+        WHERE user_id = NEW.user_id AND kind = 'out_of_office' AND id <> NEW.id
+            AND tstzrange(start_at, end_at) && tstzrange(NEW.start_at, NEW.end_at)
+    ) THEN
+        RAISE EXCEPTION 'event % overlaps an out-of-office period of user %', NEW.id, NEW.user_id
+            USING ERRCODE = 'CA001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_check_out_of_office_insert BEFORE INSERT ON events
+    FOR EACH ROW WHEN (NEW.calendar_id IS NULL) EXECUTE FUNCTION events_check_out_of_office();
+
+-- Events already overlapping periods may still be edited if they are not moved.
+CREATE TRIGGER events_check_out_of_office_update BEFORE UPDATE ON events
+    FOR EACH ROW WHEN (NEW.calendar_id IS NULL AND (OLD.start_at, OLD.end_at, OLD.kind)
+        IS DISTINCT FROM (NEW.start_at, NEW.end_at, NEW.kind))
+    EXECUTE FUNCTION events_check_out_of_office();
+
+-- +goose Down
+DROP TRIGGER events_check_out_of_office_update ON events;
+DROP TRIGGER events_check_out_of_office_insert ON events;
+DROP FUNCTION events_check_out_of_office();
@@ -0,0 +1,79 @@
+{{/*
+Prefix of object names, truncated so that suffixed names fit the 63 characters
+Kubernetes allows.
+*/}}
+{{- define "calendar.fullname" -}}
+{{- default .Release.Name .Values.fullnameOverride | trunc 50 | trimSuffix "-" }}
+{{- end }}
+
+{{/*
+Labels of the objects of a component, called with a dict of the root context
+and the component name.
+*/}}
+{{- define "calendar.labels" -}}
+helm.sh/chart: {{ printf "%s-%s" .context.Chart.Name .context.Chart.Version }}
+{{ include "calendar.selectorLabels" . }}
+app.kubernetes.io/version: {{ .context.Chart.AppVersion | quote }}
+app.kubernetes.io/managed-by: {{ .context.Release.Service }}
+{{- end }}
+
+{{- define "calendar.selectorLabels" -}}
+app.kubernetes.io/name: {{ .context.Chart.Name }}
+app.kubernetes.io/instance: {{ .context.Release.Name }}
+app.kubernetes.io/component: {{ .component }}
+{{- end }}
+
+{{/*
+Image of a component, called with a dict of the root context and the image values.
+*/}}
+{{- define "calendar.image" -}}
+{{ .image.repository }}:{{ .context.Values.image.tag | default .context.Chart.AppVersion }}
+{{- end }}
+
+{{/*
+Contents of config.toml of the API: api.config and, if the chart creates the secret
+with a JWT secret, the [auth.jwt] table verifying HS256 tokens.
+*/}}
+{{- define "calendar.apiConfig" -}}
+{{ .Values.api.config }}
+{{- if and .Values.secrets.jwtSecret (not .Values.secrets.existingSecret) }}
+# The secret is passed as CALENDAR_AUTH_JWT_SECRET.
+[auth.jwt]
+algorithm = "HS256"
+{{ end }}
+{{- end }}
+
+{{- define "calendar.secretName" -}}
+{{- .Values.secrets.existingSecret | default (include "calendar.fullname" .) }}
+{{- end }}
+
+{{/*
+Environment variable set from a key of the secret, called with a list of the root
+context, the variable name, the key and whether the key is optional.
+*/}}
+{{- define "calendar.secretEnv" -}}
+{{- $context := index . 0 -}}
+- name: {{ index . 1 }}
+  valueFrom:
+    secretKeyRef:
+      name: {{ include "calendar.secretName" $context }}
+      key: {{ index . 2 }}
+      {{- if index . 3 }}
+      optional: true
+      {{- end }}
+{{- end }}
+
+{{- define "calendar.scheduling" -}}
+{{- with .Values.nodeSelector }}
+nodeSelector:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- with .Values.affinity }}
+affinity:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- with .Values.tolerations }}
+tolerations:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- end }}
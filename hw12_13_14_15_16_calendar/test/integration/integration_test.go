@@ -0,0 +1,193 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests need the calendar started by deployments/docker-compose.yaml, e.g.
+// CALENDAR_INTEGRATION_URL=http://localhost:8888. Notifications are received by a webhook
+// listening at CALENDAR_INTEGRATION_WEBHOOK_ADDR that the sender delivers to.
+func baseURL(t *testing.T) string {
+	t.Helper()
+
+	url := os.Getenv("CALENDAR_INTEGRATION_URL")
+	if url == "" {
+		t.Skip("CALENDAR_INTEGRATION_URL is not set")
+	}
+	return url
+}
+
+type event struct {
+	ID           string `json:"id,omitempty"`
+	Title        string `json:"title"`
+	StartAt      string `json:"startAt"`
+	EndAt        string `json:"endAt"`
+	NotifyBefore string `json:"notifyBefore,omitempty"`
+}
+
+func do(t *testing.T, method, url, userID string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req, err := http.NewRequestWithContext(context.Background(), method, url, &reqBody)
+	require.NoError(t, err)
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	result := make(map[string]any)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
+}
+
+func TestCreateEvent(t *testing.T) {
+	url := baseURL(t) + "/v1/events"
+	userID := uuid.NewString()
+	standup := event{Title: "standup", StartAt: "2030-04-01T10:00:00Z", EndAt: "2030-04-01T10:15:00Z"}
+
+	status, body := do(t, http.MethodPost, url, userID, standup)
+	require.Equal(t, http.StatusOK, status)
+	created := body["event"].(map[string]any)
+	require.NotEmpty(t, created["id"])
+	require.Equal(t, userID, created["userId"])
+
+	t.Run("date is busy", func(t *testing.T) {
+		overlapping := event{Title: "review", StartAt: "2030-04-01T10:10:00Z", EndAt: "2030-04-01T11:00:00Z"}
+		status, _ := do(t, http.MethodPost, url, userID, overlapping)
+		require.Equal(t, http.StatusConflict, status)
+
+		status, _ = do(t, http.MethodPost, url, uuid.NewString(), overlapping)
+		require.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		status, body := do(t, http.MethodPost, url, userID, event{StartAt: standup.StartAt, EndAt: standup.EndAt})
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, body["message"], "title is required")
+
+		backwards := event{Title: "backwards", StartAt: standup.EndAt, EndAt: standup.StartAt}
+		status, _ = do(t, http.MethodPost, url, userID, backwards)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		status, _ := do(t, http.MethodPost, url, "", standup)
+		require.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		status, _ := do(t, http.MethodDelete, url+"/"+created["id"].(string), uuid.NewString(), nil)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
+
+func TestListEvents(t *testing.T) {
+	url := baseURL(t) + "/v1/events"
+	userID := uuid.NewString()
+	for _, day := range []string{"2030-04-01", "2030-04-03", "2030-04-20", "2030-05-02"} {
+		status, _ := do(t, http.MethodPost, url, userID, event{
+			Title:   "event on " + day,
+			StartAt: day + "T09:00:00Z",
+			EndAt:   day + "T10:00:00Z",
+		})
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	tests := []struct {
+		period string
+		titles []string
+	}{
+		{period: "day", titles: []string{"event on 2030-04-01"}},
+		{period: "week", titles: []string{"event on 2030-04-01", "event on 2030-04-03"}},
+		{period: "month", titles: []string{"event on 2030-04-01", "event on 2030-04-03", "event on 2030-04-20"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.period, func(t *testing.T) {
+			status, body := do(t, http.MethodGet, url+"/"+tc.period+"?date=2030-04-01T00:00:00Z", userID, nil)
+			require.Equal(t, http.StatusOK, status)
+			titles := make([]string, 0)
+			events, _ := body["events"].([]any)
+			for _, e := range events {
+				titles = append(titles, e.(map[string]any)["title"].(string))
+			}
+			require.Equal(t, tc.titles, titles)
+		})
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	url := baseURL(t) + "/v1/events"
+	addr := os.Getenv("CALENDAR_INTEGRATION_WEBHOOK_ADDR")
+	if addr == "" {
+		t.Skip("CALENDAR_INTEGRATION_WEBHOOK_ADDR is not set")
+	}
+
+	notifications := make(chan storage.Notification, 100)
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var n storage.Notification
+			if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			notifications <- n
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() { _ = server.Close() })
+
+	userID := uuid.NewString()
+	startAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	status, body := do(t, http.MethodPost, url, userID, event{
+		Title:        "planning",
+		StartAt:      startAt.Format(time.RFC3339),
+		EndAt:        startAt.Add(time.Hour).Format(time.RFC3339),
+		NotifyBefore: fmt.Sprintf("%ds", int((2 * time.Hour).Seconds())),
+	})
+	require.Equal(t, http.StatusOK, status)
+	id := body["event"].(map[string]any)["id"].(string)
+
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case n := <-notifications:
+			if n.EventID != id {
+				continue
+			}
+			require.Equal(t, "planning", n.Title)
+			require.Equal(t, userID, n.UserID)
+			require.True(t, startAt.Equal(n.Date))
+			return
+		case <-timeout:
+			t.Fatal("no notification of the event in 30s")
+		}
+	}
+}